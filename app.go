@@ -2,14 +2,15 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/m8u/goro/pkg/v1/layer"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"gorgonia.org/tensor"
+	"os"
+	"path/filepath"
 	goRuntime "runtime"
 	"sotsuron/internal/datasets"
 	"sotsuron/internal/evolution"
-	"sotsuron/internal/utils"
 	"strings"
 )
 
@@ -19,6 +20,11 @@ type App struct {
 	dataset *datasets.Dataset
 	species *evolution.Species
 	testImg tensor.Tensor
+	advCfg  evolution.AdvancedConfig
+
+	// model loaded from disk, used by Predict instead of the best individual of species
+	model           *evolution.Individual
+	modelClassNames []string
 }
 
 // NewApp creates a new App application struct
@@ -32,11 +38,19 @@ func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 }
 
+// DefaultAdvancedConfig lets the frontend fill in advanced config fields that have no inputs on the form
+func (a *App) DefaultAdvancedConfig() evolution.AdvancedConfig {
+	return evolution.DefaultAdvancedConfig()
+}
+
 func (a *App) LoadDataset(grayscale bool) *datasets.DatasetInfo {
 	path, err := runtime.OpenDirectoryDialog(a.ctx, runtime.OpenDialogOptions{
 		Title: "Укажите путь к датасету",
 	})
-	utils.MaybeCrash(err)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось открыть диалог выбора датасета")
+		return nil
+	}
 	if path == "" {
 		return nil
 	}
@@ -48,18 +62,86 @@ func (a *App) LoadDataset(grayscale bool) *datasets.DatasetInfo {
 	return a.dataset.GetInfo()
 }
 
-func (a *App) Evolve(advCfg evolution.AdvancedConfig, trainTestRatio float32, numIndividuals, numGenerations int) {
+// Evolve starts a new evolution. Architectures are DSL strings or names of evolution.ArchitectureTemplates
+// that take the first slots of the initial population
+func (a *App) Evolve(advCfg evolution.AdvancedConfig, trainTestRatio float32, numIndividuals, numGenerations int, architectures []string) {
+	if a.dataset == nil {
+		runtime.EventsEmit(a.ctx, "error", "Датасет не загружен")
+		runtime.EventsEmit(a.ctx, "evo-progress", evolution.Progress{Generation: -1})
+		return
+	}
 	datasetInfo := a.dataset.GetInfo()
-	a.species = evolution.NewSpecies(
+	channels := 3
+	if datasetInfo.Grayscale {
+		channels = 1
+	}
+	var genomes []evolution.Genome
+	for _, architecture := range architectures {
+		if template, ok := evolution.ArchitectureTemplates[architecture]; ok {
+			architecture = template
+		}
+		genome, err := evolution.ParseArchitecture([]byte(architecture), evolution.FormatDSL, datasetInfo.Resolution, channels)
+		if err != nil {
+			runtime.EventsEmit(a.ctx, "error", fmt.Sprintf("Неверная архитектура %q: %v", architecture, err))
+			runtime.EventsEmit(a.ctx, "evo-progress", evolution.Progress{Generation: -1})
+			return
+		}
+		genomes = append(genomes, genome)
+	}
+	species, err := evolution.NewSpecies(
 		advCfg,
 		numIndividuals,
 		datasetInfo.Resolution.Width,
 		datasetInfo.Resolution.Height,
 		datasetInfo.NumClasses,
 		datasetInfo.Grayscale,
+		genomes...,
 	)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", fmt.Sprintf("Не удалось создать популяцию: %v", err))
+		runtime.EventsEmit(a.ctx, "evo-progress", evolution.Progress{Generation: -1})
+		return
+	}
+	a.species = species
+	a.evolve(advCfg, trainTestRatio, numGenerations)
+}
+
+// Resume continues the evolution saved in the last checkpoint, the same dataset has to be loaded
+func (a *App) Resume(trainTestRatio float32, numGenerations int) {
+	if a.dataset == nil {
+		runtime.EventsEmit(a.ctx, "error", "Датасет не загружен")
+		runtime.EventsEmit(a.ctx, "evo-progress", evolution.Progress{Generation: -1})
+		return
+	}
+	species, advCfg, err := evolution.LoadCheckpoint(checkpointPath())
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось загрузить контрольную точку")
+		runtime.EventsEmit(a.ctx, "evo-progress", evolution.Progress{Generation: -1})
+		return
+	}
+	a.species = species
+	a.evolve(advCfg, trainTestRatio, numGenerations)
+}
+
+func checkpointPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sotsuron", "checkpoint.gob")
+}
+
+func (a *App) evolve(advCfg evolution.AdvancedConfig, trainTestRatio float32, numGenerations int) {
+	a.model, a.modelClassNames = nil, nil
+	a.advCfg = advCfg
+	a.species.SetCheckpointPath(checkpointPath())
+	a.dataset.Shuffle(a.species.Seed())
 	xTrain, yTrain, xTest, yTest, err := a.dataset.SplitTrainTest(trainTestRatio)
-	utils.MaybeCrash(err)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось разделить датасет на обучающую и тестовую выборки")
+		runtime.EventsEmit(a.ctx, "evo-progress", evolution.Progress{Generation: -1})
+		return
+	}
 
 	shouldStop := false
 	progressChan := make(chan evolution.Progress)
@@ -80,21 +162,25 @@ func (a *App) Evolve(advCfg evolution.AdvancedConfig, trainTestRatio float32, nu
 			runtime.EventsEmit(a.ctx, "evo-all-chart", data)
 		}
 	}()
-	bestChartChan := make(chan float32)
+	bestChartChan := make(chan evolution.BestChartData)
 	go func() {
 		for !shouldStop {
 			data := <-bestChartChan
 			runtime.EventsEmit(a.ctx, "evo-best-chart", data)
 		}
 	}()
-	bestLayersChan := make(chan []layer.Config)
+	bestStructureChan := make(chan evolution.BestStructure)
 	go func() {
 		for !shouldStop {
-			bestLayers := <-bestLayersChan
-			if bestLayers == nil {
+			best := <-bestStructureChan
+			if best.Layers == nil {
 				return
 			}
-			runtime.EventsEmit(a.ctx, "evo-best-layers", evolution.SimplifyLayers(bestLayers))
+			runtime.EventsEmit(a.ctx, "evo-best-layers", evolution.SimplifyLayers(best.Layers))
+			runtime.EventsEmit(a.ctx, "evo-best-hyperparameters", best.Hyperparameters)
+			if best.Niches != nil {
+				runtime.EventsEmit(a.ctx, "evo-best-niches", best.Niches)
+			}
 		}
 	}()
 	ctx, cancel := context.WithCancel(a.ctx)
@@ -102,25 +188,61 @@ func (a *App) Evolve(advCfg evolution.AdvancedConfig, trainTestRatio float32, nu
 		cancel()
 	})
 
-	a.species.Evolve(ctx, advCfg, numGenerations, xTrain, yTrain, xTest, yTest, progressChan, allChartChan, bestChartChan, bestLayersChan)
+	err = a.species.Evolve(ctx, advCfg, numGenerations, xTrain, yTrain, xTest, yTest, progressChan, allChartChan, bestChartChan, bestStructureChan)
 	fmt.Println("Evolution finished (backend)")
 	shouldStop = true
 	close(progressChan)
 	close(allChartChan)
 	close(bestChartChan)
-	close(bestLayersChan)
+	close(bestStructureChan)
+	if errors.As(err, &evolution.ExtinctionError{}) {
+		runtime.EventsEmit(a.ctx, "error", "Все особи погибли, эволюция остановлена")
+		return
+	} else if err != nil {
+		runtime.EventsEmit(a.ctx, "error", fmt.Sprintf("Эволюция остановлена: %v", err))
+		return
+	}
+	runtime.EventsEmit(a.ctx, "evo-pareto-front", a.ParetoFront())
+}
+
+// ParetoFront describes individuals of the last evolution that are best trade-offs between objectives
+func (a *App) ParetoFront() []evolution.ParetoPoint {
+	if a.species == nil {
+		return nil
+	}
+	front, err := a.species.ParetoFront(a.advCfg)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Неизвестная цель оптимизации")
+		return nil
+	}
+	return evolution.DescribeParetoFront(front)
 }
 
 func (a *App) LoadImage() (loadedFilename string) {
 	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
 		Title: "Укажите путь к изображению",
 	})
-	utils.MaybeCrash(err)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось открыть диалог выбора изображения")
+		return ""
+	}
 	if path == "" {
 		return ""
 	}
-	a.testImg, err = datasets.LoadImage(path, a.dataset.GetInfo().Grayscale)
-	utils.MaybeCrash(err)
+	var grayscale bool
+	if a.model != nil {
+		grayscale = a.model.IsGrayscale()
+	} else if a.dataset != nil {
+		grayscale = a.dataset.GetInfo().Grayscale
+	} else {
+		runtime.EventsEmit(a.ctx, "error", "Нет модели, для которой загружается изображение")
+		return ""
+	}
+	a.testImg, err = datasets.LoadImage(path, grayscale)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось загрузить изображение")
+		return ""
+	}
 
 	if goRuntime.GOOS == "windows" {
 		return strings.Split(path, "\\")[len(strings.Split(path, "\\"))-1]
@@ -129,8 +251,110 @@ func (a *App) LoadImage() (loadedFilename string) {
 }
 
 func (a *App) Predict() []evolution.ClassProbability {
-	probabilities, err := a.species.Best().Predict(a.testImg, a.dataset.ClassNames())
-	utils.MaybeCrash(err)
+	individual, classNames := a.model, a.modelClassNames
+	if individual == nil && a.species != nil {
+		individual, classNames = a.species.Best(), a.dataset.ClassNames()
+	}
+	if individual == nil {
+		runtime.EventsEmit(a.ctx, "error", "Нет обученной модели")
+		return nil
+	}
+	if a.testImg == nil {
+		runtime.EventsEmit(a.ctx, "error", "Изображение не загружено")
+		return nil
+	}
+	probabilities, err := individual.Predict(a.testImg, classNames)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось классифицировать изображение")
+		return nil
+	}
 	fmt.Println(probabilities)
 	return probabilities
 }
+
+// SaveModel writes the best individual of the last evolution to a file chosen by user
+func (a *App) SaveModel() {
+	if a.species == nil {
+		runtime.EventsEmit(a.ctx, "error", "Нет обученной модели")
+		return
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Сохранить модель",
+		DefaultFilename: "model.json",
+	})
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось открыть диалог сохранения модели")
+		return
+	}
+	if path == "" {
+		return
+	}
+	err = a.species.Best().SaveModel(path, a.dataset.ClassNames())
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось сохранить модель")
+	}
+}
+
+// BestArchitecture returns the architecture of the loaded model or the best individual in the DSL
+func (a *App) BestArchitecture() string {
+	switch {
+	case a.model != nil:
+		return a.model.Genome().String()
+	case a.species != nil:
+		return a.species.Best().Genome().String()
+	default:
+		runtime.EventsEmit(a.ctx, "error", "Нет обученной модели")
+		return ""
+	}
+}
+
+// SaveArchitecture writes the architecture of the best individual in one of the formats: "json", "yaml" or "dsl"
+func (a *App) SaveArchitecture(format string) {
+	if a.species == nil {
+		runtime.EventsEmit(a.ctx, "error", "Нет обученной модели")
+		return
+	}
+	data, err := a.species.Best().Genome().MarshalArchitecture(format)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось сохранить архитектуру")
+		return
+	}
+	extension := format
+	if format == evolution.FormatDSL {
+		extension = "txt"
+	}
+	path, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Сохранить архитектуру",
+		DefaultFilename: "architecture." + extension,
+	})
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось открыть диалог сохранения архитектуры")
+		return
+	}
+	if path == "" {
+		return
+	}
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось сохранить архитектуру")
+	}
+}
+
+// LoadModel reads a model saved with SaveModel, so that it can be used by Predict without evolving
+func (a *App) LoadModel() (loadedFilename string) {
+	path, err := runtime.OpenFileDialog(a.ctx, runtime.OpenDialogOptions{
+		Title: "Укажите путь к модели",
+	})
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось открыть диалог выбора модели")
+		return ""
+	}
+	if path == "" {
+		return ""
+	}
+	a.model, a.modelClassNames, err = evolution.LoadModel(path)
+	if err != nil {
+		runtime.EventsEmit(a.ctx, "error", "Не удалось загрузить модель")
+		return ""
+	}
+	return filepath.Base(path)
+}
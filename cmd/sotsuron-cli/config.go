@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sotsuron/internal/evolution"
+	"sotsuron/internal/utils"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// evolveConfig holds everything needed to run an evolution, it can be read from a JSON file
+type evolveConfig struct {
+	Dataset        string
+	Grayscale      bool
+	TrainTestRatio float32
+	NumIndividuals int
+	NumGenerations int
+	AdvancedConfig evolution.AdvancedConfig
+	// starting architectures: template names, files or DSL strings, see loadArchitecture
+	Architectures []string
+}
+
+func defaultEvolveConfig() evolveConfig {
+	return evolveConfig{
+		Grayscale:      true,
+		TrainTestRatio: 0.8,
+		NumIndividuals: 10,
+		NumGenerations: 5,
+		AdvancedConfig: evolution.DefaultAdvancedConfig(),
+	}
+}
+
+// parseWithConfigFile parses args into fs. If configPath is set after parsing, the file is read into config
+// first and flags that were explicitly set are applied on top of it
+func parseWithConfigFile(fs *flag.FlagSet, args []string, configPath *string, config interface{}) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return nil
+	}
+	explicit := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, config); err != nil {
+		return fmt.Errorf("could not parse %s: %v", *configPath, err)
+	}
+	for name, value := range explicit {
+		if err = fs.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// advancedConfigFlags registers a flag for every field of advCfg, e.g. MaxConvOutput becomes -max-conv-output
+func advancedConfigFlags(fs *flag.FlagSet, advCfg *evolution.AdvancedConfig) {
+	v := reflect.ValueOf(advCfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fs.Var(fieldValue{v.Field(i)}, kebabCase(field.Name), fmt.Sprintf("advanced config: %s", field.Name))
+	}
+}
+
+func kebabCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteRune('-')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// fieldValue is a flag.Value backed by a struct field of a basic kind
+type fieldValue struct {
+	v reflect.Value
+}
+
+func fieldValueOf(ptr interface{}) fieldValue {
+	return fieldValue{reflect.ValueOf(ptr).Elem()}
+}
+
+func (f fieldValue) String() string {
+	if !f.v.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.v.Interface())
+}
+
+func (f fieldValue) Set(s string) error {
+	switch f.v.Kind() {
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetBool(b)
+	case reflect.String:
+		f.v.SetString(s)
+	default:
+		return fmt.Errorf("unsupported flag type %s", f.v.Kind())
+	}
+	return nil
+}
+
+func (f fieldValue) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Bool
+}
+
+// stringsValue is a flag.Value that collects every occurrence of a repeated flag
+type stringsValue []string
+
+func (s *stringsValue) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsValue) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// loadArchitecture reads an architecture given as a name of evolution.ArchitectureTemplates, a saved model,
+// a file in one of the formats (chosen by extension, DSL otherwise) or a DSL string
+func loadArchitecture(s string, inputRes utils.Resolution, channels int) (evolution.Genome, error) {
+	if template, ok := evolution.ArchitectureTemplates[s]; ok {
+		return evolution.ParseArchitecture([]byte(template), evolution.FormatDSL, inputRes, channels)
+	}
+	data, err := os.ReadFile(s)
+	if errors.Is(err, fs.ErrNotExist) {
+		return evolution.ParseArchitecture([]byte(s), evolution.FormatDSL, inputRes, channels)
+	} else if err != nil {
+		return evolution.Genome{}, err
+	}
+	format := evolution.FormatDSL
+	switch strings.ToLower(filepath.Ext(s)) {
+	case ".json":
+		if genome, err := evolution.LoadModelGenome(s); err == nil {
+			return genome, nil
+		}
+		format = evolution.FormatJSON
+	case ".yaml", ".yml":
+		format = evolution.FormatYAML
+	}
+	genome, err := evolution.ParseArchitecture(data, format, inputRes, channels)
+	if err != nil {
+		return evolution.Genome{}, fmt.Errorf("could not parse %s: %v", s, err)
+	}
+	return genome, nil
+}
@@ -0,0 +1,306 @@
+// Command sotsuron-cli runs evolutions without the GUI, e.g. on build servers.
+//
+// Usage:
+//
+//	sotsuron-cli evolve -dataset path/to/dataset [-config run.json] [-architecture lenet ...] [flags]
+//	sotsuron-cli predict -model model.json -image image.png
+//	sotsuron-cli inspect-dataset -dataset path/to/dataset [-grayscale=false]
+//	sotsuron-cli architecture -model model.json [-format dsl|json|yaml]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/aunum/log"
+	"golang.org/x/exp/rand"
+	"io"
+	"os"
+	"os/signal"
+	"sotsuron/internal/datasets"
+	"sotsuron/internal/evolution"
+	"strings"
+	"time"
+)
+
+func main() {
+	rand.Seed(uint64(time.Now().UnixNano()))
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "evolve":
+		err = evolve(os.Args[2:])
+	case "predict":
+		err = predict(os.Args[2:])
+	case "inspect-dataset":
+		err = inspectDataset(os.Args[2:])
+	case "architecture":
+		err = architecture(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: sotsuron-cli <command> [flags]
+
+Commands:
+  evolve           evolve a network on a dataset
+  predict          classify an image with a saved model
+  inspect-dataset  print dataset info
+  architecture     print architecture of a saved model
+
+Run "sotsuron-cli <command> -h" to list flags of a command.`)
+}
+
+// event is a line of the JSON lines log, Name matches the event names used by the GUI
+type event struct {
+	Time time.Time
+	Name string
+	Data interface{}
+}
+
+func evolve(args []string) error {
+	config := defaultEvolveConfig()
+	fs := flag.NewFlagSet("evolve", flag.ContinueOnError)
+	configPath := fs.String("config", "", "JSON file with evolution settings, flags override it")
+	logPath := fs.String("log", "", "file to write progress to (stdout by default, other output goes to stderr), appended to with -resume")
+	checkpointPath := fs.String("checkpoint", "", "file to write a checkpoint to after every generation")
+	resume := fs.Bool("resume", false, "resume evolution from -checkpoint")
+	modelPath := fs.String("model", "", "file to save the best model to")
+	fs.StringVar(&config.Dataset, "dataset", config.Dataset, "path to dataset (a directory per class)")
+	fs.BoolVar(&config.Grayscale, "grayscale", config.Grayscale, "load dataset as grayscale")
+	fs.Var(fieldValueOf(&config.TrainTestRatio), "train-test-ratio", "share of dataset used for training")
+	fs.IntVar(&config.NumIndividuals, "individuals", config.NumIndividuals, "population size")
+	fs.IntVar(&config.NumGenerations, "generations", config.NumGenerations, "number of generations")
+	var architectures stringsValue
+	fs.Var(&architectures, "architecture", "starting architecture: a template (lenet, mlp, small), a model saved with -model, "+
+		"a .json/.yaml/.txt file or a DSL string like conv(16,3x3,relu,p1)-pool(2x2,s2)-fc(10), may be repeated")
+	advancedConfigFlags(fs, &config.AdvancedConfig)
+	if err := parseWithConfigFile(fs, args, configPath, &config); err != nil {
+		return err
+	}
+	config.Architectures = append(config.Architectures, architectures...)
+	if config.Dataset == "" {
+		return errors.New("-dataset is required")
+	}
+	if *resume && *checkpointPath == "" {
+		return errors.New("-resume requires -checkpoint")
+	}
+
+	// the evolution package and models log human readable text to stdout, it goes to stderr so that the stream
+	// of events stays parseable. Colored logs of models are bound to the original stdout, so they are turned off
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	log.DefaultLogger.Color, log.Color = false, false
+	defer func() { os.Stdout = stdout }()
+	out := io.Writer(stdout)
+	if *logPath != "" {
+		// a resumed evolution continues the log of the previous run
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if *resume {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(*logPath, flags, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+
+	dataset, err := datasets.LoadDataset(config.Dataset, config.Grayscale)
+	if err != nil {
+		return err
+	}
+	datasetInfo := dataset.GetInfo()
+
+	var species *evolution.Species
+	advCfg := config.AdvancedConfig
+	if *resume {
+		species, advCfg, err = evolution.LoadCheckpoint(*checkpointPath)
+		if err != nil {
+			return err
+		}
+	} else {
+		channels := 3
+		if datasetInfo.Grayscale {
+			channels = 1
+		}
+		var genomes []evolution.Genome
+		for _, architecture := range config.Architectures {
+			genome, err := loadArchitecture(architecture, datasetInfo.Resolution, channels)
+			if err != nil {
+				return err
+			}
+			genomes = append(genomes, genome)
+		}
+		species, err = evolution.NewSpecies(
+			advCfg,
+			config.NumIndividuals,
+			datasetInfo.Resolution.Width,
+			datasetInfo.Resolution.Height,
+			datasetInfo.NumClasses,
+			datasetInfo.Grayscale,
+			genomes...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	if *checkpointPath != "" {
+		species.SetCheckpointPath(*checkpointPath)
+	}
+	dataset.Shuffle(species.Seed())
+	xTrain, yTrain, xTest, yTest, err := dataset.SplitTrainTest(config.TrainTestRatio)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	progressChan := make(chan evolution.Progress)
+	allChartChan := make(chan evolution.AllChartData)
+	bestChartChan := make(chan evolution.BestChartData)
+	bestStructureChan := make(chan evolution.BestStructure)
+	done := make(chan struct{})
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		emit := func(name string, data interface{}) {
+			if err := encoder.Encode(event{time.Now(), name, data}); err != nil {
+				fmt.Fprintln(os.Stderr, "WARNING: could not write log:", err.Error())
+			}
+		}
+		for {
+			select {
+			case progress := <-progressChan:
+				emit("evo-progress", progress)
+			case data := <-allChartChan:
+				emit("evo-all-chart", data)
+			case data := <-bestChartChan:
+				emit("evo-best-chart", data)
+			case best := <-bestStructureChan:
+				emit("evo-best-layers", evolution.SimplifyLayers(best.Layers))
+				emit("evo-best-hyperparameters", best.Hyperparameters)
+				if best.Niches != nil {
+					emit("evo-best-niches", best.Niches)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	err = species.Evolve(ctx, advCfg, config.NumGenerations, xTrain, yTrain, xTest, yTest, progressChan, allChartChan, bestChartChan, bestStructureChan)
+	close(done)
+	<-logged
+	if err != nil {
+		return err
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	front, err := species.ParetoFront(advCfg)
+	if err != nil {
+		return err
+	}
+	if err = encoder.Encode(event{time.Now(), "evo-pareto-front", evolution.DescribeParetoFront(front)}); err != nil {
+		return err
+	}
+	if *modelPath != "" {
+		return species.Best().SaveModel(*modelPath, dataset.ClassNames())
+	}
+	return nil
+}
+
+func predict(args []string) error {
+	fs := flag.NewFlagSet("predict", flag.ContinueOnError)
+	modelPath := fs.String("model", "", "model file saved by evolve -model")
+	imagePath := fs.String("image", "", "image to classify")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *modelPath == "" || *imagePath == "" {
+		return errors.New("-model and -image are required")
+	}
+	individual, classNames, err := evolution.LoadModel(*modelPath)
+	if err != nil {
+		return err
+	}
+	img, err := datasets.LoadImage(*imagePath, individual.IsGrayscale())
+	if err != nil {
+		return err
+	}
+	probabilities, err := individual.Predict(img, classNames)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(probabilities)
+}
+
+func inspectDataset(args []string) error {
+	fs := flag.NewFlagSet("inspect-dataset", flag.ContinueOnError)
+	path := fs.String("dataset", "", "path to dataset (a directory per class)")
+	grayscale := fs.Bool("grayscale", true, "load dataset as grayscale")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("-dataset is required")
+	}
+	dataset, err := datasets.LoadDataset(*path, *grayscale)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		*datasets.DatasetInfo
+		ClassNames []string
+	}{dataset.GetInfo(), dataset.ClassNames()})
+}
+
+func architecture(args []string) error {
+	fs := flag.NewFlagSet("architecture", flag.ContinueOnError)
+	modelPath := fs.String("model", "", "model file saved by evolve -model")
+	format := fs.String("format", evolution.FormatDSL, "output format: dsl, json or yaml")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *modelPath == "" {
+		return errors.New("-model is required")
+	}
+	genome, err := evolution.LoadModelGenome(*modelPath)
+	if err != nil {
+		return err
+	}
+	data, err := genome.MarshalArchitecture(*format)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Println(strings.TrimRight(string(data), "\n"))
+	return err
+}
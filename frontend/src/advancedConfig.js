@@ -2,7 +2,19 @@ window.resetAdvancedConfig = function() {
     document.querySelector("#config-train-test-ratio").value = 0.8;
     document.querySelector("#config-epochs").value = 5;
     document.querySelector("#config-batch-size").value = 10;
+    document.querySelector("#config-max-concurrency").value = 0;
+    document.querySelector("#config-seed").value = 0;
     document.querySelector("#config-mutation-multiplier").value = 1.0;
+    document.querySelector("#config-delete-block-chance").value = 0.2;
+    document.querySelector("#config-insert-block-chance").value = 0.2;
+    document.querySelector("#config-kernel-mutation-chance").value = 0.4;
+    document.querySelector("#config-stride-mutation-chance").value = 0.2;
+    document.querySelector("#config-pad-mutation-chance").value = 0.3;
+    document.querySelector("#config-filters-mutation-chance").value = 0.5;
+    document.querySelector("#config-activation-mutation-chance").value = 0.3;
+    document.querySelector("#config-dense-size-mutation-chance").value = 0.5;
+    document.querySelector("#config-dropout-mutation-chance").value = 0.2;
+    document.querySelector("#config-batch-norm-mutation-chance").value = 0.1;
     document.querySelector("#config-max-conv-max-pooling-pairs").value = 3;
     document.querySelector("#config-max-conv-output").value = 16;
     document.querySelector("#config-max-conv-kernel-size").value = 8;
@@ -15,14 +27,60 @@ window.resetAdvancedConfig = function() {
     document.querySelector("#config-max-dense-size").value = 128;
     document.querySelector("#config-min-resolution-width").value = 16;
     document.querySelector("#config-min-resolution-height").value = 16;
+    document.querySelector("#config-avg-pooling-chance").value = 0.3;
+    document.querySelector("#config-batch-norm-chance").value = 0.2;
+    document.querySelector("#config-dropout-chance").value = 0.3;
+    document.querySelector("#config-min-dropout").value = 0.1;
+    document.querySelector("#config-max-dropout").value = 0.5;
+    document.querySelector("#config-skip-chance").value = 0;
+    document.querySelector("#config-evolve-hyperparameters").checked = false;
+    document.querySelector("#config-optimizers").value = "sgd,adam,rmsprop";
+    document.querySelector("#config-min-learning-rate").value = 0.0001;
+    document.querySelector("#config-max-learning-rate").value = 0.01;
+    document.querySelector("#config-min-batch-size").value = 8;
+    document.querySelector("#config-max-batch-size").value = 64;
+    document.querySelector("#config-max-l2").value = 0.001;
+    document.querySelector("#config-min-epochs").value = 1;
+    document.querySelector("#config-max-epochs").value = 10;
+    document.querySelector("#config-inherit-weights").checked = false;
+    document.querySelector("#config-inherited-epochs").value = 2;
+    document.querySelector("#config-fitness-cache").checked = true;
+    document.querySelector("#config-cache-evaluations").value = 1;
+    document.querySelector("#config-early-stopping-patience").value = 0;
+    document.querySelector("#config-early-stopping-min-delta").value = 0.01;
+    document.querySelector("#config-halving-rungs").value = 1;
+    document.querySelector("#config-halving-keep-ratio").value = 0.5;
+    document.querySelector("#config-strategy").value = "generational";
+    document.querySelector("#config-grid-depths").value = "1,2,3";
+    document.querySelector("#config-grid-widths").value = "4,8,16";
+    document.querySelector("#config-islands").value = 1;
+    document.querySelector("#config-migration-interval").value = 5;
+    document.querySelector("#config-migration-rate").value = 0.1;
+    document.querySelector("#config-migration-topology").value = "ring";
+    document.querySelector("#config-speciation").checked = false;
+    document.querySelector("#config-compatibility-threshold").value = 0.3;
+    document.querySelector("#config-stagnation-limit").value = 15;
 }
 
 export function getAdvancedConfig() {
     return {
+        Seed: parseInt(document.querySelector("#config-seed").value),
+
         Epochs: parseInt(document.querySelector("#config-epochs").value),
         BatchSize: parseInt(document.querySelector("#config-batch-size").value),
+        MaxConcurrency: parseInt(document.querySelector("#config-max-concurrency").value),
 
         MutationMultiplier: parseFloat(document.querySelector("#config-mutation-multiplier").value),
+        DeleteBlockChance: parseFloat(document.querySelector("#config-delete-block-chance").value),
+        InsertBlockChance: parseFloat(document.querySelector("#config-insert-block-chance").value),
+        KernelMutationChance: parseFloat(document.querySelector("#config-kernel-mutation-chance").value),
+        StrideMutationChance: parseFloat(document.querySelector("#config-stride-mutation-chance").value),
+        PadMutationChance: parseFloat(document.querySelector("#config-pad-mutation-chance").value),
+        FiltersMutationChance: parseFloat(document.querySelector("#config-filters-mutation-chance").value),
+        ActivationMutationChance: parseFloat(document.querySelector("#config-activation-mutation-chance").value),
+        DenseSizeMutationChance: parseFloat(document.querySelector("#config-dense-size-mutation-chance").value),
+        DropoutMutationChance: parseFloat(document.querySelector("#config-dropout-mutation-chance").value),
+        BatchNormMutationChance: parseFloat(document.querySelector("#config-batch-norm-mutation-chance").value),
 
         MaxConvMaxPoolingPairs: parseInt(document.querySelector("#config-max-conv-max-pooling-pairs").value),
         MaxConvOutput: parseInt(document.querySelector("#config-max-conv-output").value),
@@ -36,5 +94,42 @@ export function getAdvancedConfig() {
         MaxDenseSize: parseInt(document.querySelector("#config-max-dense-size").value),
         MinResolutionWidth: parseInt(document.querySelector("#config-min-resolution-width").value),
         MinResolutionHeight: parseInt(document.querySelector("#config-min-resolution-height").value),
+
+        AvgPoolingChance: parseFloat(document.querySelector("#config-avg-pooling-chance").value),
+        BatchNormChance: parseFloat(document.querySelector("#config-batch-norm-chance").value),
+        DropoutChance: parseFloat(document.querySelector("#config-dropout-chance").value),
+        MinDropout: parseFloat(document.querySelector("#config-min-dropout").value),
+        MaxDropout: parseFloat(document.querySelector("#config-max-dropout").value),
+
+        SkipChance: parseFloat(document.querySelector("#config-skip-chance").value),
+
+        EvolveHyperparameters: document.querySelector("#config-evolve-hyperparameters").checked,
+        Optimizers: document.querySelector("#config-optimizers").value,
+        MinLearningRate: parseFloat(document.querySelector("#config-min-learning-rate").value),
+        MaxLearningRate: parseFloat(document.querySelector("#config-max-learning-rate").value),
+        MinBatchSize: parseInt(document.querySelector("#config-min-batch-size").value),
+        MaxBatchSize: parseInt(document.querySelector("#config-max-batch-size").value),
+        MaxL2: parseFloat(document.querySelector("#config-max-l2").value),
+        MinEpochs: parseInt(document.querySelector("#config-min-epochs").value),
+        MaxEpochs: parseInt(document.querySelector("#config-max-epochs").value),
+
+        InheritWeights: document.querySelector("#config-inherit-weights").checked,
+        InheritedEpochs: parseInt(document.querySelector("#config-inherited-epochs").value),
+        FitnessCache: document.querySelector("#config-fitness-cache").checked,
+        CacheEvaluations: parseInt(document.querySelector("#config-cache-evaluations").value),
+        EarlyStoppingPatience: parseInt(document.querySelector("#config-early-stopping-patience").value),
+        EarlyStoppingMinDelta: parseFloat(document.querySelector("#config-early-stopping-min-delta").value),
+        HalvingRungs: parseInt(document.querySelector("#config-halving-rungs").value),
+        HalvingKeepRatio: parseFloat(document.querySelector("#config-halving-keep-ratio").value),
+        Strategy: document.querySelector("#config-strategy").value.trim(),
+        GridDepths: document.querySelector("#config-grid-depths").value,
+        GridWidths: document.querySelector("#config-grid-widths").value,
+        Islands: parseInt(document.querySelector("#config-islands").value),
+        MigrationInterval: parseInt(document.querySelector("#config-migration-interval").value),
+        MigrationRate: parseFloat(document.querySelector("#config-migration-rate").value),
+        MigrationTopology: document.querySelector("#config-migration-topology").value.trim(),
+        Speciation: document.querySelector("#config-speciation").checked,
+        CompatibilityThreshold: parseFloat(document.querySelector("#config-compatibility-threshold").value),
+        StagnationLimit: parseInt(document.querySelector("#config-stagnation-limit").value),
     }
 }
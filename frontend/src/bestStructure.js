@@ -9,7 +9,9 @@ const zFar = 1000.0;
 
 
 let models;
+let hyperparameters;
 let currentModelIndex;
+let currentModel = null; // shown layers, of models[currentModelIndex] or of the best individual of a niche
 
 let buffers;
 let vertexCount = 0;
@@ -21,6 +23,8 @@ const gap = 0.2;
 let maxFCOutput = 0;
 const fcHeight = 1;
 const fcMaxWidth = 20;
+// BatchNorm, Dropout and SkipMerge are drawn as thin bars as wide as the layer they follow
+const thinHeight = 0.3;
 
 let zoom = 1.0;
 let mouseDown = false;
@@ -65,17 +69,22 @@ const fsSource = `
 
 export function setCurrentModel(index) {
     currentModelIndex = index;
+    showModel(models[index], hyperparameters[index]);
+}
+
+// showModel draws the layers and lists them in the table
+function showModel(model, h) {
+    currentModel = model;
     initBuffers();
 
     const table = document.querySelector("#best-structure-table");
     table.innerHTML = "";
-    const model = models[index];
     for (let i = 0; i < model.length; i++) {
         const row = document.createElement("tr");
         const layerNumber = document.createElement("td");
         layerNumber.innerText = i.toString();
         const type = document.createElement("td");
-        type.innerText = model[i].Type;
+        type.innerText = describeLayerType(model[i]);
         const filter = document.createElement("td");
         filter.innerText = model[i].Width ? model[i].Width + "x" + model[i].Height : "-";
         const pad = document.createElement("td");
@@ -92,6 +101,28 @@ export function setCurrentModel(index) {
         row.append(layerNumber, type, filter, pad, stride, input, output, activation);
         table.append(row);
     }
+    showHyperparameters(h);
+}
+
+function showHyperparameters(h) {
+    const block = document.querySelector("#best-hyperparameters");
+    if (!h) {
+        block.innerText = "";
+        return;
+    }
+    block.innerText = `Оптимизатор: ${h.Optimizer}, скорость обучения: ${h.LearningRate}, ` +
+        `размер батча: ${h.BatchSize}, L2: ${h.L2 ?? 0}, эпох: ${h.Epochs}`;
+}
+
+function describeLayerType(layer) {
+    switch (layer.Type) {
+        case "Dropout":
+            return `Dropout (${layer.Probability})`;
+        case "SkipMerge":
+            return `SkipMerge (${layer.Mode}@${layer.From === -1 ? "in" : layer.From})`;
+        default:
+            return layer.Type;
+    }
 }
 
 function initShaderProgram(vsSource, fsSource) {
@@ -132,11 +163,16 @@ function loadShader(type, source) {
 }
 
 
-function getLayerX0X1Y0Y1(layer, lastY) {
+function getLayerX0X1Y0Y1(layer, lastY, prevLayer) {
     switch (layer.Type) {
         case "Conv2D":
         case "MaxPooling2D":
+        case "AvgPooling2D":
             return [-layer.Width/2, layer.Width/2, lastY, lastY-layer.Height];
+        case "BatchNorm":
+        case "Dropout":
+        case "SkipMerge":
+            return [prevLayer?.x0 ?? -0.5, prevLayer?.x1 ?? 0.5, lastY, lastY-thinHeight];
         case "FC":
             return [(-layer.Output/maxFCOutput)*(fcMaxWidth/2), (layer.Output/maxFCOutput)*(fcMaxWidth/2), lastY, lastY-fcHeight];
     }
@@ -149,16 +185,24 @@ function getLayerColor(layer) {
             return [].concat(...Array(6).fill([b*0.65625, b*0.2734375, b*0.625, 1.0]));
         case "MaxPooling2D":
             return [].concat(...Array(6).fill([b*0.13671875, b*0.8046875, b*0.41796875, 1.0]));
+        case "AvgPooling2D":
+            return [].concat(...Array(6).fill([b*0.13671875, b*0.6, b*0.8046875, 1.0]));
+        case "BatchNorm":
+            return [].concat(...Array(6).fill([b*0.9, b*0.7, b*0.2, 1.0]));
+        case "Dropout":
+            return [].concat(...Array(6).fill([b*0.8, b*0.3, b*0.3, 1.0]));
+        case "SkipMerge":
+            return [].concat(...Array(6).fill([b*0.2, b*0.5, b*0.9, 1.0]));
         case "FC":
             return [].concat(...Array(6).fill([b*0.4, b*0.4, b*0.4, 1.0]));
     }
 }
 
 function initBuffers() {
-    if (currentModelIndex === -1) {
+    if (currentModel == null) {
         return;
     }
-    const model = models[currentModelIndex];
+    const model = currentModel;
 
     // find max FC width and set it as width
     maxFCOutput = 0;
@@ -167,7 +211,7 @@ function initBuffers() {
             if (layer.Output > maxFCOutput) {
                 maxFCOutput = layer.Output;
             }
-        } else {
+        } else if (layer.Type !== "Dropout") {
             break;
         }
     }
@@ -176,15 +220,17 @@ function initBuffers() {
     const positions = [], colors = [];
     let lastY = 0;
     let x0, x1, y0, y1
+    let prevLayer;
     for (let layer of model) {
-        [x0, x1, y0, y1] = getLayerX0X1Y0Y1(layer, lastY);
+        [x0, x1, y0, y1] = getLayerX0X1Y0Y1(layer, lastY, prevLayer);
+        prevLayer = layer;
         layer.x0 = x0;
         layer.x1 = x1;
         layer.y0 = y0;
         layer.y1 = y1;
         positions.push(x1, y0, x0, y0, x1, y1, x1, y1, x0, y0, x0, y1);
         colors.push(...getLayerColor(layer));
-        lastY -= (layer.Type === "FC" ? fcHeight : layer.Height) + gap;
+        lastY -= y0 - y1 + gap;
         vertexCount += 6;
     }
     height = -lastY - gap;
@@ -250,7 +296,7 @@ function render(programInfo, buffers) {
 
     gl.clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT);
 
-    if (currentModelIndex === -1) {
+    if (currentModel == null) {
         return;
     }
 
@@ -311,7 +357,10 @@ export async function initBestStructureBlock() {
     window.bestStructureTable = document.querySelector("#best-structure-table");
 
     models = [];
+    hyperparameters = [];
     currentModelIndex = -1;
+    currentModel = null;
+    document.querySelector("#best-niches").innerHTML = "";
 
     window.visualizationCanvas.width = window.visualizationCanvas.clientWidth;
     window.visualizationCanvas.height = window.visualizationCanvas.clientHeight;
@@ -344,7 +393,7 @@ export async function initBestStructureBlock() {
     initBuffers();
 
     window.visualizationCanvas.addEventListener("mousemove", (e) => {
-        if (currentModelIndex === -1) {
+        if (currentModel == null) {
             return;
         }
         const rect = window.visualizationCanvas.getBoundingClientRect();
@@ -355,7 +404,7 @@ export async function initBestStructureBlock() {
         const max_new = -(0.0 - (-translation[2] - heightCursed) / 2);
         y = ((max_new - min_new) * (y - -1.0) / (0.0 - (-1.0)) + min_new) - spanY;
 
-        const layers = models[currentModelIndex];
+        const layers = currentModel;
         for (let i = 0; i < layers.length; i++) {
             if (x >= layers[i].x0 && x <= layers[i].x1 && y <= layers[i].y0 && y >= layers[i].y1) {
                 layers[i].hovered = true;
@@ -393,10 +442,10 @@ export async function initBestStructureBlock() {
     });
 
     window.visualizationCanvas.addEventListener("mouseleave", (e) => {
-        if (currentModelIndex === -1) {
+        if (currentModel == null) {
             return;
         }
-        for (const layer of models[currentModelIndex]) {
+        for (const layer of currentModel) {
             if (layer.hovered) {
                 layer.hovered = false;
                 initBuffers();
@@ -417,4 +466,26 @@ export async function initBestStructureBlock() {
 export function pushBestLayers(bestLayers) {
     models.push(bestLayers)
     setCurrentModel(models.length - 1);
-}
\ No newline at end of file
+}
+
+// pushBestHyperparameters attaches hyperparameters to the model pushed last
+export function pushBestHyperparameters(bestHyperparameters) {
+    hyperparameters[models.length - 1] = bestHyperparameters;
+    if (currentModelIndex === models.length - 1) {
+        showHyperparameters(bestHyperparameters);
+    }
+}
+// setNicheBests lists the best individuals of niches, clicking one shows its structure
+export function setNicheBests(nicheBests) {
+    const block = document.querySelector("#best-niches");
+    block.innerHTML = "";
+    for (const niche of nicheBests) {
+        const button = document.createElement("button");
+        button.type = "button";
+        button.className = "btn btn-sm btn-outline-secondary me-1 mt-1";
+        button.innerText = `Ниша ${niche.ID}: ${niche.Fitness.toFixed(3)} (${niche.Size})`;
+        button.title = `Поколений без улучшения: ${niche.Stagnation}`;
+        button.onclick = () => showModel(niche.Layers, niche.Hyperparameters);
+        block.append(button);
+    }
+}
@@ -49,13 +49,7 @@ export function initBestChart(generations=10) {
             type: "line",
             data: {
                 labels: Array.from({length: generations}, (_, i) => i + 1),
-                datasets: [
-                    {
-                        data: [],
-                        pointRadius: 4,
-                        pointHoverRadius: 7,
-                    }
-                ],
+                datasets: [],
                 tooltipEvents: ["click"],
             },
             options: {
@@ -90,13 +84,18 @@ export function initBestChart(generations=10) {
     );
 }
 
-export function updateAllChart(data) {
+const islandColors = ["#0d6efd", "#dc3545", "#198754", "#fd7e14", "#6f42c1", "#20c997", "#d63384", "#6c757d"];
+
+export function updateAllChart(data, islands=1) {
     let i = window.allChart.data.datasets.findIndex(ds => ds.label === data.Name)
     if (i === -1) {
         window.allChart.data.datasets.push({
             label: data.Name,
             data: [data.Accuracy],
-            borderColor: "#"+Math.floor(Math.random()*16777215).toString(16),
+            // individuals of the same island share a colour
+            borderColor: islands > 1
+                ? islandColors[data.Island % islandColors.length]
+                : "#"+Math.floor(Math.random()*16777215).toString(16),
             tension: 0.1,
             pointRadius: 1,
         });
@@ -106,7 +105,20 @@ export function updateAllChart(data) {
     window.allChart.update("none");
 }
 
-export function updateBestChart(accuracy) {
-    window.bestChart.data.datasets[0].data.push(accuracy);
+export function updateBestChart(data) {
+    let datasets = window.bestChart.data.datasets;
+    while (datasets.length <= data.Island) {
+        datasets.push({
+            label: `Остров ${datasets.length+1}`,
+            data: [],
+            borderColor: islandColors[datasets.length % islandColors.length],
+            backgroundColor: islandColors[datasets.length % islandColors.length],
+            pointRadius: 4,
+            pointHoverRadius: 7,
+        });
+    }
+    // a generation may be reported again when evolution resumes, the latest value wins
+    datasets[data.Island].data[data.Generation] = data.Fitness;
+    window.bestChart.options.plugins.legend.display = datasets.length > 1;
     window.bestChart.update("none");
 }
\ No newline at end of file
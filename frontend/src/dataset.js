@@ -14,4 +14,5 @@ window.loadDataset = async function(grayscale) {
     document.querySelector("#dataset-num-channels").value = grayscale ? 1 : 3;
 
     document.querySelector("#evo-start-button").disabled = false;
+    document.querySelector("#evo-resume-button").disabled = false;
 };
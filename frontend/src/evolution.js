@@ -1,40 +1,64 @@
 import {EventsEmit, EventsOff, EventsOn, LogDebug} from "../wailsjs/runtime";
-import {Evolve} from "../wailsjs/go/main/App";
+import {BestArchitecture, DefaultAdvancedConfig, Evolve, Resume} from "../wailsjs/go/main/App";
 import {initAllChart, initBestChart, updateAllChart, updateBestChart} from "./charts";
 import {getAdvancedConfig} from "./advancedConfig";
-import {initBestStructureBlock, pushBestLayers} from "./bestStructure";
+import {initBestStructureBlock, pushBestHyperparameters, pushBestLayers, setNicheBests} from "./bestStructure";
 
-window.evolve = function() {
+window.evolve = async function() {
+    await runEvolution(false);
+}
+
+window.resumeEvolution = async function() {
+    await runEvolution(true);
+}
+
+async function runEvolution(resume) {
     let trainTestRatio = parseFloat(document.querySelector("#config-train-test-ratio").value);
     let numIndividuals = parseInt(document.querySelector("#config-num-individuals").value);
     let numGenerations = parseInt(document.querySelector("#config-num-generations").value);
     LogDebug(numGenerations.toString());
-    let advCfg= getAdvancedConfig();
+    let advCfg = {...await DefaultAdvancedConfig(), ...getAdvancedConfig()};
+    let architectures = document.querySelector("#config-architectures").value
+        .split("\n")
+        .map(line => line.trim())
+        .filter(line => line !== "");
 
     let progressBar = document.querySelector("#evo-progress-bar");
     let progressBarFill = document.querySelector("#evo-progress-bar-fill");
     let progressStatus = document.querySelector("#evo-progress-status");
     let progressETA = document.querySelector("#evo-progress-eta");
     let startButton = document.querySelector("#evo-start-button");
+    let resumeButton = document.querySelector("#evo-resume-button");
     let cancelButton = document.querySelector("#evo-cancel-button");
     startButton.classList.add("visually-hidden");
+    resumeButton.classList.add("visually-hidden");
     cancelButton.classList.remove("visually-hidden");
     progressStatus.innerHTML = "Подготовка...";
     progressStatus.classList.remove("visually-hidden");
     progressBar.classList.remove("visually-hidden");
 
-    EventsOff("evo-progress", "evo-all-chart", "evo-best-chart", "evo-best-layers");
+    EventsOff("evo-progress", "evo-all-chart", "evo-best-chart", "evo-best-layers", "evo-best-hyperparameters", "evo-best-niches");
 
     EventsOn("evo-progress", (progress) => {
         if (progress.Generation === -1) {
             startButton.classList.remove("visually-hidden");
+            resumeButton.classList.remove("visually-hidden");
             cancelButton.classList.add("visually-hidden");
             progressBar.classList.add("visually-hidden");
             progressBarFill.style.width = "0%";
             progressStatus.innerHTML = window.isAborting ? "Прервано" : "Завершено";
             progressETA.innerHTML = "";
             if (window.isAborting) {
-                window.isAborting = false;
+                window.addBestArchitecture = async function() {
+    let architecture = await BestArchitecture();
+    if (!architecture) {
+        return;
+    }
+    let textarea = document.querySelector("#config-architectures");
+    textarea.value = textarea.value.trim() === "" ? architecture : `${textarea.value.trimEnd()}\n${architecture}`;
+}
+
+window.isAborting = false;
             }
 
             LogDebug("Evolution finished (frontend)");
@@ -46,11 +70,26 @@ window.evolve = function() {
         progressBarFill.style.width = progress.Individual / (numIndividuals * numGenerations) * 100 + "%";
 
         progressStatus.innerHTML = `Поколение ${progress.Generation+1} из ${numGenerations}`
+        if (advCfg.HalvingRungs > 1) {
+            progressStatus.innerHTML += `, этап ${progress.Rung+1} из ${advCfg.HalvingRungs}`;
+        }
         let eta = "";
         if (progress.ETASeconds > 1) {
             let minutes = Math.ceil(progress.ETASeconds / 60);
             eta = `Осталось ~ ${minutes} мин.`;
         }
+        if (progress.CacheHitRate > 0) {
+            eta += ` Из кэша: ${Math.round(progress.CacheHitRate * 100)}%`;
+        }
+        if (progress.Dropped > 0) {
+            eta += ` Отсеяно: ${progress.Dropped}`;
+        }
+        if (progress.StoppedEarly > 0) {
+            eta += ` Остановлено досрочно: ${progress.StoppedEarly}`;
+        }
+        if (progress.Niches > 0) {
+            eta += ` Ниш: ${progress.Niches}`;
+        }
         progressETA.innerHTML = eta;
 
         if (progress.Generation > window.currentGeneration) {
@@ -60,19 +99,38 @@ window.evolve = function() {
     });
 
     EventsOn("evo-all-chart", allChartData => {
-        updateAllChart(allChartData);
+        updateAllChart(allChartData, advCfg.Islands);
     });
-    EventsOn("evo-best-chart", bestAccuracy => {
-        updateBestChart(bestAccuracy);
+    EventsOn("evo-best-chart", bestChartData => {
+        updateBestChart(bestChartData);
     });
     EventsOn("evo-best-layers", bestLayers => {
         pushBestLayers(bestLayers);
     });
-    initAllChart(advCfg.Epochs);
+    EventsOn("evo-best-hyperparameters", bestHyperparameters => {
+        pushBestHyperparameters(bestHyperparameters);
+    });
+    EventsOn("evo-best-niches", nicheBests => {
+        setNicheBests(nicheBests);
+    });
+    initAllChart(advCfg.EvolveHyperparameters ? advCfg.MaxEpochs : advCfg.Epochs);
     initBestChart(numGenerations);
     initBestStructureBlock();
 
-    Evolve(advCfg, trainTestRatio, numIndividuals, numGenerations).then(() => {});
+    if (resume) {
+        Resume(trainTestRatio, numGenerations).then(() => {});
+    } else {
+        Evolve(advCfg, trainTestRatio, numIndividuals, numGenerations, architectures).then(() => {});
+    }
+}
+
+window.addBestArchitecture = async function() {
+    let architecture = await BestArchitecture();
+    if (!architecture) {
+        return;
+    }
+    let textarea = document.querySelector("#config-architectures");
+    textarea.value = textarea.value.trim() === "" ? architecture : `${textarea.value.trimEnd()}\n${architecture}`;
 }
 
 window.isAborting = false;
@@ -1,4 +1,4 @@
-import {LoadImage, Predict} from "../wailsjs/go/main/App";
+import {LoadImage, LoadModel, Predict, SaveArchitecture, SaveModel} from "../wailsjs/go/main/App";
 
 window.toyTest = async function() {
     let loadedFilename = await LoadImage();
@@ -7,6 +7,9 @@ window.toyTest = async function() {
     }
     document.querySelector("#toy-test-file-name").value = loadedFilename;
     let probabilities = await Predict()
+    if (!probabilities) {
+        return;
+    }
     document.querySelector("#toy-test-predicted-class").value = probabilities[0].ClassName;
     let probabilitiesContainer = document.querySelector("#toy-test-probabilities");
     probabilitiesContainer.innerHTML = "";
@@ -23,3 +26,19 @@ window.toyTest = async function() {
         `;
     }
 }
+
+window.saveModel = async function() {
+    await SaveModel();
+}
+
+window.saveArchitecture = async function(format) {
+    await SaveArchitecture(format);
+}
+
+window.loadModel = async function() {
+    let loadedFilename = await LoadModel();
+    if (!loadedFilename) {
+        return;
+    }
+    document.querySelector("#toy-test-file-name").value = loadedFilename;
+}
@@ -3,10 +3,24 @@
 import {evolution} from '../models';
 import {datasets} from '../models';
 
-export function Evolve(arg1:evolution.AdvancedConfig,arg2:number,arg3:number,arg4:number):Promise<void>;
+export function BestArchitecture():Promise<string>;
+
+export function DefaultAdvancedConfig():Promise<evolution.AdvancedConfig>;
+
+export function Evolve(arg1:evolution.AdvancedConfig,arg2:number,arg3:number,arg4:number,arg5:Array<string>):Promise<void>;
 
 export function LoadDataset(arg1:boolean):Promise<datasets.DatasetInfo>;
 
 export function LoadImage():Promise<string>;
 
+export function LoadModel():Promise<string>;
+
+export function ParetoFront():Promise<Array<evolution.ParetoPoint>>;
+
 export function Predict():Promise<Array<evolution.ClassProbability>>;
+
+export function Resume(arg1:number,arg2:number):Promise<void>;
+
+export function SaveArchitecture(arg1:string):Promise<void>;
+
+export function SaveModel():Promise<void>;
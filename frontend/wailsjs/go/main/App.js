@@ -2,8 +2,16 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function Evolve(arg1, arg2, arg3, arg4) {
-  return window['go']['main']['App']['Evolve'](arg1, arg2, arg3, arg4);
+export function BestArchitecture() {
+  return window['go']['main']['App']['BestArchitecture']();
+}
+
+export function DefaultAdvancedConfig() {
+  return window['go']['main']['App']['DefaultAdvancedConfig']();
+}
+
+export function Evolve(arg1, arg2, arg3, arg4, arg5) {
+  return window['go']['main']['App']['Evolve'](arg1, arg2, arg3, arg4, arg5);
 }
 
 export function LoadDataset(arg1) {
@@ -14,6 +22,26 @@ export function LoadImage() {
   return window['go']['main']['App']['LoadImage']();
 }
 
+export function LoadModel() {
+  return window['go']['main']['App']['LoadModel']();
+}
+
+export function ParetoFront() {
+  return window['go']['main']['App']['ParetoFront']();
+}
+
 export function Predict() {
   return window['go']['main']['App']['Predict']();
 }
+
+export function Resume(arg1, arg2) {
+  return window['go']['main']['App']['Resume'](arg1, arg2);
+}
+
+export function SaveArchitecture(arg1) {
+  return window['go']['main']['App']['SaveArchitecture'](arg1);
+}
+
+export function SaveModel() {
+  return window['go']['main']['App']['SaveModel']();
+}
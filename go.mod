@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/aunum/log v0.0.0-20200821225356-38d2e2c8b489
+	github.com/ghodss/yaml v1.0.0
 	github.com/google/uuid v1.3.0
 	github.com/m8u/gold v0.1.1
 	github.com/m8u/gorgonia v0.10.1
@@ -26,7 +27,6 @@ require (
 	github.com/chewxy/hm v1.0.0 // indirect
 	github.com/chewxy/math32 v1.10.1 // indirect
 	github.com/fatih/color v1.15.0 // indirect
-	github.com/ghodss/yaml v1.0.0 // indirect
 	github.com/go-fonts/liberation v0.3.1 // indirect
 	github.com/go-latex/latex v0.0.0-20230307184459-12ec69307ad9 // indirect
 	github.com/go-ole/go-ole v1.2.6 // indirect
@@ -20,6 +20,7 @@ type Dataset struct {
 	path       string
 	x, y       tensor.Tensor
 	classNames []string
+	order      []int // order[i] is the index of the i-th example in the order it has been loaded in
 }
 
 func (dataset *Dataset) ClassNames() []string {
@@ -85,12 +86,6 @@ func LoadDataset(path string, grayscale bool) (dataset *Dataset, err error) { //
 		}
 	}
 
-	// shuffle
-	rand.Shuffle(len(images), func(i, j int) {
-		images[i], images[j] = images[j], images[i]
-		labels[i], labels[j] = labels[j], labels[i]
-	})
-
 	// prepare buffers
 	var xBacking, yBacking []float32
 	for i := 0; i < len(images); i++ { // TODO: we can use LoadImage and concat resulting tensors into one
@@ -117,11 +112,47 @@ func LoadDataset(path string, grayscale bool) (dataset *Dataset, err error) { //
 	// create tensors from buffers
 	dataset.x = tensor.New(tensor.WithShape(rows, channels, sampleHeight, sampleWidth), tensor.WithBacking(xBacking))
 	dataset.y = tensor.New(tensor.WithShape(rows, len(classes)), tensor.WithBacking(yBacking))
+	dataset.order = make([]int, rows)
+	for i := range dataset.order {
+		dataset.order[i] = i
+	}
+	dataset.Shuffle(rand.Uint64())
 
 	log.Println("load complete!", time.Since(start))
 	return
 }
 
+// Shuffle puts examples in an order that only depends on seed, so that SplitTrainTest gives the same split
+// for the same seed no matter how the dataset has been shuffled before
+func (dataset *Dataset) Shuffle(seed uint64) {
+	rows := len(dataset.order)
+	order := make([]int, rows)
+	for i := range order {
+		order[i] = i
+	}
+	rand.New(rand.NewSource(seed)).Shuffle(rows, func(i, j int) {
+		order[i], order[j] = order[j], order[i]
+	})
+
+	position := make([]int, rows) // where each loaded example currently is
+	for i, loaded := range dataset.order {
+		position[loaded] = i
+	}
+	dataset.x = shuffleRows(dataset.x, order, position)
+	dataset.y = shuffleRows(dataset.y, order, position)
+	dataset.order = order
+}
+
+func shuffleRows(t tensor.Tensor, order, position []int) tensor.Tensor {
+	data := t.Data().([]float32)
+	rowSize := len(data) / len(order)
+	backing := make([]float32, 0, len(data))
+	for _, loaded := range order {
+		backing = append(backing, data[position[loaded]*rowSize:(position[loaded]+1)*rowSize]...)
+	}
+	return tensor.New(tensor.WithShape(t.Shape().Clone()...), tensor.WithBacking(backing))
+}
+
 func (dataset *Dataset) SplitTrainTest(ratio float32) (xTrain, yTrain, xTest, yTest tensor.Tensor, err error) {
 	rows := dataset.x.Shape()[0]
 	//fmt.Printf(
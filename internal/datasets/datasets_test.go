@@ -88,3 +88,34 @@ func TestDataset_SplitTrainTest(t *testing.T) {
 		})
 	}
 }
+
+func TestDataset_Shuffle(t *testing.T) {
+	newDataset := func() *Dataset {
+		return &Dataset{
+			x: tensor.New(tensor.WithShape(5, 1, 1, 2), tensor.WithBacking([]float32{
+				0, 0,
+				1, 1,
+				2, 2,
+				3, 3,
+				4, 4,
+			})),
+			y:     tensor.New(tensor.WithShape(5, 1), tensor.WithBacking([]float32{0, 1, 2, 3, 4})),
+			order: []int{0, 1, 2, 3, 4},
+		}
+	}
+	dataset := newDataset()
+	dataset.Shuffle(42)
+	want := dataset.y.Data().([]float32)
+	for i, label := range want {
+		if x := dataset.x.Data().([]float32)[i*2 : i*2+2]; x[0] != label || x[1] != label {
+			t.Errorf("Shuffle() moved example %v away from its label %v", x, label)
+		}
+	}
+
+	reshuffled := newDataset()
+	reshuffled.Shuffle(7)
+	reshuffled.Shuffle(42)
+	if got := reshuffled.y.Data().([]float32); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shuffle() after another Shuffle() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,280 @@
+package evolution
+
+import (
+	"context"
+	"fmt"
+	"gorgonia.org/tensor"
+	"sync"
+	"time"
+)
+
+const (
+	StrategyGenerational = "generational"
+	StrategyAging        = "aging"
+	StrategyRandom       = "random"
+	StrategyGrid         = "grid"
+)
+
+type UnknownStrategyError struct {
+	strategy string
+}
+
+func (err UnknownStrategyError) Error() string {
+	return fmt.Sprintf("unknown evolution strategy %q", err.strategy)
+}
+
+func validateStrategy(strategy string) error {
+	switch strategy {
+	case "", StrategyGenerational, StrategyAging, StrategyRandom, StrategyGrid:
+		return nil
+	default:
+		return UnknownStrategyError{strategy}
+	}
+}
+
+// evaluate trains the individual for its whole epoch budget, unless the cache already knows its fitness,
+// and tells whether it has survived
+func (species *Species) evaluate(
+	ctx context.Context, advCfg AdvancedConfig, individual *Individual, allChartChan chan AllChartData,
+	xTrain, yTrain, xTest, yTest tensor.Tensor) bool {
+
+	if species.cache.reuse(advCfg, individual) {
+		return true
+	}
+	fitness, err := individual.CalculateFitnessBatch(ctx, allChartChan, advCfg, xTrain, yTrain, xTest, yTest)
+	if err != nil {
+		fmt.Println(individual.name, "has died")
+		individual.trained = false
+		return false
+	}
+	individual.trained, individual.fitness = true, fitness
+	species.cache.store(advCfg, individual)
+	return true
+}
+
+// numberLives makes individuals die of old age in the order they are in, unless they already have their lives
+// numbered, e.g. if they are restored from a checkpoint
+func (species *Species) numberLives() {
+	for _, individual := range species.individuals {
+		if individual.lives != 1 {
+			return
+		}
+	}
+	for i, individual := range species.individuals {
+		individual.lives = i + 1
+	}
+}
+
+// addNewborn puts a trained child into the population. If the population is full, everyone else loses a life
+// and the individual that is out of lives, the oldest one, dies
+func (species *Species) addNewborn(child *Individual) {
+	if len(species.individuals) >= species.targetNumIndividuals {
+		var survivors []*Individual
+		for _, individual := range species.individuals {
+			individual.lives--
+			if individual.lives <= 0 {
+				fmt.Println(individual.name, "has died of old age")
+				if individual.borrowers == 0 {
+					individual.DisposeVMs()
+				}
+				continue
+			}
+			survivors = append(survivors, individual)
+		}
+		species.individuals = survivors
+	}
+	child.lives = species.targetNumIndividuals
+	species.individuals = append(species.individuals, child)
+}
+
+// borrow keeps a parent from being disposed while a child is bred from it outside the lock of the population
+func (individual *Individual) borrow() {
+	individual.borrowers++
+}
+
+// giveBack disposes a parent that has died of old age while it was borrowed, once no child is bred from it anymore
+func (individual *Individual) giveBack() {
+	individual.borrowers--
+	if individual.borrowers == 0 && individual.lives <= 0 {
+		individual.DisposeVMs()
+	}
+}
+
+// evolveAging is regularized evolution: every worker picks the winner of a tournament of advCfg.TournamentSize,
+// trains its mutated child and adds it to the population in place of the oldest individual, without waiting for
+// other workers. Every targetNumIndividuals children make a generation, so that the budget is the same as
+// of the generational strategy
+func (species *Species) evolveAging(
+	ctx context.Context, advCfg AdvancedConfig, numGenerations int,
+	xTrain, yTrain, xTest, yTest tensor.Tensor,
+	progressChan chan Progress, allChartChan chan AllChartData, bestChartChan chan BestChartData, bestStructureChan chan BestStructure) error {
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	progress := Progress{
+		Generation: species.generation,
+		Individual: species.generation * species.targetNumIndividuals,
+	}
+	finish := func() {
+		if progressChan != nil {
+			progress.Generation = -1
+			progressChan <- progress
+		}
+	}
+	sendBest := func() {
+		best := species.Best()
+		if species.generation >= species.unreported {
+			select {
+			case bestChartChan <- BestChartData{Generation: species.generation, Fitness: best.fitness}:
+			default:
+			}
+			species.unreported = species.generation + 1
+		}
+		select {
+		case bestStructureChan <- BestStructure{best.Chain.Layers, best.genome.Hyperparameters.Resolve(advCfg), nil}:
+		default:
+		}
+	}
+	selector := TournamentSelector{Size: tensor.MaxInt(advCfg.TournamentSize, 2)}
+
+	// train the starting population
+	if species.generation >= species.unreported {
+		fmt.Printf("===================================== Generation %d =====================================\n", species.generation)
+	}
+	for reseeds := 0; ; reseeds++ {
+		queue := make(chan *Individual)
+		for w := 0; w < maxConcurrency(advCfg); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for individual := range queue {
+					species.evaluate(ctx, advCfg, individual, allChartChan, xTrain, yTrain, xTest, yTest)
+					mu.Lock()
+					progress.Individual++
+					progress.CacheHitRate = species.cache.hitRate()
+					select {
+					case progressChan <- progress:
+					default:
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, individual := range species.individuals {
+			if individual.trained {
+				fmt.Printf("Skipping %v\n", individual.name)
+				mu.Lock()
+				progress.Individual++
+				mu.Unlock()
+				continue
+			}
+			queue <- individual
+		}
+		close(queue)
+		wg.Wait()
+		if ctx.Err() != nil {
+			fmt.Println("ABORTING")
+			finish()
+			return nil
+		}
+
+		for i := 0; i < len(species.individuals); i++ {
+			if !species.individuals[i].trained {
+				species.individuals[i].DisposeVMs()
+				species.individuals = append(species.individuals[:i], species.individuals[i+1:]...)
+				i--
+			}
+		}
+		if len(species.individuals) >= 2 {
+			break
+		}
+		if reseeds == maxReseeds {
+			finish()
+			return ExtinctionError{species.generation}
+		}
+		fmt.Println("WARNING: there are no at least 2 individuals left, reseeding")
+		if err := species.reseed(advCfg); err != nil {
+			fmt.Println("WARNING:", err.Error())
+		}
+		progress.Individual = species.generation * species.targetNumIndividuals
+	}
+	species.numberLives()
+	sendBest()
+	species.maybeSaveCheckpoint(advCfg)
+
+	// breed one child at a time in every worker until the budget of the remaining generations is spent
+	start := time.Now()
+	firstGeneration := species.generation
+	budget := (numGenerations - 1 - species.generation) * species.targetNumIndividuals
+	claimed, born := 0, 0
+	for w := 0; w < maxConcurrency(advCfg); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if claimed >= budget || ctx.Err() != nil {
+					mu.Unlock()
+					return
+				}
+				claimed++
+				// the parent is picked under the lock and borrowed, so that it isn't disposed while its child is
+				// compiled and inherits its weights without blocking other workers
+				parent := selector.Select(species.rng, species.individuals, 1)[0]
+				parent.borrow()
+				mutationChance := (1 - parent.fitness) * advCfg.MutationMultiplier
+				rng := species.newRNG()
+				mu.Unlock()
+
+				child, err := parent.Mutate(rng, advCfg, mutationChance)
+				mu.Lock()
+				parent.giveBack()
+				mu.Unlock()
+
+				survived := false
+				if err == nil {
+					survived = species.evaluate(ctx, advCfg, child, allChartChan, xTrain, yTrain, xTest, yTest)
+				} else {
+					fmt.Println("did not survive mutation")
+				}
+
+				mu.Lock()
+				if ctx.Err() != nil {
+					mu.Unlock()
+					if child != nil {
+						child.DisposeVMs()
+					}
+					return
+				}
+				if survived {
+					species.addNewborn(child)
+				} else if child != nil {
+					child.DisposeVMs()
+				}
+				born++
+				progress.Individual++
+				progress.CacheHitRate = species.cache.hitRate()
+				if born%species.targetNumIndividuals == 0 {
+					species.generation++
+					fmt.Printf("===================================== Generation %d =====================================\n", species.generation)
+					progress.Generation = species.generation
+					progress.ETASeconds = time.Since(start).Seconds() / float64(species.generation-firstGeneration) *
+						float64(numGenerations-1-species.generation)
+					sendBest()
+					species.maybeSaveCheckpoint(advCfg)
+				}
+				select {
+				case progressChan <- progress:
+				default:
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		fmt.Println("ABORTING")
+	}
+	finish()
+	return nil
+}
@@ -0,0 +1,118 @@
+package evolution
+
+import (
+	"errors"
+	"gorgonia.org/tensor"
+	"testing"
+)
+
+func TestValidateStrategy(t *testing.T) {
+	for _, strategy := range []string{"", StrategyGenerational, StrategyAging, StrategyRandom, StrategyGrid} {
+		if err := validateStrategy(strategy); err != nil {
+			t.Errorf("validateStrategy(%q) error = %v", strategy, err)
+		}
+	}
+	if err := validateStrategy("steady"); !errors.As(err, &UnknownStrategyError{}) {
+		t.Errorf("validateStrategy(%q) error = %v, want UnknownStrategyError", "steady", err)
+	}
+}
+
+func TestSpecies_addNewborn(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	compile := func() *Individual {
+		individual, err := compileGenome(advCfg, newTestGenome())
+		if err != nil {
+			t.Fatalf("compileGenome() error = %v", err)
+		}
+		return individual
+	}
+	species := &Species{targetNumIndividuals: 3}
+	for i := 0; i < 3; i++ {
+		species.individuals = append(species.individuals, compile())
+	}
+	species.numberLives()
+	for i, individual := range species.individuals {
+		if individual.lives != i+1 {
+			t.Errorf("numberLives() gave individual %d %d lives, want %d", i, individual.lives, i+1)
+		}
+	}
+
+	// the oldest individual dies every time a child is born, first the starting ones in their order
+	born := append([]*Individual{}, species.individuals...)
+	for i := 0; i < 4; i++ {
+		child := compile()
+		species.addNewborn(child)
+		born = append(born, child)
+		if len(species.individuals) != 3 {
+			t.Fatalf("addNewborn() left %d individuals, want 3", len(species.individuals))
+		}
+		for j, individual := range species.individuals {
+			if individual != born[i+1+j] {
+				t.Errorf("after %d children individual %d is born %d-th, want %d-th", i+1, j, indexOf(born, individual), i+1+j)
+			}
+		}
+	}
+
+	// lives restored from a checkpoint are kept
+	species.numberLives()
+	if species.individuals[0].lives != 1 || species.individuals[2].lives != 3 {
+		t.Errorf("numberLives() renumbered lives that were already numbered")
+	}
+
+	// a population that isn't full grows instead
+	species.targetNumIndividuals = 4
+	species.addNewborn(compile())
+	if len(species.individuals) != 4 {
+		t.Errorf("addNewborn() to a population that isn't full left %d individuals, want 4", len(species.individuals))
+	}
+}
+
+func TestIndividual_borrow(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	species := &Species{targetNumIndividuals: 2}
+	for i := 0; i < 2; i++ {
+		individual, err := compileGenome(advCfg, newTestGenome())
+		if err != nil {
+			t.Fatalf("compileGenome() error = %v", err)
+		}
+		species.individuals = append(species.individuals, individual)
+	}
+	species.numberLives()
+	disposed := func(individual *Individual) (disposed bool) {
+		defer func() {
+			disposed = recover() != nil
+		}()
+		_, _ = individual.Sequential.Predict(tensor.New(tensor.WithShape(1, 1, 12, 16), tensor.Of(tensor.Float32)))
+		return false
+	}
+
+	// the oldest individual dies while it's borrowed twice, and is disposed once it's given back by both borrowers
+	parent := species.individuals[0]
+	parent.borrow()
+	parent.borrow()
+	child, err := compileGenome(advCfg, newTestGenome())
+	if err != nil {
+		t.Fatalf("compileGenome() error = %v", err)
+	}
+	species.addNewborn(child)
+	if indexOf(species.individuals, parent) != -1 {
+		t.Fatalf("addNewborn() kept the oldest individual")
+	}
+	for i, want := range []bool{false, false, true} {
+		if i > 0 {
+			parent.giveBack()
+		}
+		if got := disposed(parent); got != want {
+			t.Errorf("after giving back %d times the parent is disposed = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func indexOf(individuals []*Individual, individual *Individual) int {
+	for i := range individuals {
+		if individuals[i] == individual {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,322 @@
+package evolution
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ghodss/yaml"
+	"sotsuron/internal/nn"
+	"sotsuron/internal/utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatDSL  = "dsl"
+)
+
+// ArchitectureTemplates are well-known architectures in the DSL that can be used to seed a population.
+// The number of outputs of their last FC is replaced by the number of classes of the dataset
+var ArchitectureTemplates = map[string]string{
+	"lenet": "conv(6,5x5,tanh,p2)-pool(2x2,s2)-conv(16,5x5,tanh)-pool(2x2,s2)-flatten-fc(120,tanh)-fc(84,tanh)-fc(10)",
+	"mlp":   "flatten-fc(128,relu)-fc(10)",
+	"small": "conv(16,3x3,relu,p1)-pool(2x2,s2)-conv(32,3x3,relu,p1)-pool(2x2,s2)-flatten-fc(64,relu)-fc(10)",
+}
+
+// architecture is the part of a genome that doesn't depend on the dataset
+type architecture struct {
+	ConvBlocks      []ConvBlock
+	DenseBlocks     []DenseBlock
+	Hyperparameters *Hyperparameters `json:",omitempty"`
+}
+
+type UnknownFormatError struct {
+	format string
+}
+
+func (err UnknownFormatError) Error() string {
+	return fmt.Sprintf("unknown architecture format %q", err.format)
+}
+
+type ArchitectureSyntaxError struct {
+	block  string
+	reason string
+}
+
+func (err ArchitectureSyntaxError) Error() string {
+	return fmt.Sprintf("could not parse %q: %s", err.block, err.reason)
+}
+
+// MarshalArchitecture serializes the blocks of the genome in one of the formats: "json", "yaml" or "dsl"
+func (genome Genome) MarshalArchitecture(format string) ([]byte, error) {
+	arch := architecture{ConvBlocks: genome.ConvBlocks, DenseBlocks: genome.DenseBlocks}
+	if genome.Hyperparameters != (Hyperparameters{}) {
+		arch.Hyperparameters = &genome.Hyperparameters
+	}
+	switch format {
+	case FormatJSON:
+		return json.MarshalIndent(arch, "", "  ")
+	case FormatYAML:
+		return yaml.Marshal(arch)
+	case FormatDSL:
+		return []byte(genome.String()), nil
+	default:
+		return nil, UnknownFormatError{format}
+	}
+}
+
+// ParseArchitecture reads an architecture serialized with MarshalArchitecture and validates it
+// against images of inputRes with given number of channels
+func ParseArchitecture(data []byte, format string, inputRes utils.Resolution, channels int) (genome Genome, err error) {
+	var arch architecture
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &arch)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &arch)
+	case FormatDSL:
+		arch, err = parseDSL(string(data))
+	default:
+		err = UnknownFormatError{format}
+	}
+	if err != nil {
+		return Genome{}, err
+	}
+	genome = Genome{
+		InputRes:    inputRes,
+		Channels:    channels,
+		ConvBlocks:  arch.ConvBlocks,
+		DenseBlocks: arch.DenseBlocks,
+	}
+	if arch.Hyperparameters != nil {
+		genome.Hyperparameters = *arch.Hyperparameters
+	}
+	for i, block := range genome.ConvBlocks {
+		genome.ConvBlocks[i].Activation = normalizeActivation(block.Activation)
+	}
+	for i, block := range genome.DenseBlocks {
+		genome.DenseBlocks[i].Activation = normalizeActivation(block.Activation)
+	}
+	return genome, genome.Validate()
+}
+
+// normalizeActivation lets activations be written in any case, e.g. "relu" for "ReLU"
+func normalizeActivation(name string) string {
+	for _, canonical := range []string{"Linear", "Sigmoid", "Softmax", "Tanh", "ReLU", "LeakyReLU"} {
+		if strings.EqualFold(name, canonical) {
+			return canonical
+		}
+	}
+	return name
+}
+
+// String formats the genome in the DSL, e.g. conv(16,3x3,relu,p1,s1,bn,d0.25)-pool(2x2,p0,s1)-avgpool(2x2,p0,s1)-flatten-fc(128,tanh,d0.5)-fc(10,linear).
+// Kernels are height x width, pads and strides default to 0 and 1 when parsed. bn adds batch normalization
+// and dN adds Dropout with probability N. add@N and cat@N add or concatenate the output of conv block N,
+// counting pooling ones from 0, or of the input with add@in and cat@in. Hyperparameters that are set
+// follow as the last block, e.g. train(sgd,lr=0.01,batch=16,l2=0.0001,epochs=3)
+func (genome Genome) String() string {
+	var blocks []string
+	for _, block := range genome.ConvBlocks {
+		switch block.Type {
+		case BlockConv2D:
+			s := fmt.Sprintf("conv(%d,%dx%d,%s,p%d,s%d",
+				block.Output, block.Height, block.Width, strings.ToLower(block.Activation), block.Pad, block.Stride)
+			if block.BatchNorm {
+				s += ",bn"
+			}
+			if block.Skip != nil {
+				s += "," + formatSkip(*block.Skip)
+			}
+			if block.Dropout > 0 {
+				s += fmt.Sprintf(",d%g", block.Dropout)
+			}
+			blocks = append(blocks, s+")")
+		case BlockAvgPooling2D:
+			blocks = append(blocks, fmt.Sprintf("avgpool(%dx%d,p%d,s%d)", block.Height, block.Width, block.Pad, block.Stride))
+		default:
+			blocks = append(blocks, fmt.Sprintf("pool(%dx%d,p%d,s%d)", block.Height, block.Width, block.Pad, block.Stride))
+		}
+	}
+	blocks = append(blocks, "flatten")
+	for _, block := range genome.DenseBlocks {
+		if block.Dropout > 0 {
+			blocks = append(blocks, fmt.Sprintf("fc(%d,%s,d%g)", block.Output, strings.ToLower(block.Activation), block.Dropout))
+			continue
+		}
+		blocks = append(blocks, fmt.Sprintf("fc(%d,%s)", block.Output, strings.ToLower(block.Activation)))
+	}
+	if genome.Hyperparameters != (Hyperparameters{}) {
+		blocks = append(blocks, genome.Hyperparameters.String())
+	}
+	return strings.Join(blocks, "-")
+}
+
+func parseDSL(s string) (arch architecture, err error) {
+	flattened := false
+	blocks := strings.Split(strings.Join(strings.Fields(s), ""), "-")
+	for i, block := range blocks {
+		name, args := block, []string(nil)
+		if open := strings.Index(block, "("); open != -1 {
+			if !strings.HasSuffix(block, ")") {
+				return architecture{}, ArchitectureSyntaxError{block, "missing closing parenthesis"}
+			}
+			name, args = block[:open], strings.Split(block[open+1:len(block)-1], ",")
+		}
+		switch strings.ToLower(name) {
+		case "conv":
+			if flattened {
+				return architecture{}, ArchitectureSyntaxError{block, "conv after flatten"}
+			}
+			convBlock, err := parseConvArgs(block, BlockConv2D, args)
+			if err != nil {
+				return architecture{}, err
+			}
+			arch.ConvBlocks = append(arch.ConvBlocks, convBlock)
+		case "pool", "avgpool":
+			if flattened {
+				return architecture{}, ArchitectureSyntaxError{block, name + " after flatten"}
+			}
+			blockType := BlockMaxPooling2D
+			if strings.ToLower(name) == "avgpool" {
+				blockType = BlockAvgPooling2D
+			}
+			convBlock, err := parseConvArgs(block, blockType, args)
+			if err != nil {
+				return architecture{}, err
+			}
+			arch.ConvBlocks = append(arch.ConvBlocks, convBlock)
+		case "flatten":
+			if flattened {
+				return architecture{}, ArchitectureSyntaxError{block, "second flatten"}
+			}
+			flattened = true
+		case "fc":
+			flattened = true
+			if len(args) < 1 || len(args) > 3 {
+				return architecture{}, ArchitectureSyntaxError{block, "want fc(outputs[,activation][,dN])"}
+			}
+			denseBlock := DenseBlock{Activation: "Linear"}
+			if denseBlock.Output, err = strconv.Atoi(args[0]); err != nil {
+				return architecture{}, ArchitectureSyntaxError{block, "outputs must be a number"}
+			}
+			for _, arg := range args[1:] {
+				if !isNumberedArg(arg, 'd') {
+					denseBlock.Activation = arg
+					continue
+				}
+				if denseBlock.Dropout, err = strconv.ParseFloat(arg[1:], 64); err != nil {
+					return architecture{}, ArchitectureSyntaxError{block, `"d" must be followed by a number`}
+				}
+			}
+			arch.DenseBlocks = append(arch.DenseBlocks, denseBlock)
+		case "train":
+			if i != len(blocks)-1 {
+				return architecture{}, ArchitectureSyntaxError{block, "train must be the last block"}
+			}
+			hyperparameters, err := parseTrainArgs(block, args)
+			if err != nil {
+				return architecture{}, err
+			}
+			arch.Hyperparameters = &hyperparameters
+		default:
+			return architecture{}, ArchitectureSyntaxError{block, "unknown block"}
+		}
+	}
+	return
+}
+
+var skipModeNames = map[string]string{nn.MergeAdd: "add", nn.MergeConcat: "cat"}
+
+func formatSkip(skip Skip) string {
+	if skip.From == -1 {
+		return skipModeNames[skip.Mode] + "@in"
+	}
+	return fmt.Sprintf("%s@%d", skipModeNames[skip.Mode], skip.From)
+}
+
+// parseSkip parses add@N, cat@N, add@in or cat@in
+func parseSkip(arg string) (skip Skip, err error) {
+	name, from, _ := strings.Cut(strings.ToLower(arg), "@")
+	for mode, modeName := range skipModeNames {
+		if name == modeName {
+			skip.Mode = mode
+		}
+	}
+	if skip.Mode == "" {
+		return Skip{}, fmt.Errorf("unknown merge %q, want add or cat", name)
+	}
+	if from == "in" {
+		skip.From = -1
+	} else if skip.From, err = strconv.Atoi(from); err != nil || skip.From < 0 {
+		return Skip{}, fmt.Errorf("%q must be followed by a block number or in", name+"@")
+	}
+	return skip, nil
+}
+
+// isNumberedArg tells whether arg is a letter followed by a number, like p1 or d0.5
+func isNumberedArg(arg string, letter byte) bool {
+	return len(arg) > 1 && arg[0] == letter && (arg[1] >= '0' && arg[1] <= '9' || arg[1] == '.')
+}
+
+// parseConvArgs parses arguments of conv(filters,HxW[,activation][,pN][,sN][,bn][,add@N|cat@N][,dN]) or pool(HxW[,pN][,sN])
+func parseConvArgs(block, blockType string, args []string) (convBlock ConvBlock, err error) {
+	convBlock = ConvBlock{Type: blockType, Stride: 1}
+	if blockType == BlockConv2D {
+		if len(args) < 2 {
+			return ConvBlock{}, ArchitectureSyntaxError{block, "want conv(filters,HxW[,activation][,pN][,sN][,bn][,add@N|cat@N][,dN])"}
+		}
+		if convBlock.Output, err = strconv.Atoi(args[0]); err != nil {
+			return ConvBlock{}, ArchitectureSyntaxError{block, "filters must be a number"}
+		}
+		convBlock.Activation = "Linear"
+		args = args[1:]
+	}
+	if len(args) < 1 {
+		return ConvBlock{}, ArchitectureSyntaxError{block, "missing kernel size"}
+	}
+	kernel := strings.Split(strings.ToLower(args[0]), "x")
+	if len(kernel) != 2 {
+		return ConvBlock{}, ArchitectureSyntaxError{block, "kernel size must look like 3x3"}
+	}
+	if convBlock.Height, err = strconv.Atoi(kernel[0]); err != nil {
+		return ConvBlock{}, ArchitectureSyntaxError{block, "kernel height must be a number"}
+	}
+	if convBlock.Width, err = strconv.Atoi(kernel[1]); err != nil {
+		return ConvBlock{}, ArchitectureSyntaxError{block, "kernel width must be a number"}
+	}
+	for _, arg := range args[1:] {
+		var value *int
+		switch {
+		case isNumberedArg(arg, 'p'):
+			value = &convBlock.Pad
+		case isNumberedArg(arg, 's'):
+			value = &convBlock.Stride
+		case blockType == BlockConv2D && strings.EqualFold(arg, "bn"):
+			convBlock.BatchNorm = true
+			continue
+		case blockType == BlockConv2D && strings.Contains(arg, "@"):
+			skip, err := parseSkip(arg)
+			if err != nil {
+				return ConvBlock{}, ArchitectureSyntaxError{block, err.Error()}
+			}
+			convBlock.Skip = &skip
+			continue
+		case blockType == BlockConv2D && isNumberedArg(arg, 'd'):
+			if convBlock.Dropout, err = strconv.ParseFloat(arg[1:], 64); err != nil {
+				return ConvBlock{}, ArchitectureSyntaxError{block, `"d" must be followed by a number`}
+			}
+			continue
+		case blockType == BlockConv2D:
+			convBlock.Activation = arg
+			continue
+		default:
+			return ConvBlock{}, ArchitectureSyntaxError{block, fmt.Sprintf("unexpected argument %q", arg)}
+		}
+		if *value, err = strconv.Atoi(arg[1:]); err != nil {
+			return ConvBlock{}, ArchitectureSyntaxError{block, fmt.Sprintf("%q must be followed by a number", arg[:1])}
+		}
+	}
+	return
+}
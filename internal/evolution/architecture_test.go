@@ -0,0 +1,87 @@
+package evolution
+
+import (
+	"errors"
+	"reflect"
+	"sotsuron/internal/utils"
+	"testing"
+)
+
+func TestGenome_MarshalArchitecture(t *testing.T) {
+	trained := newTestGenome()
+	trained.Hyperparameters = Hyperparameters{LearningRate: 0.00005, Optimizer: OptimizerRMSProp, BatchSize: 16, L2: 0.0001, Epochs: 3}
+	for _, format := range []string{FormatJSON, FormatYAML, FormatDSL} {
+		for _, genome := range []Genome{newTestGenome(), newTestRegularizedGenome(), newTestSkipGenome(), trained} {
+			genome := genome
+			t.Run(format, func(t *testing.T) {
+				data, err := genome.MarshalArchitecture(format)
+				if err != nil {
+					t.Fatalf("MarshalArchitecture() error = %v", err)
+				}
+				parsed, err := ParseArchitecture(data, format, genome.InputRes, genome.Channels)
+				if err != nil {
+					t.Fatalf("ParseArchitecture() error = %v", err)
+				}
+				if !reflect.DeepEqual(parsed, genome) {
+					t.Errorf("ParseArchitecture() = %+v, want %+v", parsed, genome)
+				}
+			})
+		}
+	}
+	if _, err := newTestGenome().MarshalArchitecture("xml"); !errors.As(err, &UnknownFormatError{}) {
+		t.Errorf("MarshalArchitecture() error = %v, want UnknownFormatError", err)
+	}
+}
+
+func TestParseArchitecture(t *testing.T) {
+	inputRes := utils.Resolution{Width: 28, Height: 28}
+	tests := []struct {
+		name    string
+		dsl     string
+		wantErr interface{}
+	}{
+		{name: "example", dsl: "conv(16,3x3,relu,p1,s1)-pool(2x2)-flatten-fc(128,tanh)-fc(10)"},
+		{name: "spaces and no flatten", dsl: "conv(4, 5x5, sigmoid) - fc(10, softmax)"},
+		{name: "regularized", dsl: "conv(8,3x3,relu,bn,d0.2)-avgpool(2x2,s2)-flatten-fc(64,relu,d.5)-fc(10)"},
+		{name: "skips", dsl: "conv(8,3x3,relu,p1,cat@in)-conv(9,3x3,relu,p1,bn,add@0,d0.1)-conv(4,3x3,relu,cat@in)-fc(10)"},
+		{name: "hyperparameters", dsl: "conv(4,3x3)-fc(10)-train(SGD,lr=0.01,batch=16,epochs=2)"},
+		{name: "train before layers", dsl: "train(sgd)-conv(4,3x3)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "unknown hyperparameter", dsl: "conv(4,3x3)-fc(10)-train(momentum=0.9)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "unknown optimizer", dsl: "conv(4,3x3)-fc(10)-train(lbfgs)", wantErr: &InvalidGenomeError{}},
+		{name: "unknown merge", dsl: "conv(4,3x3,mul@in)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "skip from nowhere", dsl: "conv(4,3x3,cat@)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "skip from later block", dsl: "conv(4,3x3,cat@1)-conv(4,3x3)-fc(10)", wantErr: &InvalidGenomeError{}},
+		{name: "dropout after pooling", dsl: "pool(2x2,d0.5)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "dropout after output", dsl: "conv(4,3x3)-fc(10,d0.5)", wantErr: &InvalidGenomeError{}},
+		{name: "unknown block", dsl: "conv(4,3x3)-dropout(0.5)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "conv after flatten", dsl: "fc(32)-conv(4,3x3)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "bad kernel", dsl: "conv(4,3)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "bad stride", dsl: "pool(2x2,sx)-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "unclosed", dsl: "conv(4,3x3-fc(10)", wantErr: &ArchitectureSyntaxError{}},
+		{name: "kernel too large", dsl: "conv(4,30x3)-fc(10)", wantErr: &InvalidGenomeError{}},
+		{name: "unknown activation", dsl: "conv(4,3x3,swish)-fc(10)", wantErr: &InvalidGenomeError{}},
+		{name: "stride 0", dsl: "conv(4,3x3,relu,s0)-flatten-fc(10)", wantErr: &InvalidGenomeError{}},
+		{name: "pooling stride 0 with a skip", dsl: "conv(4,3x3)-pool(2x2,s0)-conv(4,3x3,cat@in)-fc(10)", wantErr: &InvalidGenomeError{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			genome, err := ParseArchitecture([]byte(tt.dsl), FormatDSL, inputRes, 1)
+			if tt.wantErr != nil {
+				if !errors.As(err, tt.wantErr) {
+					t.Errorf("ParseArchitecture() error = %v, want %T", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseArchitecture() error = %v", err)
+			}
+			if _, err = genome.Decode(); err != nil {
+				t.Errorf("Decode() error = %v", err)
+			}
+			if genome.NumClasses() != 10 {
+				t.Errorf("NumClasses() = %v, want 10", genome.NumClasses())
+			}
+		})
+	}
+}
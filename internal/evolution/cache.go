@@ -0,0 +1,113 @@
+package evolution
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	g "github.com/m8u/gorgonia"
+	"gorgonia.org/tensor"
+	"sync"
+)
+
+// Fingerprint is a hash of the architecture and the training hyperparameters of the individual, resolved against
+// advCfg, so that individuals that would be trained the same way have the same fingerprint
+func (individual *Individual) Fingerprint(advCfg AdvancedConfig) string {
+	genome := individual.genome
+	genome.Hyperparameters = genome.Hyperparameters.Resolve(advCfg)
+	data, _ := json.Marshal(genome)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedFitness is the result of training an architecture, averaged over evaluations
+type cachedFitness struct {
+	fitness     float32
+	objectives  Objectives
+	weights     []tensor.Tensor // of the latest evaluation
+	evaluations int
+}
+
+// fitnessCache remembers fitness of evaluated architectures by their fingerprints
+type fitnessCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedFitness
+	lookups int
+	hits    int
+}
+
+func newFitnessCache() *fitnessCache {
+	return &fitnessCache{entries: make(map[string]*cachedFitness)}
+}
+
+// hitRate is the share of lookups that reused a cached fitness
+func (cache *fitnessCache) hitRate() float64 {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.lookups == 0 {
+		return 0
+	}
+	return float64(cache.hits) / float64(cache.lookups)
+}
+
+// reuse gives the individual the fitness and the trained weights of an architecture that has already been evaluated
+// advCfg.CacheEvaluations times. Individuals that inherited weights are never looked up, they aren't trained from scratch
+func (cache *fitnessCache) reuse(advCfg AdvancedConfig, individual *Individual) bool {
+	if !advCfg.FitnessCache || individual.inherited {
+		return false
+	}
+	fingerprint := individual.Fingerprint(advCfg)
+	cache.mu.Lock()
+	cache.lookups++
+	entry, ok := cache.entries[fingerprint]
+	if !ok || entry.evaluations < advCfg.CacheEvaluations {
+		cache.mu.Unlock()
+		return false
+	}
+	fitness, objectives, weights, evaluations := entry.fitness, entry.objectives, entry.weights, entry.evaluations
+	cache.mu.Unlock()
+
+	graph := g.NewGraph()
+	learnables := make(g.Nodes, len(weights))
+	for i, weight := range weights {
+		learnables[i] = g.NodeFromAny(graph, weight)
+	}
+	if err := individual.SetLearnables(learnables); err != nil {
+		fmt.Println("WARNING: could not reuse cached weights:", err.Error())
+		return false
+	}
+	individual.fitness, individual.objectives, individual.trained, individual.cached = fitness, objectives, true, true
+	cache.mu.Lock()
+	cache.hits++
+	cache.mu.Unlock()
+	fmt.Printf("%s repeats an architecture evaluated %d times, reusing its fitness %v\n", individual.name, evaluations, fitness)
+	return true
+}
+
+// store adds the result of a trained individual to the cache. If its architecture has been evaluated before,
+// the individual gets the average fitness of all evaluations
+func (cache *fitnessCache) store(advCfg AdvancedConfig, individual *Individual) {
+	if !advCfg.FitnessCache || individual.inherited {
+		return
+	}
+	var weights []tensor.Tensor
+	for _, learnable := range individual.Learnables() {
+		weights = append(weights, learnable.Value().(tensor.Tensor).Clone().(tensor.Tensor))
+	}
+	fingerprint := individual.Fingerprint(advCfg)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	entry, ok := cache.entries[fingerprint]
+	if !ok {
+		cache.entries[fingerprint] = &cachedFitness{individual.fitness, individual.objectives, weights, 1}
+		return
+	}
+	n := float32(entry.evaluations)
+	entry.fitness = (entry.fitness*n + individual.fitness) / (n + 1)
+	entry.objectives.Accuracy = (entry.objectives.Accuracy*n + individual.objectives.Accuracy) / (n + 1)
+	entry.objectives.Latency = (entry.objectives.Latency*float64(n) + individual.objectives.Latency) / float64(n+1)
+	entry.weights = weights
+	entry.evaluations++
+	individual.fitness, individual.objectives = entry.fitness, entry.objectives
+	fmt.Printf("%s repeats an architecture, its average fitness over %d evaluations is %v\n", individual.name, entry.evaluations, entry.fitness)
+}
@@ -0,0 +1,78 @@
+package evolution
+
+import (
+	"testing"
+)
+
+func TestIndividual_Fingerprint(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	compile := func(genome Genome) *Individual {
+		individual, err := compileGenome(advCfg, genome)
+		if err != nil {
+			t.Fatalf("compileGenome() error = %v", err)
+		}
+		return individual
+	}
+	fingerprint := compile(newTestGenome()).Fingerprint(advCfg)
+	if got := compile(newTestGenome()).Fingerprint(advCfg); got != fingerprint {
+		t.Errorf("Fingerprint() of the same architecture = %v, want %v", got, fingerprint)
+	}
+
+	// hyperparameters that resolve to the ones of advCfg don't change the way the individual is trained
+	genome := newTestGenome()
+	genome.Hyperparameters = Hyperparameters{Epochs: advCfg.Epochs, Optimizer: OptimizerAdam}
+	if got := compile(genome).Fingerprint(advCfg); got != fingerprint {
+		t.Errorf("Fingerprint() with resolved hyperparameters = %v, want %v", got, fingerprint)
+	}
+
+	genome.Hyperparameters.Epochs++
+	if compile(genome).Fingerprint(advCfg) == fingerprint {
+		t.Errorf("Fingerprint() didn't change with the number of epochs")
+	}
+	genome = newTestGenome()
+	genome.ConvBlocks[0].Output++
+	if compile(genome).Fingerprint(advCfg) == fingerprint {
+		t.Errorf("Fingerprint() didn't change with the number of filters")
+	}
+}
+
+func TestFitnessCache(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.CacheEvaluations = 2
+	cache := newFitnessCache()
+	evaluate := func(fitness float32, weight float32) *Individual {
+		individual, err := compileGenome(advCfg, newTestGenome())
+		if err != nil {
+			t.Fatalf("compileGenome() error = %v", err)
+		}
+		if cache.reuse(advCfg, individual) {
+			return individual
+		}
+		fillLearnables(t, individual, weight)
+		individual.trained, individual.fitness = true, fitness
+		cache.store(advCfg, individual)
+		return individual
+	}
+
+	evaluate(0.5, 0.1)
+	if second := evaluate(0.7, 0.2); second.fitness != 0.6 {
+		t.Errorf("store() of the second evaluation set fitness %v, want the average 0.6", second.fitness)
+	}
+	third := evaluate(0.9, 0.3)
+	if !third.trained || third.fitness != 0.6 {
+		t.Errorf("reuse() after %d evaluations gave fitness %v, want 0.6", advCfg.CacheEvaluations, third.fitness)
+	}
+	for _, learnable := range third.Learnables() {
+		if !allEqual(learnable.Value().Data().([]float32), 0.2) {
+			t.Fatalf("reuse() didn't restore weights of the latest evaluation")
+		}
+	}
+	if got := cache.hitRate(); got != 1.0/3 {
+		t.Errorf("hitRate() = %v, want 1/3", got)
+	}
+
+	advCfg.FitnessCache = false
+	if individual := evaluate(0.1, 0); individual.fitness != 0.1 {
+		t.Errorf("a disabled cache gave fitness %v, want 0.1", individual.fitness)
+	}
+}
@@ -0,0 +1,192 @@
+package evolution
+
+import (
+	"encoding/gob"
+	"fmt"
+	"golang.org/x/exp/rand"
+	"os"
+	"path/filepath"
+	"sotsuron/internal/utils"
+)
+
+type checkpoint struct {
+	Generation           int
+	TargetNumIndividuals int
+	AdvancedConfig       AdvancedConfig
+	Seed                 uint64
+	RNGState             []byte
+	Individuals          []individualCheckpoint
+	Islands              []checkpoint
+}
+
+type individualCheckpoint struct {
+	Name            string
+	Layers          []LayerSpec
+	Hyperparameters Hyperparameters
+	Weights         []tensorSpec // only stored for trained individuals
+	InputRes        utils.Resolution
+	Grayscale       bool
+	NumClasses      int
+	Fitness         float32
+	Objectives      Objectives
+	Trained         bool
+	Lives           int
+}
+
+// SetCheckpointPath makes Evolve write a checkpoint to path after every generation
+func (species *Species) SetCheckpointPath(path string) {
+	species.checkpointPath = path
+}
+
+// Generation returns the number of generations the species has already been through
+func (species *Species) Generation() int {
+	return species.generation
+}
+
+func (species *Species) maybeSaveCheckpoint(advCfg AdvancedConfig) {
+	if species.checkpointPath == "" {
+		return
+	}
+	if err := species.SaveCheckpoint(species.checkpointPath, advCfg); err != nil {
+		fmt.Println("WARNING: could not save checkpoint:", err.Error())
+	}
+}
+
+// SaveCheckpoint writes the whole state of the species to path, so that evolution can be resumed later
+func (species *Species) SaveCheckpoint(path string, advCfg AdvancedConfig) error {
+	cp, err := species.checkpoint(advCfg)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that a crash during saving doesn't corrupt the previous checkpoint
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err = gob.NewEncoder(file).Encode(cp); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadCheckpoint rebuilds a species saved with SaveCheckpoint. Evolve called on it resumes from the next
+// generation and doesn't retrain individuals that have already been trained
+func LoadCheckpoint(path string) (species *Species, advCfg AdvancedConfig, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, advCfg, err
+	}
+	defer file.Close()
+	var cp checkpoint
+	if err = gob.NewDecoder(file).Decode(&cp); err != nil {
+		return nil, advCfg, err
+	}
+
+	if species, err = restoreSpecies(cp); err != nil {
+		return nil, advCfg, err
+	}
+	return species, cp.AdvancedConfig, nil
+}
+
+// checkpoint describes the species and its islands, weights are only stored for trained individuals
+func (species *Species) checkpoint(advCfg AdvancedConfig) (checkpoint, error) {
+	rngState, err := species.rngSource.MarshalBinary()
+	if err != nil {
+		return checkpoint{}, err
+	}
+	cp := checkpoint{
+		Generation:           species.generation,
+		TargetNumIndividuals: species.targetNumIndividuals,
+		AdvancedConfig:       advCfg,
+		Seed:                 species.seed,
+		RNGState:             rngState,
+	}
+	for _, individual := range species.individuals {
+		layers, err := EncodeLayers(individual.Chain.Layers)
+		if err != nil {
+			return checkpoint{}, err
+		}
+		individualCp := individualCheckpoint{
+			Name:            individual.name,
+			Layers:          layers,
+			Hyperparameters: individual.genome.Hyperparameters,
+			InputRes:        individual.inputRes,
+			Grayscale:       individual.isGrayscale,
+			NumClasses:      individual.numClasses,
+			Fitness:         individual.fitness,
+			Objectives:      individual.objectives,
+			Trained:         individual.trained,
+			Lives:           individual.lives,
+		}
+		if individual.trained {
+			individualCp.Weights, err = encodeLearnables(individual.Learnables())
+			if err != nil {
+				return checkpoint{}, err
+			}
+		}
+		cp.Individuals = append(cp.Individuals, individualCp)
+	}
+	for _, island := range species.islands {
+		islandCp, err := island.checkpoint(advCfg)
+		if err != nil {
+			return checkpoint{}, err
+		}
+		cp.Islands = append(cp.Islands, islandCp)
+	}
+	return cp, nil
+}
+
+// restoreSpecies rebuilds the species and its islands described by cp
+func restoreSpecies(cp checkpoint) (*Species, error) {
+	rngSource := &rand.PCGSource{}
+	if err := rngSource.UnmarshalBinary(cp.RNGState); err != nil {
+		return nil, err
+	}
+	species := &Species{
+		targetNumIndividuals: cp.TargetNumIndividuals,
+		generation:           cp.Generation,
+		seed:                 cp.Seed,
+		rng:                  rand.New(rngSource),
+		rngSource:            rngSource,
+	}
+	for _, individualCp := range cp.Individuals {
+		layers, err := DecodeLayers(individualCp.Layers)
+		if err != nil {
+			return nil, err
+		}
+		individual, err := compileIndividual(cp.AdvancedConfig, individualCp.Name, layers, individualCp.Hyperparameters, individualCp.InputRes, individualCp.NumClasses, individualCp.Grayscale)
+		if err != nil {
+			return nil, err
+		}
+		individual.fitness = individualCp.Fitness
+		individual.objectives = individualCp.Objectives
+		individual.trained = individualCp.Trained
+		individual.lives = individualCp.Lives
+		if individualCp.Trained {
+			if err = individual.setLearnables(individualCp.Weights); err != nil {
+				return nil, err
+			}
+		}
+		species.individuals = append(species.individuals, individual)
+		species.inputRes, species.numClasses, species.grayscale = individual.inputRes, individual.numClasses, individual.isGrayscale
+	}
+	for _, islandCp := range cp.Islands {
+		islandCp.AdvancedConfig = cp.AdvancedConfig
+		island, err := restoreSpecies(islandCp)
+		if err != nil {
+			return nil, err
+		}
+		species.islands = append(species.islands, island)
+		species.inputRes, species.numClasses, species.grayscale = island.inputRes, island.numClasses, island.grayscale
+	}
+	return species, nil
+}
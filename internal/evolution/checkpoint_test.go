@@ -0,0 +1,59 @@
+package evolution
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSpecies_SaveCheckpoint(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	species, err := NewSpecies(advCfg, 3, 12, 12, 4, true)
+	if err != nil {
+		t.Fatalf("NewSpecies() error = %v", err)
+	}
+	species.generation = 7
+	species.individuals[0].trained = true
+	species.individuals[0].fitness = 0.75
+	wantRng := *species.rngSource
+
+	path := filepath.Join(t.TempDir(), "checkpoint.gob")
+	if err := species.SaveCheckpoint(path, advCfg); err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+	restored, restoredAdvCfg, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(restoredAdvCfg, advCfg) {
+		t.Errorf("LoadCheckpoint() advCfg = %+v, want %+v", restoredAdvCfg, advCfg)
+	}
+	if restored.generation != species.generation || restored.targetNumIndividuals != species.targetNumIndividuals {
+		t.Errorf("LoadCheckpoint() generation, target = %d, %d, want %d, %d",
+			restored.generation, restored.targetNumIndividuals, species.generation, species.targetNumIndividuals)
+	}
+	if got, want := restored.rng.Uint64(), (&wantRng).Uint64(); got != want {
+		t.Errorf("LoadCheckpoint() rng yields %d, want %d", got, want)
+	}
+	if len(restored.individuals) != len(species.individuals) {
+		t.Fatalf("LoadCheckpoint() restored %d individuals, want %d", len(restored.individuals), len(species.individuals))
+	}
+	for i, individual := range species.individuals {
+		got := restored.individuals[i]
+		if got.name != individual.name || got.fitness != individual.fitness || got.trained != individual.trained {
+			t.Errorf("individual %d = (%s, %v, %v), want (%s, %v, %v)", i,
+				got.name, got.fitness, got.trained, individual.name, individual.fitness, individual.trained)
+		}
+		gotLayers, _ := EncodeLayers(got.Chain.Layers)
+		wantLayers, _ := EncodeLayers(individual.Chain.Layers)
+		if !reflect.DeepEqual(gotLayers, wantLayers) {
+			t.Errorf("individual %d layers = %+v, want %+v", i, gotLayers, wantLayers)
+		}
+	}
+	gotWeights, _ := encodeLearnables(restored.individuals[0].Learnables())
+	wantWeights, _ := encodeLearnables(species.individuals[0].Learnables())
+	if !reflect.DeepEqual(gotWeights, wantWeights) {
+		t.Errorf("weights of a trained individual were not restored")
+	}
+}
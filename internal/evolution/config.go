@@ -1,11 +1,47 @@
 package evolution
 
 type AdvancedConfig struct {
+	Seed uint64 // makes runs reproducible, a random seed is picked if 0
+
 	Epochs    int
 	BatchSize int
 
+	MaxConcurrency int // how many individuals are trained at once, GOMAXPROCS if not positive
+
 	MutationMultiplier float32
 
+	// chances of operators applied to a block picked for mutation, deletion excludes the rest
+	DeleteBlockChance        float32
+	InsertBlockChance        float32 // of a new random block of the same type after it
+	KernelMutationChance     float32 // of the kernel size changing by 1
+	StrideMutationChance     float32 // of the stride changing by 1
+	PadMutationChance        float32 // of the pad changing by 1
+	FiltersMutationChance    float32 // of the number of filters changing by 25%
+	ActivationMutationChance float32 // of a Conv2D or a hidden FC switching to another activation function
+	DenseSizeMutationChance  float32 // of a hidden FC growing or shrinking by 25%
+	DropoutMutationChance    float32 // of Dropout of a Conv2D or a hidden FC changing by 0.05 or switching on or off
+	BatchNormMutationChance  float32 // of batch normalization after a Conv2D switching on or off
+
+	Fitness     string  // one of "accuracy-loss", "final-accuracy", "best-accuracy", "macro-f1", "loss", "accuracy-size-penalty"
+	SizePenalty float32 // fitness lost per million parameters with "accuracy-size-penalty"
+
+	Selection        string // one of "tournament", "roulette", "rank", "truncation", "nsga2"
+	TournamentSize   int
+	TruncationRatio  float32
+	ParetoObjectives string // comma separated subset of "accuracy", "params", "flops", "latency"
+
+	EliteCount        int    // how many of the best individuals are carried over unchanged
+	NumCrossoverPairs int    // how many parent pairs are bred each generation
+	Crossover         string // one of "one-point", "uniform", "two-point", "head-body"
+
+	// "generational" breeds whole generations, "aging" is regularized evolution: a mutated child of the winner of
+	// a tournament of TournamentSize replaces the oldest individual as soon as it's trained.
+	// "random" and "grid" are baselines with the same budget: random search trains random individuals only,
+	// grid search cycles through all combinations of GridDepths and GridWidths with the rest of layers random
+	Strategy   string
+	GridDepths string // comma separated numbers of Conv2D layers
+	GridWidths string // comma separated numbers of filters of every Conv2D layer
+
 	MaxConvMaxPoolingPairs int
 	MaxConvOutput          int
 	MaxConvKernelSize      int
@@ -18,6 +54,53 @@ type AdvancedConfig struct {
 	MaxDenseSize           int
 	MinResolutionWidth     int
 	MinResolutionHeight    int
+
+	AvgPoolingChance float32 // share of pooling layers that average instead of taking the maximum
+	BatchNormChance  float32 // of a Conv2D being followed by batch normalization
+	DropoutChance    float32 // of a Conv2D or a hidden FC being followed by Dropout
+	MinDropout       float64
+	MaxDropout       float64
+
+	SkipChance float32 // of a Conv2D getting a skip connection from an earlier block, models stay sequential if 0
+
+	// gives every individual its own training hyperparameters within the ranges below, instead of Epochs,
+	// BatchSize and the default optimizer
+	EvolveHyperparameters bool
+	Optimizers            string // comma separated subset of "sgd", "adam", "rmsprop"
+	MinLearningRate       float64
+	MaxLearningRate       float64
+	MinBatchSize          int
+	MaxBatchSize          int
+	MaxL2                 float64 // weight decay, half of random individuals get none
+	MinEpochs             int
+	MaxEpochs             int
+
+	InheritWeights  bool // children start with trained weights of parents' layers of the same shapes
+	InheritedEpochs int  // how many epochs children that inherited weights are trained for at most, 0 means no limit
+
+	FitnessCache     bool // reuses fitness and weights of architectures that have already been trained the same way
+	CacheEvaluations int  // how many times an architecture is trained, with its fitness averaged, before it's reused
+
+	EarlyStoppingPatience int     // epochs without test accuracy improving before training stops, 0 never stops early
+	EarlyStoppingMinDelta float32 // least increase of test accuracy that counts as an improvement
+
+	// successive halving trains a generation in several rungs with growing epoch budgets, and only the best
+	// HalvingKeepRatio of individuals continue to the next rung. The last one is the full budget, 1 rung turns it off
+	HalvingRungs     int
+	HalvingKeepRatio float32
+
+	// the population is split into islands that evolve independently and every MigrationInterval generations send
+	// their best MigrationRate of individuals to neighbouring islands. 1 island turns it off
+	Islands           int
+	MigrationInterval int
+	MigrationRate     float32
+	MigrationTopology string // one of "ring", "full"
+
+	// NEAT-style speciation clusters individuals of similar structure into niches. Parent pairs are shared out
+	// between niches by the average fitness of their members, so that new structures mostly compete within their niche
+	Speciation             bool
+	CompatibilityThreshold float32 // structural distance, from 0 to 1, below which individuals share a niche
+	StagnationLimit        int     // generations without improvement after which a niche stops breeding, 0 never stops
 }
 
 func DefaultAdvancedConfig() AdvancedConfig {
@@ -25,8 +108,37 @@ func DefaultAdvancedConfig() AdvancedConfig {
 		Epochs:    5,
 		BatchSize: 10,
 
+		MaxConcurrency: 0,
+
 		MutationMultiplier: 1.0,
 
+		DeleteBlockChance:        0.2,
+		InsertBlockChance:        0.2,
+		KernelMutationChance:     0.4,
+		StrideMutationChance:     0.2,
+		PadMutationChance:        0.3,
+		FiltersMutationChance:    0.5,
+		ActivationMutationChance: 0.3,
+		DenseSizeMutationChance:  0.5,
+		DropoutMutationChance:    0.2,
+		BatchNormMutationChance:  0.1,
+
+		Fitness:     FitnessAccuracyLoss,
+		SizePenalty: 0.1,
+
+		Selection:        SelectionTournament,
+		TournamentSize:   3,
+		TruncationRatio:  0.5,
+		ParetoObjectives: "accuracy,params,flops,latency",
+
+		EliteCount:        1,
+		NumCrossoverPairs: 3,
+		Crossover:         CrossoverOnePoint,
+
+		Strategy:   StrategyGenerational,
+		GridDepths: "1,2,3",
+		GridWidths: "4,8,16",
+
 		MaxConvMaxPoolingPairs: 3,
 		MaxConvOutput:          16,
 		MaxConvKernelSize:      16,
@@ -39,5 +151,44 @@ func DefaultAdvancedConfig() AdvancedConfig {
 		MaxDenseSize:           512,
 		MinResolutionWidth:     3,
 		MinResolutionHeight:    3,
+
+		AvgPoolingChance: 0.3,
+		BatchNormChance:  0.2,
+		DropoutChance:    0.3,
+		MinDropout:       0.1,
+		MaxDropout:       0.5,
+
+		SkipChance: 0,
+
+		EvolveHyperparameters: false,
+		Optimizers:            "sgd,adam,rmsprop",
+		MinLearningRate:       0.0001,
+		MaxLearningRate:       0.01,
+		MinBatchSize:          8,
+		MaxBatchSize:          64,
+		MaxL2:                 0.001,
+		MinEpochs:             1,
+		MaxEpochs:             10,
+
+		InheritWeights:  false,
+		InheritedEpochs: 2,
+
+		FitnessCache:     true,
+		CacheEvaluations: 1,
+
+		EarlyStoppingPatience: 0,
+		EarlyStoppingMinDelta: 0.01,
+
+		HalvingRungs:     1,
+		HalvingKeepRatio: 0.5,
+
+		Islands:           1,
+		MigrationInterval: 5,
+		MigrationRate:     0.1,
+		MigrationTopology: TopologyRing,
+
+		Speciation:             false,
+		CompatibilityThreshold: 0.3,
+		StagnationLimit:        15,
 	}
 }
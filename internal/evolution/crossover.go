@@ -0,0 +1,217 @@
+package evolution
+
+import (
+	"fmt"
+	"golang.org/x/exp/rand"
+)
+
+const (
+	CrossoverOnePoint = "one-point"
+	CrossoverUniform  = "uniform"
+	CrossoverTwoPoint = "two-point"
+	CrossoverHeadBody = "head-body"
+)
+
+type UnknownCrossoverError struct {
+	crossover string
+}
+
+func (err UnknownCrossoverError) Error() string {
+	return fmt.Sprintf("unknown crossover method %q", err.crossover)
+}
+
+// CrossoverFunc recombines two genomes, the children may need their shapes repaired
+type CrossoverFunc func(rng *rand.Rand, genome, other Genome) (child1, child2 Genome)
+
+// NewCrossoverFunc returns the crossover operator named by advCfg.Crossover (one-point by default)
+func NewCrossoverFunc(advCfg AdvancedConfig) (CrossoverFunc, error) {
+	switch advCfg.Crossover {
+	case "", CrossoverOnePoint:
+		return func(rng *rand.Rand, genome, other Genome) (child1, child2 Genome) {
+			child1, child2, _, _ = genome.Crossover(rng, other)
+			return
+		}, nil
+	case CrossoverUniform:
+		return func(rng *rand.Rand, genome, other Genome) (Genome, Genome) {
+			return genome.UniformCrossover(rng, other)
+		}, nil
+	case CrossoverTwoPoint:
+		return func(rng *rand.Rand, genome, other Genome) (Genome, Genome) {
+			return genome.TwoPointCrossover(rng, other)
+		}, nil
+	case CrossoverHeadBody:
+		return func(rng *rand.Rand, genome, other Genome) (Genome, Genome) {
+			return genome.HeadBodyCrossover(rng, other)
+		}, nil
+	default:
+		return nil, UnknownCrossoverError{advCfg.Crossover}
+	}
+}
+
+// Recombine crosses the genomes over with the operator of advCfg and repairs shapes of the children
+func (genome Genome) Recombine(rng *rand.Rand, advCfg AdvancedConfig, other Genome) (child1, child2 Genome, err1, err2 error) {
+	crossover, err := NewCrossoverFunc(advCfg)
+	if err != nil {
+		return Genome{}, Genome{}, err, err
+	}
+	child1, child2 = crossover(rng, genome, other)
+	child1.repairShapes(advCfg)
+	child2.repairShapes(advCfg)
+	return child1, child2, child1.Validate(), child2.Validate()
+}
+
+// piece is a run of conv blocks of one of the parents
+type piece struct {
+	parent     int
+	start, end int
+}
+
+// featureBlocks splits conv blocks into feature extractor blocks: a Conv2D with the pooling blocks after it.
+// Pooling blocks before the first Conv2D make a block of their own
+func (genome Genome) featureBlocks(parent int) (blocks []piece) {
+	for i, block := range genome.ConvBlocks {
+		if i == 0 || block.Type == BlockConv2D {
+			blocks = append(blocks, piece{parent: parent, start: i})
+		}
+		blocks[len(blocks)-1].end = i + 1
+	}
+	return
+}
+
+// joinPieces concatenates conv blocks of the pieces. Skip connections keep pointing at the same blocks of a parent
+// if those come earlier in the result, and are removed otherwise
+func joinPieces(parents []Genome, pieces []piece) (blocks []ConvBlock) {
+	placed := make([]map[int]int, len(parents))
+	for i := range placed {
+		placed[i] = make(map[int]int)
+	}
+	for _, p := range pieces {
+		for i := p.start; i < p.end; i++ {
+			block := parents[p.parent].ConvBlocks[i]
+			if block.Skip != nil && block.Skip.From != -1 {
+				if from, ok := placed[p.parent][block.Skip.From]; ok {
+					block.Skip = &Skip{From: from, Mode: block.Skip.Mode}
+				} else {
+					block.Skip = nil
+				}
+			}
+			placed[p.parent][i] = len(blocks)
+			blocks = append(blocks, block)
+		}
+	}
+	return
+}
+
+// withConvBlocks returns clones of the genomes whose conv blocks are joined from pieces of both genomes.
+// If either genome has hyperparameters, each of them is swapped with a chance of 0.5
+func (genome Genome) withConvBlocks(rng *rand.Rand, other Genome, pieces1, pieces2 []piece) (child1, child2 Genome) {
+	parents := []Genome{genome, other}
+	child1, child2 = genome.clone(), other.clone()
+	child1.ConvBlocks, child2.ConvBlocks = joinPieces(parents, pieces1), joinPieces(parents, pieces2)
+	child1.Hyperparameters, child2.Hyperparameters = genome.crossoverHyperparameters(rng, other)
+	return
+}
+
+// crossoverHyperparameters swaps hyperparameters of the genomes if either of them has any
+func (genome Genome) crossoverHyperparameters(rng *rand.Rand, other Genome) (Hyperparameters, Hyperparameters) {
+	if genome.Hyperparameters == (Hyperparameters{}) && other.Hyperparameters == (Hyperparameters{}) {
+		return genome.Hyperparameters, other.Hyperparameters
+	}
+	return crossoverHyperparameters(rng, genome.Hyperparameters, other.Hyperparameters)
+}
+
+// UniformCrossover aligns feature extractor blocks of the genomes by their positions and swaps each aligned pair
+// with a chance of 0.5. Dense blocks stay where they are
+func (genome Genome) UniformCrossover(rng *rand.Rand, other Genome) (child1, child2 Genome) {
+	left, right := genome.featureBlocks(0), other.featureBlocks(1)
+	var pieces1, pieces2 []piece
+	for i := 0; i < len(left) || i < len(right); i++ {
+		if i < len(left) && i < len(right) && rng.Float32() < 0.5 {
+			pieces1, pieces2 = append(pieces1, right[i]), append(pieces2, left[i])
+			continue
+		}
+		if i < len(left) {
+			pieces1 = append(pieces1, left[i])
+		}
+		if i < len(right) {
+			pieces2 = append(pieces2, right[i])
+		}
+	}
+	return genome.withConvBlocks(rng, other, pieces1, pieces2)
+}
+
+// randomRange picks a random range [from, to) of n elements, possibly an empty one
+func randomRange(rng *rand.Rand, n int) (from, to int) {
+	from = rng.Intn(n + 1)
+	return from, from + rng.Intn(n-from+1)
+}
+
+// TwoPointCrossover swaps a random range of feature extractor blocks of the genome with a random range of
+// the other genome. Dense blocks stay where they are
+func (genome Genome) TwoPointCrossover(rng *rand.Rand, other Genome) (child1, child2 Genome) {
+	left, right := genome.featureBlocks(0), other.featureBlocks(1)
+	a, b := randomRange(rng, len(left))
+	c, d := randomRange(rng, len(right))
+	var pieces1, pieces2 []piece
+	pieces1 = append(append(append(pieces1, left[:a]...), right[c:d]...), left[b:]...)
+	pieces2 = append(append(append(pieces2, right[:c]...), left[a:b]...), right[d:]...)
+	return genome.withConvBlocks(rng, other, pieces1, pieces2)
+}
+
+// HeadBodyCrossover gives each child the conv blocks of one genome and the dense blocks of the other,
+// like CrossoverAlt, but also crosses hyperparameters over
+func (genome Genome) HeadBodyCrossover(rng *rand.Rand, other Genome) (child1, child2 Genome) {
+	child1, child2 = genome.CrossoverAlt(other)
+	child1.Hyperparameters, child2.Hyperparameters = genome.crossoverHyperparameters(rng, other)
+	return
+}
+
+// loosen makes the block reduce the resolution less by one step: a smaller stride, a smaller kernel or more padding.
+// Returns false if it can't be loosened within the limits of advCfg
+func (block *ConvBlock) loosen(advCfg AdvancedConfig) bool {
+	maxPad := advCfg.MaxPoolPad
+	if block.Type == BlockConv2D {
+		maxPad = advCfg.MaxConvPad
+	}
+	switch {
+	case block.Stride > 1:
+		block.Stride--
+	case block.Height > 2 || block.Width > 2:
+		block.Height, block.Width = clampInt(block.Height-1, 2, block.Height), clampInt(block.Width-1, 2, block.Width)
+	case block.Pad < maxPad && block.Pad+1 < block.Height && block.Pad+1 < block.Width:
+		block.Pad++
+	default:
+		return false
+	}
+	return true
+}
+
+// repairShapes loosens conv blocks of an invalid genome that leave less than the minimal resolution and deletes
+// the ones that can't be loosened enough. Skip connections that don't fit anymore are removed
+func (genome *Genome) repairShapes(advCfg AdvancedConfig) {
+	if genome.Validate() == nil {
+		return
+	}
+	res := genome.InputRes
+	for i := 0; i < len(genome.ConvBlocks); {
+		block := genome.ConvBlocks[i]
+		outputRes := res.After(block.layer(0))
+		for !outputRes.Validate(advCfg.MinResolutionWidth, advCfg.MinResolutionHeight) && block.loosen(advCfg) {
+			outputRes = res.After(block.layer(0))
+		}
+		if !outputRes.Validate(advCfg.MinResolutionWidth, advCfg.MinResolutionHeight) {
+			fmt.Println("repairing shapes: deleting", block.Type, "block", i)
+			genome.ConvBlocks = append(genome.ConvBlocks[:i:i], genome.ConvBlocks[i+1:]...)
+			genome.shiftSkips(i, -1)
+			continue
+		}
+		if block != genome.ConvBlocks[i] {
+			fmt.Printf("repairing shapes: %s block %d is now %dx%d, pad %d, stride %d\n",
+				block.Type, i, block.Height, block.Width, block.Pad, block.Stride)
+			genome.ConvBlocks[i] = block
+		}
+		res = outputRes
+		i++
+	}
+	genome.repairSkips()
+}
@@ -0,0 +1,114 @@
+package evolution
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newTestOtherGenome has feature extractor blocks of other shapes than newTestGenome, but the same input and classes
+func newTestOtherGenome() Genome {
+	return Genome{
+		InputRes: newTestGenome().InputRes,
+		Channels: 1,
+		ConvBlocks: []ConvBlock{
+			{Type: BlockConv2D, Output: 2, Height: 5, Width: 5, Pad: 2, Stride: 1, Activation: "LeakyReLU"},
+			{Type: BlockMaxPooling2D, Height: 4, Width: 4, Pad: 0, Stride: 2},
+			{Type: BlockConv2D, Output: 6, Height: 2, Width: 2, Pad: 0, Stride: 1, Activation: "ReLU"},
+		},
+		DenseBlocks: []DenseBlock{
+			{Output: 64, Activation: "ReLU"},
+			{Output: 5, Activation: "Sigmoid"},
+		},
+	}
+}
+
+func TestNewCrossoverFunc(t *testing.T) {
+	for _, crossover := range []string{"", CrossoverOnePoint, CrossoverUniform, CrossoverTwoPoint, CrossoverHeadBody} {
+		advCfg := DefaultAdvancedConfig()
+		advCfg.Crossover = crossover
+		if _, err := NewCrossoverFunc(advCfg); err != nil {
+			t.Errorf("NewCrossoverFunc(%q) error = %v", crossover, err)
+		}
+	}
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Crossover = "three-point"
+	if _, err := NewCrossoverFunc(advCfg); !errors.As(err, &UnknownCrossoverError{}) {
+		t.Errorf("NewCrossoverFunc(%q) error = %v, want UnknownCrossoverError", advCfg.Crossover, err)
+	}
+}
+
+func TestGenome_featureBlocks(t *testing.T) {
+	genome := newTestGenome()
+	genome.ConvBlocks = append([]ConvBlock{genome.ConvBlocks[1]}, genome.ConvBlocks...)
+	want := []piece{{1, 0, 1}, {1, 1, 3}, {1, 3, 4}}
+	if got := genome.featureBlocks(1); !reflect.DeepEqual(got, want) {
+		t.Errorf("featureBlocks() = %v, want %v", got, want)
+	}
+}
+
+func TestJoinPieces(t *testing.T) {
+	parents := []Genome{newTestSkipGenome(), newTestOtherGenome()}
+	blocks := joinPieces(parents, []piece{{0, 0, 2}, {1, 0, 1}, {0, 2, 3}})
+	if len(blocks) != 4 {
+		t.Fatalf("joinPieces() returned %d blocks, want 4", len(blocks))
+	}
+	if want := (Skip{From: -1, Mode: parents[0].ConvBlocks[0].Skip.Mode}); blocks[0].Skip == nil || *blocks[0].Skip != want {
+		t.Errorf("joinPieces() skip of the first block = %v, want %+v", blocks[0].Skip, want)
+	}
+	if blocks[3].Skip == nil || blocks[3].Skip.From != 0 {
+		t.Errorf("joinPieces() skip of the last block = %v, want one from 0", blocks[3].Skip)
+	}
+	if blocks = joinPieces(parents, []piece{{0, 2, 3}}); blocks[0].Skip != nil {
+		t.Errorf("joinPieces() kept a skip from a block that was left behind: %+v", *blocks[0].Skip)
+	}
+}
+
+func TestGenome_Recombine(t *testing.T) {
+	countConvBlocks := func(genomes ...Genome) (n int) {
+		for _, genome := range genomes {
+			n += len(genome.ConvBlocks)
+		}
+		return
+	}
+	for _, crossover := range []string{CrossoverOnePoint, CrossoverUniform, CrossoverTwoPoint, CrossoverHeadBody} {
+		t.Run(crossover, func(t *testing.T) {
+			advCfg := DefaultAdvancedConfig()
+			advCfg.Crossover = crossover
+			for i := 0; i < 50; i++ {
+				child1, child2, err1, err2 := newTestSkipGenome().Recombine(newTestRNG(), advCfg, newTestOtherGenome())
+				if err1 != nil || err2 != nil {
+					t.Fatalf("Recombine() errors = %v, %v", err1, err2)
+				}
+				if child1.NumClasses() != 5 || child2.NumClasses() != 5 {
+					t.Errorf("Recombine() children have %v and %v classes, want 5", child1.NumClasses(), child2.NumClasses())
+				}
+			}
+		})
+	}
+	for i := 0; i < 50; i++ {
+		child1, child2 := newTestGenome().TwoPointCrossover(newTestRNG(), newTestOtherGenome())
+		if got, want := countConvBlocks(child1, child2), countConvBlocks(newTestGenome(), newTestOtherGenome()); got != want {
+			t.Errorf("TwoPointCrossover() children have %v conv blocks in total, want %v", got, want)
+		}
+	}
+}
+
+func TestGenome_repairShapes(t *testing.T) {
+	// a 7x7 kernel doesn't fit into 8x6 left by the pooling block, 4x4 is the largest one that leaves 3x3
+	genome := newTestGenome()
+	genome.ConvBlocks[2].Height, genome.ConvBlocks[2].Width = 7, 7
+	genome.repairShapes(DefaultAdvancedConfig())
+	if err := genome.Validate(); err != nil {
+		t.Fatalf("Validate() after repairShapes() error = %v", err)
+	}
+	if block := genome.ConvBlocks[2]; block.Height != 4 || block.Width != 4 {
+		t.Errorf("repairShapes() loosened the kernel to %dx%d, want 4x4", block.Height, block.Width)
+	}
+
+	genome = newTestGenome()
+	genome.repairShapes(DefaultAdvancedConfig())
+	if !reflect.DeepEqual(genome, newTestGenome()) {
+		t.Errorf("repairShapes() changed a valid genome to %+v", genome)
+	}
+}
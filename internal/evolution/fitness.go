@@ -0,0 +1,118 @@
+package evolution
+
+import "fmt"
+
+const (
+	FitnessAccuracyLoss        = "accuracy-loss"
+	FitnessFinalAccuracy       = "final-accuracy"
+	FitnessBestAccuracy        = "best-accuracy"
+	FitnessMacroF1             = "macro-f1"
+	FitnessLoss                = "loss"
+	FitnessAccuracySizePenalty = "accuracy-size-penalty"
+)
+
+// TrainingHistory is what a FitnessFunc gets to judge an individual by.
+// Accuracies, MacroF1s and Losses hold a value per epoch, EvalDurations are in seconds
+type TrainingHistory struct {
+	Accuracies    []float32
+	MacroF1s      []float32
+	Losses        []float32
+	EvalDurations []float64
+	Objectives    Objectives // accuracy, size and latency of the trained model
+}
+
+func lastValue(values []float32) float32 {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[len(values)-1]
+}
+
+// FitnessFunc turns the training history of an individual into its fitness, the higher the better
+type FitnessFunc func(history TrainingHistory) float32
+
+type UnknownFitnessError struct {
+	fitness string
+}
+
+func (err UnknownFitnessError) Error() string {
+	return fmt.Sprintf("unknown fitness function %q", err.fitness)
+}
+
+// NewFitnessFunc returns the built-in fitness function named by advCfg.Fitness
+func NewFitnessFunc(advCfg AdvancedConfig) (FitnessFunc, error) {
+	switch advCfg.Fitness {
+	case FitnessAccuracyLoss, "":
+		return func(history TrainingHistory) float32 {
+			return lastValue(history.Accuracies)*1.0 + -1*lastValue(history.Losses)*0.5
+		}, nil
+	case FitnessFinalAccuracy:
+		return func(history TrainingHistory) float32 {
+			return lastValue(history.Accuracies)
+		}, nil
+	case FitnessBestAccuracy:
+		return func(history TrainingHistory) (best float32) {
+			for _, accuracy := range history.Accuracies {
+				if accuracy > best {
+					best = accuracy
+				}
+			}
+			return
+		}, nil
+	case FitnessMacroF1:
+		return func(history TrainingHistory) float32 {
+			return lastValue(history.MacroF1s)
+		}, nil
+	case FitnessLoss:
+		return func(history TrainingHistory) float32 {
+			return -lastValue(history.Losses)
+		}, nil
+	case FitnessAccuracySizePenalty:
+		penalty := advCfg.SizePenalty
+		return func(history TrainingHistory) float32 {
+			return lastValue(history.Accuracies) - penalty*float32(history.Objectives.NumParams)/1e6
+		}, nil
+	default:
+		return nil, UnknownFitnessError{advCfg.Fitness}
+	}
+}
+
+// confusionMatrix counts predictions, rows are true classes and columns are predicted ones
+type confusionMatrix [][]int
+
+func newConfusionMatrix(numClasses int) confusionMatrix {
+	matrix := make(confusionMatrix, numClasses)
+	for i := range matrix {
+		matrix[i] = make([]int, numClasses)
+	}
+	return matrix
+}
+
+func (matrix confusionMatrix) add(y, yHat []int) {
+	for i := range y {
+		matrix[y[i]][yHat[i]]++
+	}
+}
+
+// macroF1 is the F1 score averaged over classes, classes that never occur and are never predicted are skipped
+func (matrix confusionMatrix) macroF1() float32 {
+	var sum float64
+	var numClasses int
+	for class := range matrix {
+		var truePositives, actual, predicted int
+		for other := range matrix {
+			actual += matrix[class][other]
+			predicted += matrix[other][class]
+		}
+		truePositives = matrix[class][class]
+		if actual == 0 && predicted == 0 {
+			continue
+		}
+		numClasses++
+		sum += 2 * float64(truePositives) / float64(actual+predicted)
+	}
+	if numClasses == 0 {
+		return 0
+	}
+	return float32(sum / float64(numClasses))
+}
@@ -0,0 +1,74 @@
+package evolution
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestNewFitnessFunc(t *testing.T) {
+	history := TrainingHistory{
+		Accuracies: []float32{0.5, 0.9, 0.8},
+		MacroF1s:   []float32{0.4, 0.85, 0.7},
+		Losses:     []float32{1.0, 0.4, 0.6},
+		Objectives: Objectives{NumParams: 2_000_000},
+	}
+	tests := []struct {
+		name    string
+		fitness string
+		want    float32
+		wantErr bool
+	}{
+		{name: "default", fitness: "", want: 0.8 - 0.3},
+		{name: "accuracy-loss", fitness: FitnessAccuracyLoss, want: 0.8 - 0.3},
+		{name: "final-accuracy", fitness: FitnessFinalAccuracy, want: 0.8},
+		{name: "best-accuracy", fitness: FitnessBestAccuracy, want: 0.9},
+		{name: "macro-f1", fitness: FitnessMacroF1, want: 0.7},
+		{name: "loss", fitness: FitnessLoss, want: -0.6},
+		{name: "accuracy-size-penalty", fitness: FitnessAccuracySizePenalty, want: 0.8 - 0.1*2},
+		{name: "unknown", fitness: "happiness", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advCfg := DefaultAdvancedConfig()
+			advCfg.Fitness = tt.fitness
+			fitnessFunc, err := NewFitnessFunc(advCfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFitnessFunc() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.As(err, &UnknownFitnessError{}) {
+					t.Errorf("NewFitnessFunc() error = %v, want UnknownFitnessError", err)
+				}
+				return
+			}
+			if got := fitnessFunc(history); math.Abs(float64(got-tt.want)) > 1e-6 {
+				t.Errorf("fitness = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfusionMatrix_macroF1(t *testing.T) {
+	tests := []struct {
+		name       string
+		numClasses int
+		y, yHat    []int
+		want       float32
+	}{
+		{name: "perfect", numClasses: 3, y: []int{0, 1, 2, 2}, yHat: []int{0, 1, 2, 2}, want: 1},
+		{name: "all wrong", numClasses: 2, y: []int{0, 1}, yHat: []int{1, 0}, want: 0},
+		// class 0: tp 1, actual 2, predicted 1 -> 2/3; class 1: tp 2, actual 2, predicted 3 -> 4/5
+		{name: "mixed", numClasses: 2, y: []int{0, 0, 1, 1}, yHat: []int{0, 1, 1, 1}, want: (2.0/3 + 4.0/5) / 2},
+		{name: "absent class is skipped", numClasses: 3, y: []int{0, 1}, yHat: []int{0, 1}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matrix := newConfusionMatrix(tt.numClasses)
+			matrix.add(tt.y, tt.yHat)
+			if got := matrix.macroF1(); math.Abs(float64(got-tt.want)) > 1e-6 {
+				t.Errorf("macroF1() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
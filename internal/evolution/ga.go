@@ -3,10 +3,11 @@ package evolution
 import (
 	"context"
 	"fmt"
-	"github.com/m8u/goro/pkg/v1/layer"
+	"golang.org/x/exp/rand"
 	"gorgonia.org/tensor"
-	"log"
+	"runtime"
 	"sort"
+	"sotsuron/internal/utils"
 	"sync"
 	"time"
 )
@@ -14,157 +15,455 @@ import (
 type Species struct {
 	individuals          []*Individual
 	targetNumIndividuals int
+	generation           int
+	unreported           int // the first generation whose best hasn't been sent yet, islands continue from the one before
+
+	// what reseeded individuals are generated for
+	inputRes   utils.Resolution
+	numClasses int
+	grayscale  bool
+
+	seed      uint64
+	rng       *rand.Rand
+	rngSource *rand.PCGSource
+
+	checkpointPath string
+
+	cache *fitnessCache // created by Evolve, so that it lives as long as the process
+
+	islands []*Species // sub-populations that hold all the individuals instead, if there are several
+
+	// clusters of individuals of similar structure with advCfg.Speciation, they aren't saved in checkpoints
+	niches           []*niche
+	nextNicheID      int
+	nichesGeneration int // the last one speciated
+}
+
+// InvalidArchitectureError means that a starting architecture can't be used with the dataset
+type InvalidArchitectureError struct {
+	index int
+	err   error
+}
+
+func (err InvalidArchitectureError) Error() string {
+	return fmt.Sprintf("starting architecture %d: %v", err.index+1, err.err)
+}
+
+func (err InvalidArchitectureError) Unwrap() error {
+	return err.err
+}
+
+// NewSpecies creates a population of numIndividuals. Starting architectures, if any, are adapted to the dataset
+// and take the first slots, the rest is filled with random individuals. With config.Islands the population is
+// split into islands, and starting architectures are dealt out to them
+func NewSpecies(config AdvancedConfig, numIndividuals, inputWidth, inputHeight, numClasses int, grayscale bool, architectures ...Genome) (*Species, error) {
+	seed := config.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	fmt.Println("Seed:", seed)
+	if err := validateHyperparameterRanges(config); err != nil {
+		return nil, err
+	}
+	rngSource := &rand.PCGSource{}
+	rngSource.Seed(seed)
+	species := &Species{
+		targetNumIndividuals: numIndividuals,
+		inputRes:             utils.Resolution{Width: inputWidth, Height: inputHeight},
+		numClasses:           numClasses,
+		grayscale:            grayscale,
+		seed:                 seed,
+		rng:                  rand.New(rngSource),
+		rngSource:            rngSource,
+	}
+	if len(architectures) > numIndividuals {
+		fmt.Println("WARNING: more starting architectures than individuals, extra ones are ignored")
+		architectures = architectures[:numIndividuals]
+	}
+	channels := 3
+	if grayscale {
+		channels = 1
+	}
+	var starting []*Individual
+	for i, genome := range architectures {
+		adapted, err := genome.Adapt(species.inputRes, channels, numClasses)
+		if err != nil {
+			return nil, InvalidArchitectureError{i, err}
+		}
+		individual, err := compileGenome(config, adapted)
+		if err != nil {
+			return nil, InvalidArchitectureError{i, err}
+		}
+		starting = append(starting, individual)
+	}
+	if n := numIslands(config, numIndividuals); n > 1 {
+		if err := species.splitIntoIslands(config, n, starting); err != nil {
+			return nil, err
+		}
+		return species, nil
+	}
+	species.individuals = starting
+	if err := species.reseed(config); err != nil {
+		return nil, err
+	}
+	return species, nil
 }
 
-func NewSpecies(config AdvancedConfig, numIndividuals, inputWidth, inputHeight, numClasses int, grayscale bool) *Species {
-	species := &Species{targetNumIndividuals: numIndividuals}
-	species.individuals = make([]*Individual, numIndividuals)
+// reseed fills the population up to the target size with random individuals
+func (species *Species) reseed(advCfg AdvancedConfig) error {
+	numNew := species.targetNumIndividuals - len(species.individuals)
+	if numNew <= 0 {
+		return nil
+	}
+	newIndividuals := make([]*Individual, numNew)
+	errs := make([]error, numNew)
 	var wg sync.WaitGroup
-	for i := 0; i < numIndividuals; i++ {
+	for i := 0; i < numNew; i++ {
 		i := i
+		rng := species.newRNG()
 		wg.Add(1)
 		go func() {
-			species.individuals[i] = NewIndividual(config, inputWidth, inputHeight, numClasses, grayscale)
+			newIndividuals[i], errs[i] = NewIndividual(rng, advCfg, species.inputRes.Width, species.inputRes.Height, species.numClasses, species.grayscale)
 			wg.Done()
 		}()
 	}
 	wg.Wait()
-	return species
+	var err error
+	for i, individual := range newIndividuals {
+		if errs[i] != nil {
+			fmt.Println("WARNING:", errs[i].Error())
+			err = errs[i]
+			continue
+		}
+		species.individuals = append(species.individuals, individual)
+	}
+	if len(species.individuals) == 0 {
+		return err
+	}
+	return nil
+}
+
+// Seed is the seed the species was created with, it also determines how the dataset is shuffled
+func (species *Species) Seed() uint64 {
+	return species.seed
+}
+
+// newRNG derives a separate random stream from the species' one, so that goroutines don't interleave their draws
+func (species *Species) newRNG() *rand.Rand {
+	return rand.New(rand.NewSource(species.rng.Uint64()))
+}
+
+// maxReseeds is how many times in a row a generation may be reseeded before evolution gives up
+const maxReseeds = 3
+
+// ExtinctionError means that no individuals have survived a generation even after reseeding
+type ExtinctionError struct {
+	generation int
+}
+
+func (err ExtinctionError) Error() string {
+	return fmt.Sprintf("all individuals have died in generation %d", err.generation)
+}
+
+// maxConcurrency is how many individuals may be trained at the same time
+func maxConcurrency(advCfg AdvancedConfig) int {
+	if advCfg.MaxConcurrency > 0 {
+		return advCfg.MaxConcurrency
+	}
+	return runtime.GOMAXPROCS(0)
 }
 
 func (species *Species) Evolve(
 	ctx context.Context, advCfg AdvancedConfig, numGenerations int,
 	xTrain, yTrain, xTest, yTest tensor.Tensor,
-	progressChan chan Progress, allChartChan chan AllChartData, bestChartChan chan float32, bestLayersChan chan []layer.Config) {
+	progressChan chan Progress, allChartChan chan AllChartData, bestChartChan chan BestChartData, bestStructureChan chan BestStructure) error {
 
 	var err error
 	var mu sync.Mutex
 	var wg sync.WaitGroup
-	progress := Progress{}
+	progress := Progress{
+		Generation: species.generation,
+		Individual: species.generation * species.targetNumIndividuals,
+	}
+
+	selector, err := NewSelector(advCfg)
+	if err != nil {
+		fmt.Println("WARNING:", err.Error())
+		selector, _ = NewSelector(DefaultAdvancedConfig())
+	}
+	if _, err = NewFitnessFunc(advCfg); err != nil {
+		fmt.Println("WARNING:", err.Error())
+		advCfg.Fitness = DefaultAdvancedConfig().Fitness
+	}
+	if _, err = NewCrossoverFunc(advCfg); err != nil {
+		fmt.Println("WARNING:", err.Error())
+		advCfg.Crossover = DefaultAdvancedConfig().Crossover
+	}
+
+	if err = validateStrategy(advCfg.Strategy); err != nil {
+		fmt.Println("WARNING:", err.Error())
+		advCfg.Strategy = DefaultAdvancedConfig().Strategy
+	}
+	// a range of hyperparameters has no sensible fallback, so evolution doesn't start
+	if err = validateHyperparameterRanges(advCfg); err != nil {
+		if progressChan != nil {
+			progress.Generation = -1
+			progressChan <- progress
+		}
+		return err
+	}
+
+	if species.cache == nil {
+		species.cache = newFitnessCache()
+	}
+	if len(species.islands) > 0 {
+		return species.evolveIslands(ctx, advCfg, numGenerations, xTrain, yTrain, xTest, yTest,
+			progressChan, allChartChan, bestChartChan, bestStructureChan)
+	}
+	if advCfg.Strategy != StrategyGenerational && advCfg.Speciation {
+		fmt.Println("WARNING: speciation only works with the generational strategy")
+	}
+	switch advCfg.Strategy {
+	case StrategyAging:
+		return species.evolveAging(ctx, advCfg, numGenerations, xTrain, yTrain, xTest, yTest,
+			progressChan, allChartChan, bestChartChan, bestStructureChan)
+	case StrategyRandom:
+		return species.evolveRandom(ctx, advCfg, numGenerations, xTrain, yTrain, xTest, yTest,
+			progressChan, allChartChan, bestChartChan, bestStructureChan)
+	case StrategyGrid:
+		return species.evolveGrid(ctx, advCfg, numGenerations, xTrain, yTrain, xTest, yTest,
+			progressChan, allChartChan, bestChartChan, bestStructureChan)
+	}
 
 	start := time.Now()
-	for i := 0; i < numGenerations; i++ {
-		fmt.Printf("===================================== Generation %d =====================================\n", i)
-		// calculate fitness for each individual
-		for _, individual := range species.individuals {
-			if individual.trained {
-				fmt.Printf("Skipping %v\n", individual.name)
-				progress.Individual++
-				continue
+	firstGeneration := species.generation
+	for i := firstGeneration; i < numGenerations; i++ {
+		species.generation = i
+		if i >= species.unreported {
+			fmt.Printf("===================================== Generation %d =====================================\n", i)
+		}
+		for reseeds := 0; ; reseeds++ {
+			var untrained []*Individual
+			for _, individual := range species.individuals {
+				if individual.trained {
+					fmt.Printf("Skipping %v\n", individual.name)
+					mu.Lock()
+					progress.Individual++
+					mu.Unlock()
+					continue
+				}
+				untrained = append(untrained, individual)
 			}
-			individual := individual
-			wg.Add(1)
-			go func() {
-				fitness, err := individual.CalculateFitnessBatch(ctx, allChartChan, advCfg, xTrain, yTrain, xTest, yTest)
-				if err != nil {
-					//fmt.Println("WARNING:", err.Error())
-					fmt.Println(individual.name, "has died")
-				} else {
-					individual.trained = true
-					individual.fitness = fitness
+			progress.Dropped, progress.StoppedEarly = 0, 0
+
+			// train in rungs of successive halving, there is one rung with the whole epoch budget if it's off
+			rungs := halvingRungs(advCfg)
+			for rung, training := 0, untrained; rung < rungs && len(training) > 0 && ctx.Err() == nil; rung++ {
+				progress.Rung = rung
+				// calculate fitness for each individual, at most maxConcurrency of them are trained at once
+				queue := make(chan *Individual)
+				for w := 0; w < maxConcurrency(advCfg); w++ {
+					wg.Add(1)
+					go func(rung int) {
+						defer wg.Done()
+						for individual := range queue {
+							stoppedEarly := false
+							if rung > 0 || !species.cache.reuse(advCfg, individual) {
+								epochs := individual.rungEpochs(advCfg, rung, rungs)
+								fitness, err := individual.trainUpTo(ctx, allChartChan, advCfg, epochs, xTrain, yTrain, xTest, yTest)
+								if err != nil {
+									//fmt.Println("WARNING:", err.Error())
+									fmt.Println(individual.name, "has died")
+									individual.trained = false
+								} else {
+									individual.trained = true
+									individual.fitness = fitness
+									stoppedEarly = individual.stoppedEarly
+									if individual.finished(advCfg) {
+										species.cache.store(advCfg, individual)
+									}
+								}
+							}
+							mu.Lock()
+							if !individual.trained || individual.finished(advCfg) {
+								progress.Individual++
+							}
+							if stoppedEarly {
+								progress.StoppedEarly++
+							}
+							progress.CacheHitRate = species.cache.hitRate()
+							select {
+							case progressChan <- progress:
+							default:
+							}
+							mu.Unlock()
+						}
+					}(rung)
 				}
-				mu.Lock()
-				progress.Individual++
-				select {
-				case progressChan <- progress:
-				default:
+				for _, individual := range training {
+					queue <- individual
 				}
+				close(queue)
+				wg.Wait()
+
+				kept, dropped := halve(advCfg, training)
+				mu.Lock()
+				progress.Individual += len(dropped)
+				progress.Dropped += len(dropped)
 				mu.Unlock()
-				wg.Done()
-			}()
-		}
-		wg.Wait()
+				training = kept
+			}
 
-		select {
-		case <-ctx.Done():
-			fmt.Println("ABORTING")
-			progress.Generation = -1
-			progressChan <- progress
-			return
-		default:
-		}
+			select {
+			case <-ctx.Done():
+				fmt.Println("ABORTING")
+				if progressChan != nil {
+					progress.Generation = -1
+					progressChan <- progress
+				}
+				return nil
+			default:
+			}
 
-		// eliminate individuals that have failed to train
-		for i := 0; i < len(species.individuals); i++ {
-			if !species.individuals[i].trained {
-				species.individuals[i].DisposeVMs()
-				species.individuals = append(species.individuals[:i], species.individuals[i+1:]...)
-				i--
+			// eliminate individuals that have failed to train
+			for i := 0; i < len(species.individuals); i++ {
+				if !species.individuals[i].trained {
+					species.individuals[i].DisposeVMs()
+					species.individuals = append(species.individuals[:i], species.individuals[i+1:]...)
+					i--
+				}
+			}
+			if len(species.individuals) >= 2 {
+				break
+			}
+			// recover from extinction by replacing the dead with random individuals
+			if reseeds == maxReseeds {
+				if progressChan != nil {
+					progress.Generation = -1
+					progressChan <- progress
+				}
+				return ExtinctionError{i}
+			}
+			fmt.Println("WARNING: there are no at least 2 individuals left, reseeding")
+			if err = species.reseed(advCfg); err != nil {
+				fmt.Println("WARNING:", err.Error())
 			}
+			progress.Individual = i * species.targetNumIndividuals
 		}
-		// if there are no at least 2 individuals, then crash
-		if len(species.individuals) < 2 {
-			log.Fatalln("There are no at least 2 individuals left")
+		// rank individuals, best ones go first
+		if nsga2, ok := selector.(NSGA2Selector); ok {
+			species.individuals = sortByDominance(species.individuals, nsga2.Objectives)
+		} else {
+			sort.Slice(species.individuals, func(i, j int) bool {
+				return species.individuals[i].fitness > species.individuals[j].fitness
+			})
 		}
-		// find 2 best individuals
-		sort.Slice(species.individuals, func(i, j int) bool {
-			return species.individuals[i].fitness > species.individuals[j].fitness
-		})
-		parent1, parent2 := species.individuals[0], species.individuals[1]
-		select {
-		case bestChartChan <- parent1.fitness:
-		default:
+		if advCfg.Speciation {
+			species.speciate(advCfg)
+			progress.Niches = len(species.niches)
+		}
+		best := species.Best()
+		if i >= species.unreported {
+			select {
+			case bestChartChan <- BestChartData{Generation: i, Fitness: best.fitness}:
+			default:
+			}
+			species.unreported = i + 1
 		}
 		select {
-		case bestLayersChan <- parent1.Chain.Layers:
+		case bestStructureChan <- BestStructure{best.Chain.Layers, best.genome.Hyperparameters.Resolve(advCfg), species.nicheBests(advCfg)}:
 		default:
 		}
 
 		if i == numGenerations-1 {
+			species.maybeSaveCheckpoint(advCfg)
 			if progressChan != nil {
 				progress.Generation = -1
 				progressChan <- progress
 			}
-			return
+			return nil
 		}
 
+		// carry elites over unchanged
+		eliteCount := tensor.MinInt(tensor.MaxInt(advCfg.EliteCount, 0), len(species.individuals))
+		var newGeneration []*Individual
+		newGeneration = append(newGeneration, species.individuals[:eliteCount]...)
+
 		// dispose VMs of obsolete individuals because for an unknown reason GC won't do it
-		for _, individual := range species.individuals[1:] {
+		for _, individual := range species.individuals[eliteCount:] {
 			individual.DisposeVMs()
 		}
 
-		// crossover
-		child1, child2, err1, err2 := parent1.Crossover(advCfg, parent2)
-		if err1 != nil && err2 != nil {
-			// if crossover failed, use alternative method
-			child1, child2, err1, err2 = parent1.CrossoverAlt(advCfg, parent2)
+		// breed children of several parent pairs, each child starts its own lineage
+		type lineage struct {
+			ancestor       *Individual
+			mutationChance float32
 		}
+		var lineages []lineage
+		numPairs := tensor.MaxInt(advCfg.NumCrossoverPairs, 1)
+		for _, pair := range species.selectParentPairs(species.rng, advCfg, selector, numPairs) {
+			parent1, parent2 := pair[0], pair[1]
+			mutationChance := (1 - (parent1.fitness+parent2.fitness)/2) * advCfg.MutationMultiplier
 
-		var newGeneration []*Individual
-		newGeneration = append(newGeneration, parent1, child1, child2)
-		// mutate N times to fill the rest of new generation
-		mutationChance := (1 - (parent1.fitness+parent2.fitness)/2) * advCfg.MutationMultiplier
-		fmt.Printf(">>>>>> Mutation chance: %v\n", mutationChance) // TODO: запилить тост об ошибке
+			// crossover
+			child1, child2, err1, err2 := parent1.Crossover(species.newRNG(), advCfg, parent2)
+			if err1 != nil && err2 != nil {
+				// if crossover failed, use alternative method
+				child1, child2, err1, err2 = parent1.CrossoverAlt(advCfg, parent2)
+			}
+			if err1 != nil && err2 != nil {
+				// mutate the parents themselves if they can't produce any children
+				lineages = append(lineages, lineage{parent1, mutationChance}, lineage{parent2, mutationChance})
+				continue
+			}
+			for _, child := range []struct {
+				individual *Individual
+				err        error
+			}{{child1, err1}, {child2, err2}} {
+				if child.err != nil || len(newGeneration) >= species.targetNumIndividuals {
+					continue
+				}
+				newGeneration = append(newGeneration, child.individual)
+				lineages = append(lineages, lineage{child.individual, mutationChance})
+			}
+		}
+
+		// mutate N times to fill the rest of new generation, taking turns between lineages
 		var mutated *Individual
 		for i := 0; len(newGeneration) < species.targetNumIndividuals && i < species.targetNumIndividuals*3; i++ {
-			fmt.Println("Mutating", i)
-			if i%2 == 0 {
-				mutated, err = child1.Mutate(advCfg, mutationChance)
-			} else {
-				mutated, err = child2.Mutate(advCfg, mutationChance)
-			}
+			ancestor := lineages[i%len(lineages)]
+			fmt.Printf("Mutating %d (mutation chance: %v)\n", i, ancestor.mutationChance)
+			mutated, err = ancestor.ancestor.Mutate(species.newRNG(), advCfg, ancestor.mutationChance)
 			if err == nil {
 				newGeneration = append(newGeneration, mutated)
 			} else {
 				//fmt.Println("WARNING:", err.Error())
 				fmt.Println("did not survive mutation")
 			}
-
 		}
 		species.individuals = newGeneration
+		species.generation = i + 1
+		species.maybeSaveCheckpoint(advCfg)
 		progress.Generation++
-		progress.ETASeconds = time.Since(start).Seconds() / float64(i+1) * float64(numGenerations-i-1)
+		progress.ETASeconds = time.Since(start).Seconds() / float64(i-firstGeneration+1) * float64(numGenerations-i-1)
 		select {
 		case progressChan <- progress:
 		default:
 		}
 	}
-	return
+	return nil
 }
 
-// Best returns the best individual
+// Best returns the individual with the highest fitness of all islands
 func (species *Species) Best() *Individual {
-	return species.individuals[0]
+	population := species.population()
+	best := population[0]
+	for _, individual := range population[1:] {
+		if individual.trained && (!best.trained || individual.fitness > best.fitness) {
+			best = individual
+		}
+	}
+	return best
 }
 
 // TODO старые графики в allChart не удаляются (вроде как только если через браузер открывать)
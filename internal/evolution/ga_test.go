@@ -2,6 +2,8 @@ package evolution
 
 import (
 	"context"
+	"errors"
+	"reflect"
 	"sotsuron/internal/datasets"
 	"sotsuron/internal/utils"
 	"testing"
@@ -43,11 +45,95 @@ func TestSpecies_Evolve(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			species := NewSpecies(DefaultAdvancedConfig(), tt.fields.numIndividuals, tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, tt.fields.grayscale)
-			species.Evolve(context.Background(), DefaultAdvancedConfig(), tt.args.numGenerations,
+			species, err := NewSpecies(DefaultAdvancedConfig(), tt.fields.numIndividuals, tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, tt.fields.grayscale)
+			utils.MaybeCrash(err)
+			err = species.Evolve(context.Background(), DefaultAdvancedConfig(), tt.args.numGenerations,
 				xTrain, yTrain, xTest, yTest,
 				nil, nil, nil, nil,
 			)
+			if err != nil {
+				t.Errorf("Evolve() error = %v", err)
+			}
 		})
 	}
 }
+
+func TestNewSpecies_seed(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Seed = 42
+	species, err := NewSpecies(advCfg, 4, 16, 16, 3, true)
+	utils.MaybeCrash(err)
+	other, err := NewSpecies(advCfg, 4, 16, 16, 3, true)
+	utils.MaybeCrash(err)
+	if species.Seed() != advCfg.Seed {
+		t.Errorf("Seed() = %v, want %v", species.Seed(), advCfg.Seed)
+	}
+	for i := range species.individuals {
+		got, want := SimplifyLayers(other.individuals[i].Chain.Layers), SimplifyLayers(species.individuals[i].Chain.Layers)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("individual %v of species with the same seed = %v, want %v", i, got, want)
+		}
+	}
+
+	mutated, err := species.individuals[0].Mutate(species.newRNG(), advCfg, 1)
+	if err != nil {
+		t.Skipf("could not mutate: %v", err)
+	}
+	otherMutated, err := other.individuals[0].Mutate(other.newRNG(), advCfg, 1)
+	if err != nil {
+		t.Fatalf("Mutate() with the same seed failed: %v", err)
+	}
+	if got, want := SimplifyLayers(otherMutated.Chain.Layers), SimplifyLayers(mutated.Chain.Layers); !reflect.DeepEqual(got, want) {
+		t.Errorf("Mutate() with the same seed = %v, want %v", got, want)
+	}
+}
+
+func TestSpecies_reseed(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	species, err := NewSpecies(advCfg, 4, 16, 16, 3, true)
+	utils.MaybeCrash(err)
+	survivor := species.individuals[2]
+	species.individuals = species.individuals[2:3]
+	if err = species.reseed(advCfg); err != nil {
+		t.Fatalf("reseed() error = %v", err)
+	}
+	if len(species.individuals) != species.targetNumIndividuals {
+		t.Errorf("reseed() left %v individuals, want %v", len(species.individuals), species.targetNumIndividuals)
+	}
+	if species.individuals[0] != survivor {
+		t.Errorf("reseed() must keep surviving individuals")
+	}
+}
+
+func TestNewSpecies_architectures(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	var architectures []Genome
+	for _, name := range []string{"lenet", "mlp"} {
+		genome, err := ParseArchitecture([]byte(ArchitectureTemplates[name]), FormatDSL, utils.Resolution{Width: 28, Height: 28}, 3)
+		if err != nil {
+			t.Fatalf("ParseArchitecture(%q) error = %v", name, err)
+		}
+		architectures = append(architectures, genome)
+	}
+	species, err := NewSpecies(advCfg, 4, 32, 32, 3, true, architectures...)
+	if err != nil {
+		t.Fatalf("NewSpecies() error = %v", err)
+	}
+	if len(species.individuals) != 4 {
+		t.Errorf("NewSpecies() created %v individuals, want 4", len(species.individuals))
+	}
+	for i, architecture := range architectures {
+		genome := species.individuals[i].Genome()
+		if genome.Channels != 1 || genome.InputRes != species.inputRes || genome.NumClasses() != 3 {
+			t.Errorf("individual %v is not adapted to the dataset: %+v", i, genome)
+		}
+		if !reflect.DeepEqual(genome.ConvBlocks, architecture.ConvBlocks) {
+			t.Errorf("individual %v conv blocks = %+v, want %+v", i, genome.ConvBlocks, architecture.ConvBlocks)
+		}
+	}
+
+	_, err = NewSpecies(advCfg, 4, 8, 8, 3, true, architectures...)
+	if !errors.As(err, &InvalidArchitectureError{}) {
+		t.Errorf("NewSpecies() with too small images error = %v, want InvalidArchitectureError", err)
+	}
+}
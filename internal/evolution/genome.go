@@ -0,0 +1,641 @@
+package evolution
+
+import (
+	"fmt"
+	"github.com/m8u/goro/pkg/v1/layer"
+	"golang.org/x/exp/rand"
+	"sotsuron/internal/nn"
+	"sotsuron/internal/utils"
+)
+
+const (
+	BlockConv2D       = "Conv2D"
+	BlockMaxPooling2D = "MaxPooling2D"
+	BlockAvgPooling2D = "AvgPooling2D"
+)
+
+// ConvBlock is a layer before the flatten point of a genome
+type ConvBlock struct {
+	Type       string // BlockConv2D, BlockMaxPooling2D or BlockAvgPooling2D
+	Output     int    `json:",omitempty"` // number of filters, Conv2D only
+	Height     int    // of the kernel
+	Width      int
+	Pad        int
+	Stride     int
+	Activation string `json:",omitempty"` // Conv2D only
+
+	// Conv2D only, the layer is followed by batch normalization and then by Dropout if Dropout is positive
+	BatchNorm bool    `json:",omitempty"`
+	Dropout   float64 `json:",omitempty"`
+
+	// Conv2D only, merges the output of an earlier block after batch normalization and before Dropout.
+	// It is never modified in place, so that copies of genomes can share it
+	Skip *Skip `json:",omitempty"`
+}
+
+// Skip connects a conv block to the output of an earlier one. If the earlier output has a higher resolution,
+// its center is cropped
+type Skip struct {
+	From int    // index of the earlier conv block, -1 for the input
+	Mode string // nn.MergeAdd or nn.MergeConcat
+}
+
+// DenseBlock is a fully connected layer after the flatten point
+type DenseBlock struct {
+	Output     int
+	Activation string
+	Dropout    float64 `json:",omitempty"` // probability of Dropout after the layer, hidden layers only
+}
+
+// Genome describes an architecture without the sizes that can be derived from it, like inputs of layers,
+// and how to train it. The last dense block is the output layer
+type Genome struct {
+	InputRes        utils.Resolution
+	Channels        int // of input images
+	ConvBlocks      []ConvBlock
+	DenseBlocks     []DenseBlock
+	Hyperparameters Hyperparameters
+}
+
+type InvalidGenomeError struct {
+	reason string
+}
+
+func (err InvalidGenomeError) Error() string {
+	return fmt.Sprintf("invalid genome: %s", err.reason)
+}
+
+// EncodeGenome builds a genome from layers of a model that takes images of inputRes with given number of channels
+func EncodeGenome(layers []layer.Config, inputRes utils.Resolution, channels int) (genome Genome, err error) {
+	genome = Genome{InputRes: inputRes, Channels: channels}
+	flattened := false
+	for _, l := range layers {
+		switch l := l.(type) {
+		case layer.Conv2D:
+			if flattened {
+				return Genome{}, InvalidGenomeError{"Conv2D after Flatten"}
+			}
+			genome.ConvBlocks = append(genome.ConvBlocks, ConvBlock{
+				Type:       BlockConv2D,
+				Output:     l.Output,
+				Height:     l.Height,
+				Width:      l.Width,
+				Pad:        l.Pad[0],
+				Stride:     l.Stride[0],
+				Activation: activationFnToString(l.Activation),
+			})
+		case layer.MaxPooling2D:
+			if flattened {
+				return Genome{}, InvalidGenomeError{"MaxPooling2D after Flatten"}
+			}
+			genome.ConvBlocks = append(genome.ConvBlocks, ConvBlock{
+				Type:   BlockMaxPooling2D,
+				Height: l.Kernel[0],
+				Width:  l.Kernel[1],
+				Pad:    l.Pad[0],
+				Stride: l.Stride[0],
+			})
+		case nn.AvgPooling2D:
+			if flattened {
+				return Genome{}, InvalidGenomeError{"AvgPooling2D after Flatten"}
+			}
+			genome.ConvBlocks = append(genome.ConvBlocks, ConvBlock{
+				Type:   BlockAvgPooling2D,
+				Height: l.Kernel[0],
+				Width:  l.Kernel[1],
+				Pad:    l.Pad[0],
+				Stride: l.Stride[0],
+			})
+		case nn.BatchNorm:
+			last := len(genome.ConvBlocks) - 1
+			if flattened || last < 0 || genome.ConvBlocks[last].Type != BlockConv2D ||
+				genome.ConvBlocks[last].BatchNorm || genome.ConvBlocks[last].Dropout > 0 || genome.ConvBlocks[last].Skip != nil {
+				return Genome{}, InvalidGenomeError{"BatchNorm must directly follow a Conv2D"}
+			}
+			genome.ConvBlocks[last].BatchNorm = true
+		case nn.Dropout:
+			if flattened {
+				last := len(genome.DenseBlocks) - 1
+				if last < 0 || genome.DenseBlocks[last].Dropout > 0 {
+					return Genome{}, InvalidGenomeError{"Dropout must follow an FC"}
+				}
+				genome.DenseBlocks[last].Dropout = l.Probability
+				continue
+			}
+			last := len(genome.ConvBlocks) - 1
+			if last < 0 || genome.ConvBlocks[last].Type != BlockConv2D || genome.ConvBlocks[last].Dropout > 0 {
+				return Genome{}, InvalidGenomeError{"Dropout must follow a Conv2D"}
+			}
+			genome.ConvBlocks[last].Dropout = l.Probability
+		case nn.SkipSource:
+			// sources are derived from merges on decoding
+		case nn.SkipMerge:
+			last := len(genome.ConvBlocks) - 1
+			if flattened || last < 0 || genome.ConvBlocks[last].Type != BlockConv2D ||
+				genome.ConvBlocks[last].Dropout > 0 || genome.ConvBlocks[last].Skip != nil {
+				return Genome{}, InvalidGenomeError{"SkipMerge must follow a Conv2D or its BatchNorm"}
+			}
+			genome.ConvBlocks[last].Skip = &Skip{From: l.From, Mode: l.Mode}
+		case layer.Flatten:
+			flattened = true
+		case layer.FC:
+			if !flattened {
+				return Genome{}, InvalidGenomeError{"FC before Flatten"}
+			}
+			genome.DenseBlocks = append(genome.DenseBlocks, DenseBlock{
+				Output:     l.Output,
+				Activation: activationFnToString(l.Activation),
+			})
+		default:
+			return Genome{}, UnknownLayerError{fmt.Sprintf("%T", l)}
+		}
+	}
+	return genome, genome.Validate()
+}
+
+// NumClasses is the size of the output layer
+func (genome Genome) NumClasses() int {
+	if len(genome.DenseBlocks) == 0 {
+		return 0
+	}
+	return genome.DenseBlocks[len(genome.DenseBlocks)-1].Output
+}
+
+// Adapt returns a copy of the genome for images of inputRes with given number of channels, classifying
+// them into numClasses. The FC input is derived from the resolution on decoding, so only the output changes
+func (genome Genome) Adapt(inputRes utils.Resolution, channels, numClasses int) (Genome, error) {
+	genome = genome.clone()
+	genome.InputRes = inputRes
+	genome.Channels = channels
+	if len(genome.DenseBlocks) > 0 {
+		genome.DenseBlocks[len(genome.DenseBlocks)-1].Output = numClasses
+	}
+	return genome, genome.Validate()
+}
+
+// Validate checks that the genome decodes to a model that can be compiled
+func (genome Genome) Validate() error {
+	if genome.Channels <= 0 {
+		return InvalidGenomeError{"no input channels"}
+	}
+	if genome.InputRes.Width <= 0 || genome.InputRes.Height <= 0 {
+		return InvalidGenomeError{fmt.Sprintf("input resolution %s", genome.InputRes.String())}
+	}
+	// shapes of skip connections are only checked once all blocks are known to have a valid geometry,
+	// a stride of 0 would make resolutions divide by zero
+	res := genome.InputRes
+	for i, block := range genome.ConvBlocks {
+		switch block.Type {
+		case BlockConv2D:
+			if block.Output <= 0 {
+				return InvalidGenomeError{fmt.Sprintf("conv block %d has no filters", i)}
+			}
+			if _, err := activationFnFromString(block.Activation); err != nil {
+				return InvalidGenomeError{fmt.Sprintf("conv block %d: %v", i, err)}
+			}
+		case BlockMaxPooling2D, BlockAvgPooling2D:
+			if block.BatchNorm || block.Dropout != 0 || block.Skip != nil {
+				return InvalidGenomeError{fmt.Sprintf("conv block %d is a pooling one, but has BatchNorm, Dropout or a skip connection", i)}
+			}
+		default:
+			return InvalidGenomeError{fmt.Sprintf("conv block %d has unknown type %q", i, block.Type)}
+		}
+		if block.Dropout < 0 || block.Dropout >= 1 {
+			return InvalidGenomeError{fmt.Sprintf("conv block %d has dropout probability %v", i, block.Dropout)}
+		}
+		if block.Height <= 0 || block.Width <= 0 || block.Stride <= 0 || block.Pad < 0 {
+			return InvalidGenomeError{fmt.Sprintf("conv block %d has invalid geometry %+v", i, block)}
+		}
+		if res = res.After(block.layer(0)); res.Width <= 0 || res.Height <= 0 {
+			return InvalidGenomeError{fmt.Sprintf("resolution after conv block %d is %s", i, res.String())}
+		}
+	}
+	shapes := genome.convShapes()
+	for i, block := range genome.ConvBlocks {
+		if block.Skip != nil {
+			if err := genome.skipError(i, *block.Skip, shapes); err != nil {
+				return err
+			}
+		}
+	}
+	if len(genome.DenseBlocks) == 0 {
+		return InvalidGenomeError{"no dense blocks"}
+	}
+	for i, block := range genome.DenseBlocks {
+		if block.Output <= 0 {
+			return InvalidGenomeError{fmt.Sprintf("dense block %d has no outputs", i)}
+		}
+		if _, err := activationFnFromString(block.Activation); err != nil {
+			return InvalidGenomeError{fmt.Sprintf("dense block %d: %v", i, err)}
+		}
+		if block.Dropout < 0 || block.Dropout >= 1 {
+			return InvalidGenomeError{fmt.Sprintf("dense block %d has dropout probability %v", i, block.Dropout)}
+		}
+	}
+	if genome.DenseBlocks[len(genome.DenseBlocks)-1].Dropout != 0 {
+		return InvalidGenomeError{"the output layer is followed by Dropout"}
+	}
+	return genome.Hyperparameters.validate()
+}
+
+// layer converts the block to a layer config that takes input channels
+func (block ConvBlock) layer(input int) layer.Config {
+	switch block.Type {
+	case BlockMaxPooling2D:
+		return layer.MaxPooling2D{
+			Kernel: []int{block.Height, block.Width},
+			Pad:    SquareShapeSlice(block.Pad),
+			Stride: SquareShapeSlice(block.Stride),
+		}
+	case BlockAvgPooling2D:
+		return nn.AvgPooling2D{
+			Kernel: []int{block.Height, block.Width},
+			Pad:    SquareShapeSlice(block.Pad),
+			Stride: SquareShapeSlice(block.Stride),
+		}
+	}
+	activation, _ := activationFnFromString(block.Activation)
+	return layer.Conv2D{
+		Input:      input,
+		Output:     block.Output,
+		Height:     block.Height,
+		Width:      block.Width,
+		Activation: activation,
+		Pad:        SquareShapeSlice(block.Pad),
+		Stride:     SquareShapeSlice(block.Stride),
+	}
+}
+
+// outputChannels returns the number of channels after the block given the number before it, not counting a skip connection
+func (block ConvBlock) outputChannels(input int) int {
+	if block.Type == BlockConv2D {
+		return block.Output
+	}
+	return input
+}
+
+// convShape is the resolution and the number of channels of a tensor between conv blocks
+type convShape struct {
+	res      utils.Resolution
+	channels int
+}
+
+// convShapes returns the shape of the input followed by shapes of outputs of conv blocks,
+// so that the output of block i is at i+1. Invalid skip connections are not counted
+func (genome Genome) convShapes() []convShape {
+	shapes := []convShape{{genome.InputRes, genome.Channels}}
+	for i, block := range genome.ConvBlocks {
+		shape := convShape{shapes[i].res.After(block.layer(0)), block.outputChannels(shapes[i].channels)}
+		if skip := block.Skip; skip != nil && skip.Mode == nn.MergeConcat && skip.From >= -1 && skip.From < i {
+			shape.channels += shapes[skip.From+1].channels
+		}
+		shapes = append(shapes, shape)
+	}
+	return shapes
+}
+
+// skipError tells why conv block i can't have the skip connection given shapes returned by convShapes
+func (genome Genome) skipError(i int, skip Skip, shapes []convShape) error {
+	block := genome.ConvBlocks[i]
+	if block.Type != BlockConv2D {
+		return InvalidGenomeError{fmt.Sprintf("conv block %d is a pooling one, but has a skip connection", i)}
+	}
+	if skip.From < -1 || skip.From >= i {
+		return InvalidGenomeError{fmt.Sprintf("conv block %d has a skip connection from block %d", i, skip.From)}
+	}
+	source, res := shapes[skip.From+1], shapes[i+1].res
+	if source.res.Width < res.Width || source.res.Height < res.Height {
+		return InvalidGenomeError{fmt.Sprintf("skip connection to conv block %d comes from a lower resolution %s", i, source.res.String())}
+	}
+	switch skip.Mode {
+	case nn.MergeAdd:
+		if source.channels != block.Output {
+			return InvalidGenomeError{fmt.Sprintf("skip connection adds %d channels to %d of conv block %d", source.channels, block.Output, i)}
+		}
+	case nn.MergeConcat:
+	default:
+		return InvalidGenomeError{fmt.Sprintf("conv block %d has a skip connection with unknown mode %q", i, skip.Mode)}
+	}
+	return nil
+}
+
+// randomSkip picks a skip connection that conv block i can have, ok is false if there are none
+func (genome Genome) randomSkip(rng *rand.Rand, i int) (skip Skip, ok bool) {
+	shapes := genome.convShapes()
+	var candidates []Skip
+	for from := -1; from < i; from++ {
+		for _, mode := range []string{nn.MergeAdd, nn.MergeConcat} {
+			if genome.skipError(i, Skip{from, mode}, shapes) == nil {
+				candidates = append(candidates, Skip{from, mode})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return Skip{}, false
+	}
+	return candidates[rng.Intn(len(candidates))], true
+}
+
+// addRandomSkips gives each Conv2D block without a skip connection a random one with advCfg.SkipChance
+func (genome *Genome) addRandomSkips(rng *rand.Rand, advCfg AdvancedConfig) {
+	for i, block := range genome.ConvBlocks {
+		if block.Type != BlockConv2D || block.Skip != nil || rng.Float32() >= advCfg.SkipChance {
+			continue
+		}
+		if skip, ok := genome.randomSkip(rng, i); ok {
+			genome.ConvBlocks[i].Skip = &skip
+		}
+	}
+}
+
+// mutateSkips removes the skip connection of each Conv2D block or, with advCfg.SkipChance, adds one with mutationChance
+func (genome *Genome) mutateSkips(rng *rand.Rand, advCfg AdvancedConfig, mutationChance float32) {
+	for i, block := range genome.ConvBlocks {
+		if block.Type != BlockConv2D || block.Skip == nil && advCfg.SkipChance <= 0 || rng.Float32() >= mutationChance {
+			continue
+		}
+		if block.Skip != nil {
+			fmt.Println("removing skip connection to conv block", i)
+			genome.ConvBlocks[i].Skip = nil
+			continue
+		}
+		if rng.Float32() >= advCfg.SkipChance {
+			continue
+		}
+		if skip, ok := genome.randomSkip(rng, i); ok {
+			fmt.Println("adding skip connection to conv block", i, "from", skip.From)
+			genome.ConvBlocks[i].Skip = &skip
+		}
+	}
+}
+
+// repairSkips removes skip connections that became invalid after blocks around them changed
+func (genome *Genome) repairSkips() {
+	for i, block := range genome.ConvBlocks {
+		if block.Skip == nil {
+			continue
+		}
+		if err := genome.skipError(i, *block.Skip, genome.convShapes()); err != nil {
+			fmt.Println("WARNING: removing skip connection:", err.Error())
+			genome.ConvBlocks[i].Skip = nil
+		}
+	}
+}
+
+// shiftSkips keeps skip connections pointing at the same blocks after delta blocks were inserted at index at,
+// or deleted from it if delta is negative. Connections from deleted blocks come from the block before them instead
+func (genome *Genome) shiftSkips(at, delta int) {
+	for i, block := range genome.ConvBlocks {
+		if block.Skip == nil || block.Skip.From < at {
+			continue
+		}
+		from := block.Skip.From + delta
+		if from < at-1 {
+			from = at - 1
+		}
+		genome.ConvBlocks[i].Skip = &Skip{From: from, Mode: block.Skip.Mode}
+	}
+}
+
+// rebaseSkips returns copies of blocks that were taken from index oldStart of another genome to be put at newStart.
+// Skip connections from blocks that were left behind are removed
+func rebaseSkips(blocks []ConvBlock, oldStart, newStart int) []ConvBlock {
+	rebased := append([]ConvBlock(nil), blocks...)
+	for i, block := range rebased {
+		switch {
+		case block.Skip == nil || block.Skip.From == -1:
+		case block.Skip.From < oldStart:
+			rebased[i].Skip = nil
+		default:
+			rebased[i].Skip = &Skip{From: block.Skip.From - oldStart + newStart, Mode: block.Skip.Mode}
+		}
+	}
+	return rebased
+}
+
+// Decode converts the genome to layer configs, with inputs of Conv2D and FC layers filled in
+func (genome Genome) Decode() (layers []layer.Config, err error) {
+	if err = genome.Validate(); err != nil {
+		return nil, err
+	}
+	shapes := genome.convShapes()
+	skips := nn.NewSkips()
+	isSource := make(map[int]bool)
+	for _, block := range genome.ConvBlocks {
+		if block.Skip != nil {
+			isSource[block.Skip.From] = true
+		}
+	}
+	if isSource[-1] {
+		layers = append(layers, nn.SkipSource{ID: -1, Skips: skips})
+	}
+	for i, block := range genome.ConvBlocks {
+		layers = append(layers, block.layer(shapes[i].channels))
+		if block.BatchNorm {
+			layers = append(layers, nn.BatchNorm{})
+		}
+		if block.Skip != nil {
+			layers = append(layers, nn.SkipMerge{From: block.Skip.From, Mode: block.Skip.Mode, Skips: skips})
+		}
+		if block.Dropout > 0 {
+			layers = append(layers, nn.Dropout{Probability: block.Dropout})
+		}
+		if isSource[i] {
+			layers = append(layers, nn.SkipSource{ID: i, Skips: skips})
+		}
+	}
+	res, channels := shapes[len(shapes)-1].res, shapes[len(shapes)-1].channels
+	layers = append(layers, layer.Flatten{})
+	input := channels * res.Width * res.Height
+	for _, block := range genome.DenseBlocks {
+		activation, _ := activationFnFromString(block.Activation)
+		layers = append(layers, layer.FC{
+			Input:      input,
+			Output:     block.Output,
+			Activation: activation,
+		})
+		if block.Dropout > 0 {
+			layers = append(layers, nn.Dropout{Probability: block.Dropout})
+		}
+		input = block.Output
+	}
+	return
+}
+
+func (genome Genome) clone() Genome {
+	genome.ConvBlocks = append([]ConvBlock(nil), genome.ConvBlocks...)
+	genome.DenseBlocks = append([]DenseBlock(nil), genome.DenseBlocks...)
+	return genome
+}
+
+// convLayersFrom returns layers of conv blocks starting at index from, followed by Flatten.
+// Only their geometry is meaningful, which is enough to calculate the resolution they need
+func (genome Genome) convLayersFrom(from int) (layers []layer.Config) {
+	for _, block := range genome.ConvBlocks[from:] {
+		layers = append(layers, block.layer(0))
+	}
+	return append(layers, layer.Flatten{})
+}
+
+// randomConvBlock generates a Conv2D block or, given either of pooling types, a pooling block of a random type
+func randomConvBlock(rng *rand.Rand, advCfg AdvancedConfig, blockType string, res utils.Resolution, layersAfter []layer.Config) (ConvBlock, error) {
+	if blockType != BlockConv2D {
+		pooling2D, err := GenerateRandomPooling2D(rng, advCfg, res, layersAfter...)
+		if err != nil {
+			return ConvBlock{}, err
+		}
+		switch pooling2D := pooling2D.(type) {
+		case nn.AvgPooling2D:
+			return ConvBlock{
+				Type:   BlockAvgPooling2D,
+				Height: pooling2D.Kernel[0],
+				Width:  pooling2D.Kernel[1],
+				Pad:    pooling2D.Pad[0],
+				Stride: pooling2D.Stride[0],
+			}, nil
+		default:
+			maxPooling2D := pooling2D.(layer.MaxPooling2D)
+			return ConvBlock{
+				Type:   BlockMaxPooling2D,
+				Height: maxPooling2D.Kernel[0],
+				Width:  maxPooling2D.Kernel[1],
+				Pad:    maxPooling2D.Pad[0],
+				Stride: maxPooling2D.Stride[0],
+			}, nil
+		}
+	}
+	conv2D, err := GenerateRandomConv2D(rng, advCfg, 0, res, layersAfter...)
+	if err != nil {
+		return ConvBlock{}, err
+	}
+	return ConvBlock{
+		Type:       BlockConv2D,
+		Output:     conv2D.Output,
+		Height:     conv2D.Height,
+		Width:      conv2D.Width,
+		Pad:        conv2D.Pad[0],
+		Stride:     conv2D.Stride[0],
+		Activation: activationFnToString(conv2D.Activation),
+		BatchNorm:  rng.Float32() < advCfg.BatchNormChance,
+		Dropout:    generateRandomDropout(rng, advCfg),
+	}, nil
+}
+
+// randomDenseBlock generates a hidden dense block
+func randomDenseBlock(rng *rand.Rand, advCfg AdvancedConfig) DenseBlock {
+	fc := generateRandomFC(rng, advCfg, 0)
+	return DenseBlock{Output: fc.Output, Activation: activationFnToString(fc.Activation), Dropout: generateRandomDropout(rng, advCfg)}
+}
+
+// Mutate returns a copy of the genome where each block, except for the output layer, is mutated with mutationChance.
+// A mutated block is deleted with advCfg.DeleteBlockChance, otherwise its parameters are changed by small steps
+// (see ConvBlock.mutate and DenseBlock.mutate) and a new random block of the same type is inserted after it
+// with advCfg.InsertBlockChance. Hyperparameters are mutated too if advCfg.EvolveHyperparameters is set
+func (genome Genome) Mutate(rng *rand.Rand, advCfg AdvancedConfig, mutationChance float32) (mutated Genome, err error) {
+	mutated = genome.clone()
+
+	res := mutated.InputRes
+	for i := 0; i < len(mutated.ConvBlocks); i++ {
+		if rng.Float32() >= mutationChance {
+			res = res.After(mutated.ConvBlocks[i].layer(0))
+			continue
+		}
+		blockType := mutated.ConvBlocks[i].Type
+		if rng.Float32() < advCfg.DeleteBlockChance {
+			fmt.Println("deleting", blockType, "block")
+			mutated.ConvBlocks = append(mutated.ConvBlocks[:i], mutated.ConvBlocks[i+1:]...)
+			mutated.shiftSkips(i, -1)
+			i--
+			continue
+		}
+		block := mutated.ConvBlocks[i].mutate(rng, advCfg, res, mutated.convLayersFrom(i+1))
+		mutated.ConvBlocks[i] = block
+		res = res.After(block.layer(0))
+
+		if rng.Float32() < advCfg.InsertBlockChance {
+			fmt.Println("inserting", blockType, "block")
+			newBlock, err := randomConvBlock(rng, advCfg, blockType, res, mutated.convLayersFrom(i+1))
+			if err != nil {
+				fmt.Println("WARNING: failed to generate new", blockType, "block", err)
+				continue
+			}
+			mutated.ConvBlocks = append(mutated.ConvBlocks[:i+1], append([]ConvBlock{newBlock}, mutated.ConvBlocks[i+1:]...)...)
+			mutated.shiftSkips(i+1, 1)
+			res = res.After(newBlock.layer(0))
+			i++
+		}
+	}
+
+	mutated.repairSkips()
+	mutated.mutateSkips(rng, advCfg, mutationChance)
+	mutated.repairSkips()
+
+	// the output layer is never mutated
+	for i := 0; i < len(mutated.DenseBlocks)-1; i++ {
+		if rng.Float32() >= mutationChance {
+			continue
+		}
+		if rng.Float32() < advCfg.DeleteBlockChance {
+			fmt.Println("deleting dense block", i)
+			mutated.DenseBlocks = append(mutated.DenseBlocks[:i], mutated.DenseBlocks[i+1:]...)
+			i--
+			continue
+		}
+		mutated.DenseBlocks[i] = mutated.DenseBlocks[i].mutate(rng, advCfg)
+		if rng.Float32() < advCfg.InsertBlockChance {
+			fmt.Println("inserting dense block after", i)
+			mutated.DenseBlocks = append(mutated.DenseBlocks[:i+1], append([]DenseBlock{randomDenseBlock(rng, advCfg)}, mutated.DenseBlocks[i+1:]...)...)
+			i++
+		}
+	}
+
+	if advCfg.EvolveHyperparameters {
+		if mutated.Hyperparameters, err = mutated.Hyperparameters.mutate(rng, advCfg, mutationChance); err != nil {
+			return Genome{}, err
+		}
+	}
+
+	if err = mutated.Validate(); err != nil {
+		fmt.Println("WARNING:", err.Error())
+		return Genome{}, &MutationFailedError{}
+	}
+	return mutated, nil
+}
+
+// Crossover picks a random point in the genome and a compatible one in the other genome
+// (conv blocks, the flatten point or dense blocks) and swaps everything after them.
+// If either genome has hyperparameters, each of them is swapped with a chance of 0.5
+func (genome Genome) Crossover(rng *rand.Rand, other Genome) (child1, child2 Genome, err1, err2 error) {
+	left, right := genome.clone(), other.clone()
+	flattenPoint := len(left.ConvBlocks)
+	crossoverPointLeft := rng.Intn(len(left.ConvBlocks) + 1 + len(left.DenseBlocks))
+	switch {
+	case crossoverPointLeft < flattenPoint:
+		crossoverPointRight := rng.Intn(len(right.ConvBlocks) + 1)
+		child1, child2 = left, right
+		child1.ConvBlocks = append(left.ConvBlocks[:crossoverPointLeft:crossoverPointLeft],
+			rebaseSkips(right.ConvBlocks[crossoverPointRight:], crossoverPointRight, crossoverPointLeft)...)
+		child2.ConvBlocks = append(right.ConvBlocks[:crossoverPointRight:crossoverPointRight],
+			rebaseSkips(left.ConvBlocks[crossoverPointLeft:], crossoverPointLeft, crossoverPointRight)...)
+		child1.DenseBlocks, child2.DenseBlocks = right.DenseBlocks, left.DenseBlocks
+		child1.repairSkips()
+		child2.repairSkips()
+	case crossoverPointLeft == flattenPoint:
+		child1, child2 = genome.CrossoverAlt(other)
+	default:
+		crossoverPointLeft -= flattenPoint + 1
+		crossoverPointRight := rng.Intn(len(right.DenseBlocks))
+		child1, child2 = left, right
+		child1.DenseBlocks = append(left.DenseBlocks[:crossoverPointLeft:crossoverPointLeft], right.DenseBlocks[crossoverPointRight:]...)
+		child2.DenseBlocks = append(right.DenseBlocks[:crossoverPointRight:crossoverPointRight], left.DenseBlocks[crossoverPointLeft:]...)
+	}
+	child1.Hyperparameters, child2.Hyperparameters = genome.crossoverHyperparameters(rng, other)
+	return child1, child2, child1.Validate(), child2.Validate()
+}
+
+// CrossoverAlt swaps the dense parts of the genomes, which always gives valid children
+func (genome Genome) CrossoverAlt(other Genome) (child1, child2 Genome) {
+	child1, child2 = genome.clone(), other.clone()
+	child1.DenseBlocks, child2.DenseBlocks = child2.DenseBlocks, child1.DenseBlocks
+	return
+}
@@ -0,0 +1,275 @@
+package evolution
+
+import (
+	"errors"
+	"fmt"
+	"github.com/m8u/goro/pkg/v1/layer"
+	"reflect"
+	"sotsuron/internal/nn"
+	"sotsuron/internal/utils"
+	"testing"
+)
+
+func newTestGenome() Genome {
+	return Genome{
+		InputRes: utils.Resolution{Width: 16, Height: 12},
+		Channels: 1,
+		ConvBlocks: []ConvBlock{
+			{Type: BlockConv2D, Output: 8, Height: 3, Width: 3, Pad: 1, Stride: 1, Activation: "ReLU"},
+			{Type: BlockMaxPooling2D, Height: 2, Width: 2, Pad: 0, Stride: 2},
+			{Type: BlockConv2D, Output: 4, Height: 3, Width: 3, Pad: 0, Stride: 1, Activation: "Tanh"},
+		},
+		DenseBlocks: []DenseBlock{
+			{Output: 32, Activation: "Sigmoid"},
+			{Output: 5, Activation: "Linear"},
+		},
+	}
+}
+
+// newTestRegularizedGenome is newTestGenome with average pooling, batch normalization and Dropout
+func newTestRegularizedGenome() Genome {
+	genome := newTestGenome()
+	genome.ConvBlocks[0].BatchNorm = true
+	genome.ConvBlocks[0].Dropout = 0.25
+	genome.ConvBlocks[1].Type = BlockAvgPooling2D
+	genome.DenseBlocks[0].Dropout = 0.5
+	return genome
+}
+
+// newTestSkipGenome is newTestGenome with the input concatenated to the first conv block
+// and the output of the first block concatenated to the last one
+func newTestSkipGenome() Genome {
+	genome := newTestGenome()
+	genome.ConvBlocks[0].Skip = &Skip{From: -1, Mode: nn.MergeConcat}
+	genome.ConvBlocks[2].Skip = &Skip{From: 0, Mode: nn.MergeConcat}
+	return genome
+}
+
+func TestGenome_Decode(t *testing.T) {
+	layers, err := newTestGenome().Decode()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got := layers[2].(layer.Conv2D).Input; got != 8 {
+		t.Errorf("Decode() second Conv2D input = %v, want 8", got)
+	}
+	if _, ok := layers[3].(layer.Flatten); !ok {
+		t.Errorf("Decode() layer 3 = %T, want layer.Flatten", layers[3])
+	}
+	// 16x12 -> conv 16x12 -> pool 8x6 -> conv 6x4
+	if got := layers[4].(layer.FC).Input; got != 4*6*4 {
+		t.Errorf("Decode() first FC input = %v, want %v", got, 4*6*4)
+	}
+	if got := layers[5].(layer.FC).Input; got != 32 {
+		t.Errorf("Decode() second FC input = %v, want 32", got)
+	}
+
+	encoded, err := EncodeGenome(layers, newTestGenome().InputRes, 1)
+	if err != nil {
+		t.Fatalf("EncodeGenome() error = %v", err)
+	}
+	if !reflect.DeepEqual(encoded, newTestGenome()) {
+		t.Errorf("EncodeGenome() = %+v, want %+v", encoded, newTestGenome())
+	}
+}
+
+func TestGenome_Decode_regularized(t *testing.T) {
+	layers, err := newTestRegularizedGenome().Decode()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	var types []string
+	for _, l := range layers {
+		types = append(types, fmt.Sprintf("%T", l))
+	}
+	want := []string{"layer.Conv2D", "nn.BatchNorm", "nn.Dropout", "nn.AvgPooling2D", "layer.Conv2D", "layer.Flatten",
+		"layer.FC", "nn.Dropout", "layer.FC"}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("Decode() layers = %v, want %v", types, want)
+	}
+	if got := layers[6].(layer.FC).Input; got != 4*6*4 {
+		t.Errorf("Decode() first FC input = %v, want %v", got, 4*6*4)
+	}
+
+	encoded, err := EncodeGenome(layers, newTestGenome().InputRes, 1)
+	if err != nil {
+		t.Fatalf("EncodeGenome() error = %v", err)
+	}
+	if !reflect.DeepEqual(encoded, newTestRegularizedGenome()) {
+		t.Errorf("EncodeGenome() = %+v, want %+v", encoded, newTestRegularizedGenome())
+	}
+	if _, err = EncodeGenome(append([]layer.Config{nn.Dropout{Probability: 0.5}}, layers...), newTestGenome().InputRes, 1); err == nil {
+		t.Errorf("EncodeGenome() of Dropout before any Conv2D must fail")
+	}
+}
+
+func TestGenome_Decode_skips(t *testing.T) {
+	layers, err := newTestSkipGenome().Decode()
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	var types []string
+	for _, l := range layers {
+		types = append(types, fmt.Sprintf("%T", l))
+	}
+	want := []string{"nn.SkipSource", "layer.Conv2D", "nn.SkipMerge", "nn.SkipSource", "layer.MaxPooling2D", "layer.Conv2D",
+		"nn.SkipMerge", "layer.Flatten", "layer.FC", "layer.FC"}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("Decode() layers = %v, want %v", types, want)
+	}
+	// 8 filters and the input channel, then 4 filters and the 9 channels of the first block
+	if got := layers[5].(layer.Conv2D).Input; got != 9 {
+		t.Errorf("Decode() second Conv2D input = %v, want 9", got)
+	}
+	if got := layers[8].(layer.FC).Input; got != 13*6*4 {
+		t.Errorf("Decode() first FC input = %v, want %v", got, 13*6*4)
+	}
+
+	encoded, err := EncodeGenome(layers, newTestGenome().InputRes, 1)
+	if err != nil {
+		t.Fatalf("EncodeGenome() error = %v", err)
+	}
+	if !reflect.DeepEqual(encoded, newTestSkipGenome()) {
+		t.Errorf("EncodeGenome() = %+v, want %+v", encoded, newTestSkipGenome())
+	}
+	if _, err = compileGenome(DefaultAdvancedConfig(), newTestSkipGenome()); err != nil {
+		t.Errorf("compileGenome() error = %v", err)
+	}
+}
+
+func TestGenome_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(genome *Genome)
+	}{
+		{name: "no dense blocks", modify: func(genome *Genome) { genome.DenseBlocks = nil }},
+		{name: "no channels", modify: func(genome *Genome) { genome.Channels = 0 }},
+		{name: "unknown block", modify: func(genome *Genome) { genome.ConvBlocks[1].Type = "Dropout" }},
+		{name: "unknown activation", modify: func(genome *Genome) { genome.DenseBlocks[0].Activation = "Swish" }},
+		{name: "no filters", modify: func(genome *Genome) { genome.ConvBlocks[0].Output = 0 }},
+		{name: "kernel too large", modify: func(genome *Genome) { genome.ConvBlocks[2].Height = 20 }},
+		{name: "stride 0", modify: func(genome *Genome) { genome.ConvBlocks[0].Stride = 0 }},
+		{name: "pooling stride 0", modify: func(genome *Genome) { genome.ConvBlocks[1].Stride = 0 }},
+		{name: "kernel 0", modify: func(genome *Genome) { genome.ConvBlocks[0].Width = 0 }},
+		{name: "negative pad", modify: func(genome *Genome) { genome.ConvBlocks[2].Pad = -1 }},
+		{name: "stride 0 with a skip", modify: func(genome *Genome) {
+			genome.ConvBlocks[0].Stride = 0
+			genome.ConvBlocks[2].Skip = &Skip{From: 0, Mode: nn.MergeConcat}
+		}},
+		{name: "dropout after pooling", modify: func(genome *Genome) { genome.ConvBlocks[1].Dropout = 0.5 }},
+		{name: "batch norm after pooling", modify: func(genome *Genome) { genome.ConvBlocks[1].BatchNorm = true }},
+		{name: "dropout of 1", modify: func(genome *Genome) { genome.DenseBlocks[0].Dropout = 1 }},
+		{name: "dropout after output", modify: func(genome *Genome) { genome.DenseBlocks[1].Dropout = 0.5 }},
+		{name: "skip to pooling", modify: func(genome *Genome) { genome.ConvBlocks[1].Skip = &Skip{From: 0, Mode: nn.MergeConcat} }},
+		{name: "skip from later block", modify: func(genome *Genome) { genome.ConvBlocks[0].Skip = &Skip{From: 2, Mode: nn.MergeConcat} }},
+		{name: "skip adds other channels", modify: func(genome *Genome) { genome.ConvBlocks[2].Skip = &Skip{From: 0, Mode: nn.MergeAdd} }},
+		{name: "skip from lower resolution", modify: func(genome *Genome) {
+			genome.ConvBlocks = append(genome.ConvBlocks, ConvBlock{Type: BlockConv2D, Output: 4, Height: 1, Width: 1, Stride: 1,
+				Activation: "ReLU", Pad: 1, Skip: &Skip{From: 2, Mode: nn.MergeAdd}})
+		}},
+		{name: "unknown merge", modify: func(genome *Genome) { genome.ConvBlocks[2].Skip = &Skip{From: 1, Mode: "multiply"} }},
+	}
+	if err := newTestGenome().Validate(); err != nil {
+		t.Fatalf("Validate() of a valid genome error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			genome := newTestGenome()
+			tt.modify(&genome)
+			if err := genome.Validate(); !errors.As(err, &InvalidGenomeError{}) {
+				t.Errorf("Validate() error = %v, want InvalidGenomeError", err)
+			}
+		})
+	}
+}
+
+func TestGenome_Mutate(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	for i := 0; i < 50; i++ {
+		genome := newTestGenome()
+		mutated, err := genome.Mutate(newTestRNG(), advCfg, 0.5)
+		if err != nil {
+			continue
+		}
+		if err = mutated.Validate(); err != nil {
+			t.Errorf("Mutate() returned invalid genome: %v", err)
+		}
+		if mutated.NumClasses() != genome.NumClasses() {
+			t.Errorf("Mutate() changed number of classes to %v", mutated.NumClasses())
+		}
+		if !reflect.DeepEqual(genome, newTestGenome()) {
+			t.Fatalf("Mutate() modified the original genome")
+		}
+	}
+}
+
+func TestGenome_Mutate_skips(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.SkipChance = 1
+	numSkips := 0
+	for i := 0; i < 50; i++ {
+		mutated, err := newTestSkipGenome().Mutate(newTestRNG(), advCfg, 0.5)
+		if err != nil {
+			continue
+		}
+		if err = mutated.Validate(); err != nil {
+			t.Errorf("Mutate() returned invalid genome: %v", err)
+		}
+		for _, block := range mutated.ConvBlocks {
+			if block.Skip != nil {
+				numSkips++
+			}
+		}
+	}
+	if numSkips == 0 {
+		t.Errorf("Mutate() never kept or added a skip connection")
+	}
+}
+
+func TestGenome_shiftSkips(t *testing.T) {
+	genome := newTestSkipGenome()
+	genome.ConvBlocks = genome.ConvBlocks[1:]
+	genome.shiftSkips(0, -1)
+	if want := (Skip{From: -1, Mode: nn.MergeConcat}); *genome.ConvBlocks[1].Skip != want {
+		t.Errorf("shiftSkips() after deleting the source = %+v, want %+v", *genome.ConvBlocks[1].Skip, want)
+	}
+	if err := genome.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if *newTestSkipGenome().ConvBlocks[2].Skip != (Skip{From: 0, Mode: nn.MergeConcat}) {
+		t.Errorf("shiftSkips() modified a skip connection in place")
+	}
+}
+
+func TestGenome_Crossover(t *testing.T) {
+	other := Genome{
+		InputRes: newTestGenome().InputRes,
+		Channels: 1,
+		ConvBlocks: []ConvBlock{
+			{Type: BlockConv2D, Output: 2, Height: 5, Width: 5, Pad: 2, Stride: 1, Activation: "LeakyReLU"},
+		},
+		DenseBlocks: []DenseBlock{
+			{Output: 64, Activation: "ReLU"},
+			{Output: 16, Activation: "ReLU"},
+			{Output: 5, Activation: "Sigmoid"},
+		},
+	}
+	numBlocks := func(genomes ...Genome) (n int) {
+		for _, genome := range genomes {
+			n += len(genome.ConvBlocks) + len(genome.DenseBlocks)
+		}
+		return
+	}
+	for i := 0; i < 50; i++ {
+		child1, child2, err1, err2 := newTestGenome().Crossover(newTestRNG(), other)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if child1.NumClasses() != 5 || child2.NumClasses() != 5 {
+			t.Errorf("Crossover() children have %v and %v classes, want 5", child1.NumClasses(), child2.NumClasses())
+		}
+		if got, want := numBlocks(child1, child2), numBlocks(newTestGenome(), other); got != want {
+			t.Errorf("Crossover() children have %v blocks in total, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package evolution
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// halvingRungs is the number of rungs of successive halving configured by advCfg, 1 if it's off
+func halvingRungs(advCfg AdvancedConfig) int {
+	if advCfg.HalvingRungs < 1 || advCfg.HalvingKeepRatio <= 0 || advCfg.HalvingKeepRatio >= 1 {
+		return 1
+	}
+	return advCfg.HalvingRungs
+}
+
+// rungEpochs is how many epochs the individual is trained for in total by the end of the rung: its whole budget
+// in the last rung and advCfg.HalvingKeepRatio of the next rung's epochs before that, but at least 1
+func (individual *Individual) rungEpochs(advCfg AdvancedConfig, rung, rungs int) int {
+	epochs := float64(individual.epochBudget(advCfg))
+	if rungs > 1 {
+		epochs *= math.Pow(float64(advCfg.HalvingKeepRatio), float64(rungs-1-rung))
+	}
+	return clampInt(int(math.Ceil(epochs)), 1, individual.epochBudget(advCfg))
+}
+
+// halve ranks individuals that are still training by their fitness so far and keeps advCfg.HalvingKeepRatio
+// of them, but at least one, for the next rung. The rest are dropped and keep the fitness they have
+func halve(advCfg AdvancedConfig, individuals []*Individual) (kept, dropped []*Individual) {
+	var training []*Individual
+	for _, individual := range individuals {
+		if individual.trained && !individual.finished(advCfg) {
+			training = append(training, individual)
+		}
+	}
+	sort.SliceStable(training, func(i, j int) bool {
+		return training[i].fitness > training[j].fitness
+	})
+	numKept := int(math.Ceil(float64(len(training)) * float64(advCfg.HalvingKeepRatio)))
+	if numKept < 1 {
+		numKept = 1
+	}
+	if numKept >= len(training) {
+		return training, nil
+	}
+	for _, individual := range training[numKept:] {
+		fmt.Printf("%s is dropped after %d epochs with fitness %v\n", individual.name, len(individual.history.Accuracies), individual.fitness)
+	}
+	return training[:numKept], training[numKept:]
+}
@@ -0,0 +1,95 @@
+package evolution
+
+import (
+	"context"
+	"gorgonia.org/tensor"
+	"reflect"
+	"testing"
+)
+
+func TestIndividual_rungEpochs(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Epochs = 8
+	advCfg.HalvingRungs = 3
+	individual := &Individual{}
+	var got []int
+	for rung := 0; rung < halvingRungs(advCfg); rung++ {
+		got = append(got, individual.rungEpochs(advCfg, rung, halvingRungs(advCfg)))
+	}
+	if want := []int{2, 4, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rungEpochs() = %v, want %v", got, want)
+	}
+
+	advCfg.HalvingKeepRatio = 1
+	if rungs := halvingRungs(advCfg); rungs != 1 {
+		t.Errorf("halvingRungs() with keeping everyone = %d, want 1", rungs)
+	}
+	if got := individual.rungEpochs(advCfg, 0, 1); got != 8 {
+		t.Errorf("rungEpochs() without halving = %d, want 8", got)
+	}
+}
+
+func TestHalve(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Epochs = 4
+	training := func(name string, fitness float32) *Individual {
+		return &Individual{name: name, fitness: fitness, trained: true, history: TrainingHistory{Accuracies: []float32{fitness}}}
+	}
+	stopped := training("stopped", 0.9)
+	stopped.stoppedEarly = true
+	individuals := []*Individual{training("a", 0.2), training("b", 0.8), stopped, {name: "dead"}, training("c", 0.5)}
+	kept, dropped := halve(advCfg, individuals)
+	names := func(individuals []*Individual) (names []string) {
+		for _, individual := range individuals {
+			names = append(names, individual.name)
+		}
+		return
+	}
+	if got, want := names(kept), []string{"b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("halve() kept %v, want %v", got, want)
+	}
+	if got, want := names(dropped), []string{"a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("halve() dropped %v, want %v", got, want)
+	}
+}
+
+// TestSpecies_Evolve_cached ranks a generation of individuals that are all reused from the fitness cache,
+// so that it doesn't need a dataset
+func TestSpecies_Evolve_cached(t *testing.T) {
+	for _, rungs := range []int{1, 3} {
+		advCfg := DefaultAdvancedConfig()
+		advCfg.HalvingRungs = rungs
+		species, err := NewSpecies(advCfg, 3, 16, 12, 5, true, newTestGenome(), newTestGenome(), newTestGenome())
+		if err != nil {
+			t.Fatalf("NewSpecies() error = %v", err)
+		}
+		species.cache = newFitnessCache()
+		evaluated, err := compileGenome(advCfg, newTestGenome())
+		if err != nil {
+			t.Fatalf("compileGenome() error = %v", err)
+		}
+		fillLearnables(t, evaluated, 0.1)
+		evaluated.trained, evaluated.fitness = true, 0.6
+		species.cache.store(advCfg, evaluated)
+
+		x := tensor.New(tensor.WithShape(1, 1, 12, 16), tensor.WithBacking(make([]float32, 12*16)))
+		y := tensor.New(tensor.WithShape(1, 5), tensor.WithBacking(make([]float32, 5)))
+		progressChan := make(chan Progress, 100)
+		if err = species.Evolve(context.Background(), advCfg, 1, x, y, x, y, progressChan, nil, nil, nil); err != nil {
+			t.Fatalf("Evolve() with %d rungs error = %v", rungs, err)
+		}
+		close(progressChan)
+		var last Progress
+		for progress := range progressChan {
+			last = progress
+		}
+		if last.Individual != 3 || last.Dropped != 0 {
+			t.Errorf("Evolve() with %d rungs counted %d individuals and dropped %d, want 3 and 0", rungs, last.Individual, last.Dropped)
+		}
+		for _, individual := range species.individuals {
+			if individual.fitness != 0.6 || len(individual.history.Accuracies) != 0 {
+				t.Errorf("Evolve() with %d rungs trained a cached individual further, fitness %v", rungs, individual.fitness)
+			}
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package evolution
+
+import (
+	"fmt"
+	g "github.com/m8u/gorgonia"
+	"golang.org/x/exp/rand"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	OptimizerSGD     = "sgd"
+	OptimizerAdam    = "adam"
+	OptimizerRMSProp = "rmsprop"
+)
+
+// defaultLearningRate is the one all gorgonia solvers use unless told otherwise
+const defaultLearningRate = 0.001
+
+// Hyperparameters are training settings of an individual. Zero values mean that AdvancedConfig decides,
+// or the defaults of the optimizer for LearningRate and Optimizer
+type Hyperparameters struct {
+	LearningRate float64 `json:",omitempty"`
+	Optimizer    string  `json:",omitempty"` // OptimizerSGD, OptimizerAdam or OptimizerRMSProp
+	BatchSize    int     `json:",omitempty"`
+	L2           float64 `json:",omitempty"` // weight decay
+	Epochs       int     `json:",omitempty"`
+}
+
+type UnknownOptimizerError struct {
+	optimizer string
+}
+
+func (err UnknownOptimizerError) Error() string {
+	return fmt.Sprintf("unknown optimizer %q", err.optimizer)
+}
+
+// parseOptimizers parses a comma separated list of optimizers, an empty list means all of them
+func parseOptimizers(s string) (optimizers []string, err error) {
+	if strings.TrimSpace(s) == "" {
+		return []string{OptimizerSGD, OptimizerAdam, OptimizerRMSProp}, nil
+	}
+	for _, optimizer := range strings.Split(s, ",") {
+		optimizer = strings.ToLower(strings.TrimSpace(optimizer))
+		switch optimizer {
+		case OptimizerSGD, OptimizerAdam, OptimizerRMSProp:
+			optimizers = append(optimizers, optimizer)
+		default:
+			return nil, UnknownOptimizerError{optimizer}
+		}
+	}
+	return
+}
+
+// Resolve fills in the hyperparameters that aren't set with values that are used for training
+func (h Hyperparameters) Resolve(advCfg AdvancedConfig) Hyperparameters {
+	if h.LearningRate == 0 {
+		h.LearningRate = defaultLearningRate
+	}
+	if h.Optimizer == "" {
+		h.Optimizer = OptimizerAdam
+	}
+	if h.BatchSize == 0 {
+		h.BatchSize = advCfg.BatchSize
+	}
+	if h.Epochs == 0 {
+		h.Epochs = advCfg.Epochs
+	}
+	return h
+}
+
+func (h Hyperparameters) validate() error {
+	if h.LearningRate < 0 || h.BatchSize < 0 || h.L2 < 0 || h.Epochs < 0 {
+		return InvalidGenomeError{fmt.Sprintf("negative hyperparameters %+v", h)}
+	}
+	switch h.Optimizer {
+	case "", OptimizerSGD, OptimizerAdam, OptimizerRMSProp:
+		return nil
+	default:
+		return InvalidGenomeError{UnknownOptimizerError{h.Optimizer}.Error()}
+	}
+}
+
+// solver creates the optimizer to train a model with
+func (h Hyperparameters) solver() g.Solver {
+	var opts []g.SolverOpt
+	if h.LearningRate > 0 {
+		opts = append(opts, g.WithLearnRate(h.LearningRate))
+	}
+	if h.L2 > 0 {
+		opts = append(opts, g.WithL2Reg(h.L2))
+	}
+	switch h.Optimizer {
+	case OptimizerSGD:
+		return g.NewVanillaSolver(opts...)
+	case OptimizerRMSProp:
+		return g.NewRMSPropSolver(opts...)
+	default:
+		return g.NewAdamSolver(opts...)
+	}
+}
+
+// roundSignificant keeps 3 significant digits, so that rates are readable
+func roundSignificant(x float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(x, 'g', 3, 64), 64)
+	return rounded
+}
+
+// logUniform picks a number between min and max so that every order of magnitude is equally likely
+func logUniform(rng *rand.Rand, min, max float64) float64 {
+	return roundSignificant(math.Exp(math.Log(min) + rng.Float64()*(math.Log(max)-math.Log(min))))
+}
+
+func clampFloat(x, min, max float64) float64 {
+	return math.Max(min, math.Min(max, x))
+}
+
+func clampInt(x, min, max int) int {
+	if x < min {
+		return min
+	}
+	if x > max {
+		return max
+	}
+	return x
+}
+
+// InvalidHyperparameterRangeError means that a range of hyperparameters of AdvancedConfig is empty or out of bounds
+type InvalidHyperparameterRangeError struct {
+	name     string
+	min, max float64
+}
+
+func (err InvalidHyperparameterRangeError) Error() string {
+	return fmt.Sprintf("invalid range of %s from %v to %v", err.name, err.min, err.max)
+}
+
+// validateHyperparameterRanges checks the ranges hyperparameters are picked within, if advCfg.EvolveHyperparameters is on
+func validateHyperparameterRanges(advCfg AdvancedConfig) error {
+	if !advCfg.EvolveHyperparameters {
+		return nil
+	}
+	switch {
+	case advCfg.MinLearningRate <= 0 || advCfg.MinLearningRate > advCfg.MaxLearningRate:
+		return InvalidHyperparameterRangeError{"learning rate", advCfg.MinLearningRate, advCfg.MaxLearningRate}
+	case advCfg.MinBatchSize < 1 || advCfg.MinBatchSize > advCfg.MaxBatchSize:
+		return InvalidHyperparameterRangeError{"batch size", float64(advCfg.MinBatchSize), float64(advCfg.MaxBatchSize)}
+	case advCfg.MinEpochs < 1 || advCfg.MinEpochs > advCfg.MaxEpochs:
+		return InvalidHyperparameterRangeError{"epochs", float64(advCfg.MinEpochs), float64(advCfg.MaxEpochs)}
+	case advCfg.MaxL2 < 0:
+		return InvalidHyperparameterRangeError{"L2", 0, advCfg.MaxL2}
+	}
+	_, err := parseOptimizers(advCfg.Optimizers)
+	return err
+}
+
+// generateRandomHyperparameters picks every hyperparameter within the ranges of advCfg
+func generateRandomHyperparameters(rng *rand.Rand, advCfg AdvancedConfig) (h Hyperparameters, err error) {
+	if err = validateHyperparameterRanges(advCfg); err != nil {
+		return Hyperparameters{}, err
+	}
+	optimizers, _ := parseOptimizers(advCfg.Optimizers)
+	h.LearningRate = logUniform(rng, advCfg.MinLearningRate, advCfg.MaxLearningRate)
+	h.Optimizer = optimizers[rng.Intn(len(optimizers))]
+	h.BatchSize = advCfg.MinBatchSize + rng.Intn(advCfg.MaxBatchSize-advCfg.MinBatchSize+1)
+	if advCfg.MaxL2 > 0 && rng.Float32() < 0.5 {
+		h.L2 = logUniform(rng, advCfg.MaxL2/100, advCfg.MaxL2)
+	}
+	h.Epochs = advCfg.MinEpochs + rng.Intn(advCfg.MaxEpochs-advCfg.MinEpochs+1)
+	return
+}
+
+// mutate changes each hyperparameter with mutationChance by a small step within the ranges of advCfg
+func (h Hyperparameters) mutate(rng *rand.Rand, advCfg AdvancedConfig, mutationChance float32) (Hyperparameters, error) {
+	optimizers, err := parseOptimizers(advCfg.Optimizers)
+	if err != nil {
+		return Hyperparameters{}, err
+	}
+	h = h.Resolve(advCfg)
+	if rng.Float32() < mutationChance {
+		h.LearningRate = roundSignificant(clampFloat(h.LearningRate*math.Exp(rng.NormFloat64()/2), advCfg.MinLearningRate, advCfg.MaxLearningRate))
+		fmt.Println("mutating learning rate to", h.LearningRate)
+	}
+	if rng.Float32() < mutationChance {
+		h.Optimizer = optimizers[rng.Intn(len(optimizers))]
+		fmt.Println("mutating optimizer to", h.Optimizer)
+	}
+	if rng.Float32() < mutationChance {
+		if rng.Float32() < 0.5 {
+			h.BatchSize = clampInt(h.BatchSize*2, advCfg.MinBatchSize, advCfg.MaxBatchSize)
+		} else {
+			h.BatchSize = clampInt(h.BatchSize/2, advCfg.MinBatchSize, advCfg.MaxBatchSize)
+		}
+		fmt.Println("mutating batch size to", h.BatchSize)
+	}
+	if rng.Float32() < mutationChance && advCfg.MaxL2 > 0 {
+		switch {
+		case h.L2 == 0:
+			h.L2 = logUniform(rng, advCfg.MaxL2/100, advCfg.MaxL2)
+		case rng.Float32() < 0.5:
+			h.L2 = 0
+		default:
+			h.L2 = roundSignificant(clampFloat(h.L2*math.Exp(rng.NormFloat64()), advCfg.MaxL2/100, advCfg.MaxL2))
+		}
+		fmt.Println("mutating L2 to", h.L2)
+	}
+	if rng.Float32() < mutationChance {
+		if rng.Float32() < 0.5 {
+			h.Epochs = clampInt(h.Epochs+1, advCfg.MinEpochs, advCfg.MaxEpochs)
+		} else {
+			h.Epochs = clampInt(h.Epochs-1, advCfg.MinEpochs, advCfg.MaxEpochs)
+		}
+		fmt.Println("mutating epochs to", h.Epochs)
+	}
+	return h, nil
+}
+
+// crossoverHyperparameters swaps each hyperparameter between the children with a chance of 0.5
+func crossoverHyperparameters(rng *rand.Rand, a, b Hyperparameters) (Hyperparameters, Hyperparameters) {
+	if rng.Float32() < 0.5 {
+		a.LearningRate, b.LearningRate = b.LearningRate, a.LearningRate
+	}
+	if rng.Float32() < 0.5 {
+		a.Optimizer, b.Optimizer = b.Optimizer, a.Optimizer
+	}
+	if rng.Float32() < 0.5 {
+		a.BatchSize, b.BatchSize = b.BatchSize, a.BatchSize
+	}
+	if rng.Float32() < 0.5 {
+		a.L2, b.L2 = b.L2, a.L2
+	}
+	if rng.Float32() < 0.5 {
+		a.Epochs, b.Epochs = b.Epochs, a.Epochs
+	}
+	return a, b
+}
+
+// String formats the hyperparameters that are set as a DSL block, e.g. train(adam,lr=0.001,batch=32,l2=0.0001,epochs=5).
+// Rates are never written in the exponent form because "-" separates blocks
+func (h Hyperparameters) String() string {
+	var args []string
+	if h.Optimizer != "" {
+		args = append(args, h.Optimizer)
+	}
+	if h.LearningRate != 0 {
+		args = append(args, "lr="+strconv.FormatFloat(h.LearningRate, 'f', -1, 64))
+	}
+	if h.BatchSize != 0 {
+		args = append(args, fmt.Sprintf("batch=%d", h.BatchSize))
+	}
+	if h.L2 != 0 {
+		args = append(args, "l2="+strconv.FormatFloat(h.L2, 'f', -1, 64))
+	}
+	if h.Epochs != 0 {
+		args = append(args, fmt.Sprintf("epochs=%d", h.Epochs))
+	}
+	return "train(" + strings.Join(args, ",") + ")"
+}
+
+// parseTrainArgs parses arguments of train([optimizer][,lr=N][,batch=N][,l2=N][,epochs=N])
+func parseTrainArgs(block string, args []string) (h Hyperparameters, err error) {
+	for _, arg := range args {
+		key, value, found := strings.Cut(strings.ToLower(arg), "=")
+		if !found {
+			h.Optimizer = key
+			continue
+		}
+		switch key {
+		case "lr":
+			h.LearningRate, err = strconv.ParseFloat(value, 64)
+		case "batch":
+			h.BatchSize, err = strconv.Atoi(value)
+		case "l2":
+			h.L2, err = strconv.ParseFloat(value, 64)
+		case "epochs":
+			h.Epochs, err = strconv.Atoi(value)
+		default:
+			return Hyperparameters{}, ArchitectureSyntaxError{block, fmt.Sprintf("unknown hyperparameter %q", key)}
+		}
+		if err != nil {
+			return Hyperparameters{}, ArchitectureSyntaxError{block, fmt.Sprintf("%q must be a number", key)}
+		}
+	}
+	return
+}
@@ -0,0 +1,115 @@
+package evolution
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHyperparameters_Resolve(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	want := Hyperparameters{LearningRate: defaultLearningRate, Optimizer: OptimizerAdam, BatchSize: advCfg.BatchSize, Epochs: advCfg.Epochs}
+	if got := (Hyperparameters{}).Resolve(advCfg); got != want {
+		t.Errorf("Resolve() = %+v, want %+v", got, want)
+	}
+	set := Hyperparameters{LearningRate: 0.01, Optimizer: OptimizerSGD, BatchSize: 32, L2: 0.001, Epochs: 2}
+	if got := set.Resolve(advCfg); got != set {
+		t.Errorf("Resolve() = %+v, want %+v", got, set)
+	}
+}
+
+func TestParseOptimizers(t *testing.T) {
+	if got, err := parseOptimizers(" SGD, rmsprop"); err != nil || !reflect.DeepEqual(got, []string{OptimizerSGD, OptimizerRMSProp}) {
+		t.Errorf("parseOptimizers() = %v, %v", got, err)
+	}
+	if got, _ := parseOptimizers(""); len(got) != 3 {
+		t.Errorf("parseOptimizers() of an empty list = %v, want all optimizers", got)
+	}
+	if _, err := parseOptimizers("adam,lbfgs"); !errors.As(err, &UnknownOptimizerError{}) {
+		t.Errorf("parseOptimizers() error = %v, want UnknownOptimizerError", err)
+	}
+}
+
+func TestValidateHyperparameterRanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(advCfg *AdvancedConfig)
+	}{
+		{"zero learning rate", func(advCfg *AdvancedConfig) { advCfg.MinLearningRate = 0 }},
+		{"learning rates swapped", func(advCfg *AdvancedConfig) { advCfg.MinLearningRate, advCfg.MaxLearningRate = 0.1, 0.01 }},
+		{"zero batch size", func(advCfg *AdvancedConfig) { advCfg.MinBatchSize = 0 }},
+		{"batch sizes swapped", func(advCfg *AdvancedConfig) { advCfg.MinBatchSize, advCfg.MaxBatchSize = 64, 8 }},
+		{"zero epochs", func(advCfg *AdvancedConfig) { advCfg.MinEpochs = 0 }},
+		{"epochs swapped", func(advCfg *AdvancedConfig) { advCfg.MinEpochs, advCfg.MaxEpochs = 5, 2 }},
+		{"negative L2", func(advCfg *AdvancedConfig) { advCfg.MaxL2 = -0.1 }},
+	}
+	for _, tt := range tests {
+		advCfg := DefaultAdvancedConfig()
+		advCfg.EvolveHyperparameters = true
+		tt.modify(&advCfg)
+		if err := validateHyperparameterRanges(advCfg); !errors.As(err, &InvalidHyperparameterRangeError{}) {
+			t.Errorf("validateHyperparameterRanges() with %s error = %v, want InvalidHyperparameterRangeError", tt.name, err)
+		}
+		if _, err := generateRandomHyperparameters(newTestRNG(), advCfg); err == nil {
+			t.Errorf("generateRandomHyperparameters() with %s didn't fail", tt.name)
+		}
+		if _, err := NewSpecies(advCfg, 2, 16, 12, 5, true); !errors.As(err, &InvalidHyperparameterRangeError{}) {
+			t.Errorf("NewSpecies() with %s error = %v, want InvalidHyperparameterRangeError", tt.name, err)
+		}
+		// the ranges don't matter if hyperparameters aren't evolved
+		advCfg.EvolveHyperparameters = false
+		if err := validateHyperparameterRanges(advCfg); err != nil {
+			t.Errorf("validateHyperparameterRanges() with %s and no evolved hyperparameters error = %v", tt.name, err)
+		}
+	}
+
+	advCfg := DefaultAdvancedConfig()
+	advCfg.EvolveHyperparameters = true
+	if err := validateHyperparameterRanges(advCfg); err != nil {
+		t.Errorf("validateHyperparameterRanges() of the default config error = %v", err)
+	}
+	advCfg.Optimizers = "lbfgs"
+	if err := validateHyperparameterRanges(advCfg); !errors.As(err, &UnknownOptimizerError{}) {
+		t.Errorf("validateHyperparameterRanges() error = %v, want UnknownOptimizerError", err)
+	}
+}
+
+func TestHyperparameters_mutate(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Optimizers = "sgd,rmsprop"
+	rng := newTestRNG()
+	for i := 0; i < 100; i++ {
+		h, err := generateRandomHyperparameters(rng, advCfg)
+		if err != nil {
+			t.Fatalf("generateRandomHyperparameters() error = %v", err)
+		}
+		if h, err = h.mutate(rng, advCfg, 0.5); err != nil {
+			t.Fatalf("mutate() error = %v", err)
+		}
+		if h.LearningRate < advCfg.MinLearningRate || h.LearningRate > advCfg.MaxLearningRate ||
+			h.BatchSize < advCfg.MinBatchSize || h.BatchSize > advCfg.MaxBatchSize ||
+			h.L2 < 0 || h.L2 > advCfg.MaxL2 ||
+			h.Epochs < advCfg.MinEpochs || h.Epochs > advCfg.MaxEpochs ||
+			h.Optimizer == OptimizerAdam {
+			t.Fatalf("mutate() = %+v, out of the ranges of AdvancedConfig", h)
+		}
+	}
+}
+
+func TestGenome_Crossover_hyperparameters(t *testing.T) {
+	left, right := newTestGenome(), newTestGenome()
+	left.Hyperparameters = Hyperparameters{LearningRate: 0.01, Optimizer: OptimizerSGD, BatchSize: 8, L2: 0.001, Epochs: 1}
+	right.Hyperparameters = Hyperparameters{LearningRate: 0.002, Optimizer: OptimizerRMSProp, BatchSize: 64, Epochs: 9}
+	for i := 0; i < 20; i++ {
+		child1, child2, err1, err2 := left.Crossover(newTestRNG(), right)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		// every gene comes from exactly one parent
+		if child1.Hyperparameters.BatchSize+child2.Hyperparameters.BatchSize != 72 ||
+			child1.Hyperparameters.Epochs+child2.Hyperparameters.Epochs != 10 ||
+			child1.Hyperparameters.L2+child2.Hyperparameters.L2 != 0.001 {
+			t.Errorf("Crossover() hyperparameters = %+v and %+v", child1.Hyperparameters, child2.Hyperparameters)
+		}
+	}
+}
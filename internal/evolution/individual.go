@@ -20,42 +20,124 @@ import (
 type Individual struct {
 	name string
 	*m.Sequential
-	inputRes    utils.Resolution
-	isGrayscale bool
-	numClasses  int
-	fitness     float32
-	trained     bool
-	lives       int
+	inputRes     utils.Resolution
+	isGrayscale  bool
+	numClasses   int
+	genome       Genome
+	fitness      float32
+	objectives   Objectives
+	trained      bool
+	inherited    bool            // some weights were copied from parents
+	history      TrainingHistory // of the epochs trained so far
+	stoppedEarly bool            // test accuracy has plateaued
+	cached       bool            // fitness and weights are reused from the fitness cache, so it isn't trained
+	lives        int
+	borrowers    int // children being bred from it outside the lock of the population
 }
 
-func NewIndividual(advCfg AdvancedConfig, inputWidth, inputHeight, numClasses int, grayscale bool) (individual *Individual) {
-	name := uuid.New().String()
-	model, _ := m.NewSequential(name) // TODO: specify metrics
-	model.AddLayers(GenerateRandomStructure(advCfg, inputWidth, inputHeight, numClasses, grayscale)...)
+func NewIndividual(rng *rand.Rand, advCfg AdvancedConfig, inputWidth, inputHeight, numClasses int, grayscale bool) (*Individual, error) {
+	layers := GenerateRandomStructure(rng, advCfg, inputWidth, inputHeight, numClasses, grayscale)
+	inputRes := utils.Resolution{Width: inputWidth, Height: inputHeight}
+	var hyperparameters Hyperparameters
+	if advCfg.EvolveHyperparameters {
+		var err error
+		if hyperparameters, err = generateRandomHyperparameters(rng, advCfg); err != nil {
+			return nil, err
+		}
+	}
+	if advCfg.SkipChance > 0 {
+		channels := 3
+		if grayscale {
+			channels = 1
+		}
+		genome, err := EncodeGenome(layers, inputRes, channels)
+		if err != nil {
+			return nil, err
+		}
+		genome.addRandomSkips(rng, advCfg)
+		genome.Hyperparameters = hyperparameters
+		return compileGenome(advCfg, genome)
+	}
+	return compileIndividual(advCfg, uuid.New().String(), layers, hyperparameters, inputRes, numClasses, grayscale)
+}
+
+// TrainingError means that an individual could not be trained or evaluated, so it should die
+type TrainingError struct {
+	name string
+	err  error
+}
+
+func (err TrainingError) Error() string {
+	return fmt.Sprintf("could not train %s: %v", err.name, err.err)
+}
+
+func (err TrainingError) Unwrap() error {
+	return err.err
+}
+
+// compileGenome creates a new individual with a freshly compiled model decoded from genome
+func compileGenome(advCfg AdvancedConfig, genome Genome) (*Individual, error) {
+	layers, err := genome.Decode()
+	if err != nil {
+		return nil, err
+	}
+	return compileIndividual(advCfg, uuid.New().String(), layers, genome.Hyperparameters, genome.InputRes, genome.NumClasses(), genome.Channels == 1)
+}
+
+// compileIndividual creates an individual with a freshly compiled model consisting of given layers,
+// which is trained with hyperparameters resolved against advCfg
+func compileIndividual(advCfg AdvancedConfig, name string, layers []layer.Config, hyperparameters Hyperparameters,
+	inputRes utils.Resolution, numClasses int, grayscale bool) (individual *Individual, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			individual, err = nil, fmt.Errorf("could not compile %s: %v", name, r)
+		}
+	}()
+
 	var channels int
 	if grayscale {
 		channels = 1
 	} else {
 		channels = 3
 	}
-	err := model.Compile(
-		m.NewInput("x", []int{1, channels, inputHeight, inputWidth}),
+	genome, err := EncodeGenome(layers, inputRes, channels)
+	if err != nil {
+		return nil, err
+	}
+	genome.Hyperparameters = hyperparameters
+	if err = hyperparameters.validate(); err != nil {
+		return nil, err
+	}
+	resolved := hyperparameters.Resolve(advCfg)
+
+	model, _ := m.NewSequential(name) // TODO: specify metrics
+	model.AddLayers(layers...)
+	err = model.Compile(
+		m.NewInput("x", []int{1, channels, inputRes.Height, inputRes.Width}),
 		m.NewInput("y", []int{1, numClasses}),
-		m.WithBatchSize(advCfg.BatchSize),
+		m.WithBatchSize(resolved.BatchSize),
+		m.WithOptimizer(resolved.solver()),
 	)
-	utils.MaybeCrash(err)
-	individual = &Individual{
+	if err != nil {
+		return nil, err
+	}
+	return &Individual{
 		name:        name,
 		Sequential:  model,
-		inputRes:    utils.Resolution{Width: inputWidth, Height: inputHeight},
+		inputRes:    inputRes,
 		isGrayscale: grayscale,
 		numClasses:  numClasses,
+		genome:      genome,
 		lives:       1,
-	}
-	return
+	}, nil
 }
 
-func (individual *Individual) evaluateBatch(ctx context.Context, x, y tensor.Tensor, batchSize int) (accuracy, loss float32, err error) {
+// Genome describes the architecture of the individual
+func (individual *Individual) Genome() Genome {
+	return individual.genome.clone()
+}
+
+func (individual *Individual) evaluateBatch(ctx context.Context, x, y tensor.Tensor, batchSize int) (accuracy, macroF1, loss float32, err error) {
 	exampleSize := x.Shape()[0]
 	batches := exampleSize / batchSize
 
@@ -67,10 +149,11 @@ func (individual *Individual) evaluateBatch(ctx context.Context, x, y tensor.Ten
 	}
 
 	var accuracies []float32
+	confusion := newConfusionMatrix(individual.numClasses)
 	for batch := 0; batch < batches; batch++ {
 		select {
 		case <-ctx.Done():
-			return 0, 0, context.Canceled
+			return 0, 0, 0, context.Canceled
 		default:
 		}
 
@@ -84,38 +167,77 @@ func (individual *Individual) evaluateBatch(ctx context.Context, x, y tensor.Ten
 		}
 
 		xi, err := x.Slice(dense.MakeRangedSlice(start, end))
-		utils.MaybeCrash(err)
+		if err != nil {
+			return 0, 0, 0, err
+		}
 		err = xi.Reshape(batchSize, channels, individual.inputRes.Height, individual.inputRes.Width)
-		utils.MaybeCrash(err)
+		if err != nil {
+			return 0, 0, 0, err
+		}
 
 		yi, err := y.Slice(dense.MakeRangedSlice(start, end))
-		utils.MaybeCrash(err)
+		if err != nil {
+			return 0, 0, 0, err
+		}
 		err = yi.Reshape(batchSize, individual.numClasses)
-		utils.MaybeCrash(err)
+		if err != nil {
+			return 0, 0, 0, err
+		}
 
 		yHat, err := individual.PredictBatch(xi)
-		utils.MaybeCrash(err)
+		if err != nil {
+			return 0, 0, 0, err
+		}
 
 		acc, err := calculateAccuracy(yHat.(*tensor.Dense), yi.(*tensor.Dense))
-		utils.MaybeCrash(err)
+		if err != nil {
+			return 0, 0, 0, err
+		}
 		accuracies = append(accuracies, acc)
+		yMax, err := argmax(yi)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		yHatMax, err := argmax(yHat.(*tensor.Dense))
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		confusion.add(yMax, yHatMax)
 	}
 	lossVal, err := individual.Tracker.GetValue(fmt.Sprintf("%s_train_batch_loss", individual.name))
-	utils.MaybeCrash(err)
+	if err != nil {
+		return 0, 0, 0, err
+	}
 	loss = float32(lossVal.Scalar())
 	accuracy = num.Mean(accuracies)
+	macroF1 = confusion.macroF1()
 	return
 }
 
+// argmax returns the index of the largest value in each row of t
+func argmax(t tensor.Tensor) ([]int, error) {
+	indices, err := t.(*tensor.Dense).Argmax(1)
+	if err != nil {
+		return nil, err
+	}
+	return indices.Data().([]int), nil
+}
+
 func calculateAccuracy(yHat, y tensor.Tensor) (accuracy float32, err error) {
 	yMax, err := y.(*tensor.Dense).Argmax(1)
-	utils.MaybeCrash(err)
+	if err != nil {
+		return 0, err
+	}
 
 	yHatMax, err := yHat.(*tensor.Dense).Argmax(1)
-	utils.MaybeCrash(err)
+	if err != nil {
+		return 0, err
+	}
 
 	eq, err := tensor.ElEq(yMax, yHatMax, tensor.AsSameType())
-	utils.MaybeCrash(err)
+	if err != nil {
+		return 0, err
+	}
 	eqd := eq.(*tensor.Dense)
 
 	numTrue, err := eqd.Sum()
@@ -126,12 +248,59 @@ func calculateAccuracy(yHat, y tensor.Tensor) (accuracy float32, err error) {
 	return float32(numTrue.Data().(int)) / float32(eqd.Len()), nil
 }
 
+// epochBudget is how many epochs the individual is trained for in total
+func (individual *Individual) epochBudget(advCfg AdvancedConfig) int {
+	epochs := individual.genome.Hyperparameters.Resolve(advCfg).Epochs
+	if individual.inherited && advCfg.InheritedEpochs > 0 && advCfg.InheritedEpochs < epochs {
+		epochs = advCfg.InheritedEpochs
+	}
+	return epochs
+}
+
+// finished tells if the individual has used up its epoch budget, has stopped early or is reused from the cache
+func (individual *Individual) finished(advCfg AdvancedConfig) bool {
+	return individual.cached || individual.stoppedEarly || len(individual.history.Accuracies) >= individual.epochBudget(advCfg)
+}
+
+// plateaued tells if accuracy hasn't improved by more than minDelta over the best earlier one for patience epochs
+func plateaued(accuracies []float32, patience int, minDelta float32) bool {
+	if patience <= 0 || len(accuracies) <= patience {
+		return false
+	}
+	best, sinceBest := accuracies[0], 0
+	for _, accuracy := range accuracies[1:] {
+		if accuracy > best+minDelta {
+			best, sinceBest = accuracy, 0
+		} else {
+			sinceBest++
+		}
+	}
+	return sinceBest >= patience
+}
+
+// CalculateFitnessBatch trains the individual for its whole epoch budget and returns its fitness
 func (individual *Individual) CalculateFitnessBatch(
 	ctx context.Context, allChartChan chan AllChartData, advCfg AdvancedConfig,
 	xTrain, yTrain, xTest, yTest tensor.Tensor) (fitness float32, err error) {
-	epochs := advCfg.Epochs
+	individual.history, individual.stoppedEarly, individual.cached = TrainingHistory{}, false, false
+	return individual.trainUpTo(ctx, allChartChan, advCfg, individual.epochBudget(advCfg), xTrain, yTrain, xTest, yTest)
+}
+
+// trainUpTo continues training of the individual until it has been trained for the given number of epochs
+// in total or its test accuracy plateaus (see AdvancedConfig.EarlyStoppingPatience), and returns its fitness
+func (individual *Individual) trainUpTo(
+	ctx context.Context, allChartChan chan AllChartData, advCfg AdvancedConfig, epochs int,
+	xTrain, yTrain, xTest, yTest tensor.Tensor) (fitness float32, err error) {
+	// degenerate architectures may make gorgonia panic, that must only kill the individual
+	defer func() {
+		if r := recover(); r != nil {
+			fitness, err = -1, TrainingError{individual.name, fmt.Errorf("%v", r)}
+		}
+	}()
+
+	batchSize := individual.genome.Hyperparameters.Resolve(advCfg).BatchSize
 	exampleSize := xTrain.Shape()[0]
-	batches := exampleSize / advCfg.BatchSize
+	batches := exampleSize / batchSize
 
 	var channels int
 	if individual.isGrayscale {
@@ -140,11 +309,15 @@ func (individual *Individual) CalculateFitnessBatch(
 		channels = 3
 	}
 
+	fitnessFunc, err := NewFitnessFunc(advCfg)
+	if err != nil {
+		return -1, err
+	}
+
 	var evalStartTime time.Time
-	var evalDurations []float64
-	var accuracy, loss float32
+	history := individual.history
 
-	for epoch := 0; epoch < epochs; epoch++ {
+	for epoch := len(history.Accuracies); epoch < epochs && !individual.stoppedEarly; epoch++ {
 		for batch := 0; batch < batches; batch++ {
 			select {
 			case <-ctx.Done():
@@ -152,8 +325,8 @@ func (individual *Individual) CalculateFitnessBatch(
 			default:
 			}
 
-			start := batch * advCfg.BatchSize
-			end := start + advCfg.BatchSize
+			start := batch * batchSize
+			end := start + batchSize
 			if start >= exampleSize {
 				break
 			}
@@ -162,83 +335,68 @@ func (individual *Individual) CalculateFitnessBatch(
 			}
 
 			xi, err := xTrain.Slice(dense.MakeRangedSlice(start, end))
-			utils.MaybeCrash(err) // todo maybe replace with return
-			err = xi.Reshape(advCfg.BatchSize, channels, individual.inputRes.Height, individual.inputRes.Width)
-			utils.MaybeCrash(err)
+			if err != nil {
+				return -1, TrainingError{individual.name, err}
+			}
+			err = xi.Reshape(batchSize, channels, individual.inputRes.Height, individual.inputRes.Width)
+			if err != nil {
+				return -1, TrainingError{individual.name, err}
+			}
 
 			yi, err := yTrain.Slice(dense.MakeRangedSlice(start, end))
-			utils.MaybeCrash(err)
-			err = yi.Reshape(advCfg.BatchSize, individual.numClasses)
-			utils.MaybeCrash(err)
+			if err != nil {
+				return -1, TrainingError{individual.name, err}
+			}
+			err = yi.Reshape(batchSize, individual.numClasses)
+			if err != nil {
+				return -1, TrainingError{individual.name, err}
+			}
 
 			err = individual.FitBatch(xi, yi) // FIXME: runtime error: invalid memory address or nil pointer dereference (goro@v0.1.3/pkg/v1/model/io.go:84)
 			if err != nil {
-				return -1, err
+				return -1, TrainingError{individual.name, err}
 			}
 			err = individual.Tracker.LogStep(epoch, batch) // FIXME: json: unsupported value: NaN
 			if err != nil {
-				return -1, err
+				return -1, TrainingError{individual.name, err}
 			}
 		}
 		evalStartTime = time.Now()
-		accuracy, loss, err = individual.evaluateBatch(ctx, xTest, yTest, advCfg.BatchSize)
+		accuracy, macroF1, loss, err := individual.evaluateBatch(ctx, xTest, yTest, batchSize)
 		if errors.Is(err, context.Canceled) {
 			return -1, err
-		} else {
-			utils.MaybeCrash(err)
+		} else if err != nil {
+			return -1, TrainingError{individual.name, err}
 		}
-		allChartChan <- AllChartData{
-			Name:     individual.name,
-			Accuracy: accuracy,
+		if allChartChan != nil {
+			allChartChan <- AllChartData{
+				Name:     individual.name,
+				Accuracy: accuracy,
+			}
 		}
-		evalDurations = append(evalDurations, time.Since(evalStartTime).Seconds())
+		history.EvalDurations = append(history.EvalDurations, time.Since(evalStartTime).Seconds())
+		history.Accuracies = append(history.Accuracies, accuracy)
+		history.MacroF1s = append(history.MacroF1s, macroF1)
+		history.Losses = append(history.Losses, loss)
+		individual.history = history
 		//log.Infof("completed train epoch %v with accuracy %v and loss %v", epoch, accuracy, loss)
-	}
-	err = individual.Tracker.Clear()
-	meanEvalDuration := float32(stat.Mean(evalDurations, nil))
-	fmt.Println(individual.name, accuracy, loss, meanEvalDuration)
-	return accuracy*1.0 + -1*loss*0.5, err
-}
-
-func getPrevConv2DOutput(layers []layer.Config, startIndex int, grayscale bool) int {
-	lastConv2DIndex := startIndex
-	for i := startIndex - 1; i >= 0; i-- {
-		if _, ok := layers[i].(layer.Conv2D); ok {
-			lastConv2DIndex = i
-			break
-		}
-	}
-	if lastConv2DIndex == startIndex {
-		if grayscale {
-			return 1
-		}
-		return 3
-	}
-	return layers[lastConv2DIndex].(layer.Conv2D).Output
-}
-
-func findFirstFCIndex(layers []layer.Config) int {
-	firstFCIndex := len(layers) - 1
-	for ; ; firstFCIndex-- {
-		if _, ok := layers[firstFCIndex-1].(layer.Flatten); ok {
-			return firstFCIndex
-		}
-	}
-}
-
-func findNextConv2DIndex(layers []layer.Config, startIndex int) int {
-	nextConv2DIndex := startIndex
-	firstFCIndex := findFirstFCIndex(layers)
-	for i := startIndex + 1; i < firstFCIndex; i++ {
-		if _, ok := layers[i].(layer.Conv2D); ok {
-			nextConv2DIndex = i
-			break
+		if plateaued(history.Accuracies, advCfg.EarlyStoppingPatience, advCfg.EarlyStoppingMinDelta) {
+			fmt.Printf("%s has stopped early after %d epochs\n", individual.name, epoch+1)
+			individual.stoppedEarly = true
 		}
 	}
-	if nextConv2DIndex == startIndex {
-		return -1
+	err = individual.Tracker.Clear()
+	meanEvalDuration := float32(stat.Mean(history.EvalDurations, nil))
+	accuracy := lastValue(history.Accuracies)
+	fmt.Println(individual.name, accuracy, lastValue(history.Losses), meanEvalDuration)
+	individual.objectives = Objectives{
+		Accuracy: accuracy,
+		Latency:  float64(meanEvalDuration) / float64(xTest.Shape()[0]),
 	}
-	return nextConv2DIndex
+	individual.objectives.NumParams, individual.objectives.FLOPs = CalculateModelCost(individual.Chain.Layers, individual.inputRes, channels)
+	history.Objectives = individual.objectives
+	individual.history = history
+	return fitnessFunc(history), err
 }
 
 type MutationFailedError struct{}
@@ -247,156 +405,21 @@ func (err MutationFailedError) Error() string {
 	return fmt.Sprintf("mutation failed")
 }
 
-func (individual *Individual) Mutate(advCfg AdvancedConfig, customMutationChance ...float32) (mutated *Individual, err error) {
-	// get a slice of layers of a model
-	layers := make([]layer.Config, len(individual.Chain.Layers))
-	copy(layers, individual.Chain.Layers)
-
-	input := (individual.Sequential.X().Inputs()[0].Shape())[2:]
-	res := utils.Resolution{
-		Width:  input[1],
-		Height: input[0],
-	}
-
+func (individual *Individual) Mutate(rng *rand.Rand, advCfg AdvancedConfig, customMutationChance ...float32) (mutated *Individual, err error) {
 	var mutationChance float32 = 0.2
 	if len(customMutationChance) > 0 {
 		mutationChance = customMutationChance[0]
 	}
-
-	// mutate layers
-	for i := 0; i < len(layers)-1; i++ {
-		if _, ok := layers[i].(layer.Flatten); ok {
-			continue
-		}
-
-		if rand.Float32() < mutationChance {
-			//println("----------------------------- mutating layer", i, "-----------------------------")
-			shouldDelete, shouldInsert := rand.Float32() < 0.5, rand.Float32() < 0.5
-
-			if _, ok := layers[i].(layer.Conv2D); ok {
-				prevOutput := getPrevConv2DOutput(layers, i, individual.isGrayscale) // TODO: maybe return back setting channels as default
-				if shouldDelete {
-					fmt.Println("deleting Conv2D layer")
-					layers = append(layers[:i], layers[i+1:]...)
-					i--
-				} else {
-					conv2D, err := GenerateRandomConv2D(advCfg, prevOutput, res, layers[i+1:]...)
-					if err != nil {
-						return nil, err
-					}
-					layers[i] = conv2D
-					prevOutput = conv2D.Output
-					res = res.After(conv2D)
-
-					if shouldInsert {
-						fmt.Println("inserting Conv2D layer")
-						newConv2D, err := GenerateRandomConv2D(advCfg, prevOutput, res, layers[i+1:]...)
-						if err != nil {
-							fmt.Println("WARNING: failed to generate new Conv2D layer", err)
-							goto updateNextConv2DInput
-						}
-						layers = append(layers[:i+1], append([]layer.Config{newConv2D}, layers[i+1:]...)...)
-						prevOutput = newConv2D.Output
-						res = res.After(newConv2D)
-						i++
-					}
-				}
-				// update input of next Conv2D layer, if any
-			updateNextConv2DInput:
-				nextConv2DIndex := findNextConv2DIndex(layers, i)
-				if nextConv2DIndex > -1 {
-					nextConv2D := layers[nextConv2DIndex].(layer.Conv2D)
-					nextConv2D.Input = prevOutput
-					layers[nextConv2DIndex] = nextConv2D
-				}
-			} else if _, ok := layers[i].(layer.MaxPooling2D); ok {
-				if shouldDelete {
-					fmt.Println("deleting MaxPooling2D layer")
-					layers = append(layers[:i], layers[i+1:]...)
-					i--
-				} else {
-					maxPooling2D, err := GenerateRandomMaxPooling2D(advCfg, res, layers[i+1:]...)
-					if err != nil {
-						return nil, err
-					}
-					layers[i] = maxPooling2D
-					res = res.After(maxPooling2D)
-
-					if shouldInsert {
-						fmt.Println("inserting MaxPooling2D layer")
-						newMaxPooling2D, err := GenerateRandomMaxPooling2D(advCfg, res, layers[i+1:]...)
-						if err != nil {
-							fmt.Println("WARNING: failed to generate new MaxPooling2D layer", err)
-							continue
-						}
-						layers = append(layers[:i+1], append([]layer.Config{newMaxPooling2D}, layers[i+1:]...)...)
-						res = res.After(newMaxPooling2D)
-						i++
-					}
-				}
-			} else if fc, ok := layers[i].(layer.FC); ok {
-				prevOutput := fc.Input // TODO: maybe return back setting channels as default
-				if shouldDelete {
-					fmt.Println("deleting FC layer", i)
-					layers = append(layers[:i], layers[i+1:]...)
-					i--
-				} else {
-					fc = generateRandomFC(advCfg, prevOutput)
-					layers[i] = fc
-					prevOutput = fc.Output
-
-					if shouldInsert {
-						fmt.Println("inserting FC layer after", i)
-						newFC := generateRandomFC(advCfg, prevOutput)
-						layers = append(layers[:i+1], append([]layer.Config{newFC}, layers[i+1:]...)...)
-						prevOutput = newFC.Output
-						i++
-					}
-				}
-				// update input of next dense layer
-				nextFC := layers[i+1].(layer.FC)
-				nextFC.Input = prevOutput
-				layers[i+1] = nextFC
-			}
-		} else {
-			res = res.After(layers[i])
-		}
-	}
-	if res.Width == 0 || res.Height == 0 {
-		return nil, &MutationFailedError{}
-	}
-
-	// update input of first FC layer
-	firstFCIndex := findFirstFCIndex(layers)
-	firstFC := layers[firstFCIndex].(layer.FC)
-	prevOutput := getPrevConv2DOutput(layers, firstFCIndex, individual.isGrayscale)
-	firstFC.Input = prevOutput * res.Width * res.Height
-	layers[firstFCIndex] = firstFC
-
-	// print layers
-	for _, l := range layers {
-		fmt.Printf("%+v\n", l)
+	genome, err := individual.genome.Mutate(rng, advCfg, mutationChance)
+	if err != nil {
+		return nil, err
 	}
-
-	// create new model with mutated layers
-	name := uuid.New().String()
-	newModel, _ := m.NewSequential(name)
-	// print layers
-	//for _, l := range layers {
-	//	fmt.Printf("%v\n", l)
-	//}
-
-	newModel.AddLayers(layers...)
-	err = newModel.Compile(individual.X(), individual.Y(), m.WithBatchSize(advCfg.BatchSize))
-	mutated = &Individual{
-		name:        name,
-		Sequential:  newModel,
-		inputRes:    individual.inputRes,
-		isGrayscale: individual.isGrayscale,
-		numClasses:  individual.numClasses,
-		lives:       1,
+	mutated, err = compileGenome(advCfg, genome)
+	if err != nil {
+		return nil, err
 	}
-	return
+	mutated.maybeInheritWeights(advCfg, individual)
+	return mutated, nil
 }
 
 type CrossoverFailedError struct {
@@ -407,172 +430,39 @@ func (err *CrossoverFailedError) Error() string {
 	return fmt.Sprintf("crossover failed: %v", err.recoverData)
 }
 
-func (individual *Individual) Crossover(advCfg AdvancedConfig, other *Individual) (child1, child2 *Individual, err1, err2 error) {
-	// get slices of layers of both models
-	layersLeft := make([]layer.Config, len(individual.Chain.Layers))
-	layersRight := make([]layer.Config, len(other.Chain.Layers))
-	copy(layersLeft, individual.Chain.Layers)
-	copy(layersRight, other.Chain.Layers)
-
-	// pick a random crossover point within the left model
-	crossoverPointLeft := rand.Intn(len(layersLeft))
-
-	// pick a random crossover point within the right model, but avoid illegal crossovers
-	var crossoverPointRight int
-	firstFCIndex := findFirstFCIndex(layersRight)
-	if _, ok := layersLeft[crossoverPointLeft].(layer.Flatten); ok {
-		crossoverPointRight = firstFCIndex - 1
-	} else if _, ok := layersLeft[crossoverPointLeft].(layer.FC); ok {
-		crossoverPointRight = firstFCIndex + rand.Intn(len(layersRight)-firstFCIndex)
-	} else {
-		crossoverPointRight = rand.Intn(firstFCIndex)
-	}
-	// swap layers
-	glass := make([]layer.Config, len(layersLeft))
-	copy(glass, layersLeft)
-	layersLeft, layersRight =
-		append(layersLeft[:crossoverPointLeft], layersRight[crossoverPointRight:]...),
-		append(layersRight[:crossoverPointRight], glass[crossoverPointLeft:]...)
-
-	// update inputs of layers at crossover points
-	updateInputs := func(layers []layer.Config, crossoverPoint int) error {
-		if conv2D, ok := layers[crossoverPoint].(layer.Conv2D); ok {
-			if crossoverPoint == 0 {
-				if individual.isGrayscale {
-					conv2D.Input = 1
-				} else {
-					conv2D.Input = 3
-				}
-			} else {
-				conv2D.Input = getPrevConv2DOutput(layers, crossoverPoint, individual.isGrayscale)
-			}
-			layers[crossoverPoint] = conv2D
-		} else if _, ok := layers[crossoverPoint].(layer.MaxPooling2D); ok {
-			nextConv2DIndex := findNextConv2DIndex(layers, crossoverPoint)
-			if nextConv2DIndex != -1 {
-				prevOutput := getPrevConv2DOutput(layers, crossoverPoint, individual.isGrayscale)
-				nextConv2D := layers[nextConv2DIndex].(layer.Conv2D)
-				nextConv2D.Input = prevOutput
-				layers[nextConv2DIndex] = nextConv2D
-			}
-		} else if fc, ok := layers[crossoverPoint].(layer.FC); ok {
-			if prevFC, ok := layers[crossoverPoint-1].(layer.FC); ok {
-				fc.Input = prevFC.Output
-				layers[crossoverPoint] = fc
-				return nil
-			}
-		}
-		firstFCIndex := findFirstFCIndex(layers)
-		input := (individual.Sequential.X().Inputs()[0].Shape())[2:]
-		res := (&utils.Resolution{Width: input[1], Height: input[0]}).AfterMany(layers[:firstFCIndex-1])
-		fc := layers[firstFCIndex].(layer.FC)
-		fc.Input = getPrevConv2DOutput(layers, firstFCIndex-1, individual.isGrayscale) * res.Width * res.Height
-		if fc.Input == 0 {
-			return errors.New("input is 0")
-		}
-		layers[firstFCIndex] = fc
-		return nil
+// Crossover recombines the genomes with the operator of advCfg. Each child that is valid is compiled even if
+// the other one isn't, a child is nil only together with its error
+func (individual *Individual) Crossover(rng *rand.Rand, advCfg AdvancedConfig, other *Individual) (child1, child2 *Individual, err1, err2 error) {
+	genome1, genome2, err1, err2 := individual.genome.Recombine(rng, advCfg, other.genome)
+	if err1 == nil {
+		child1, err1 = compileChild(advCfg, genome1, individual, other)
 	}
-	err1 = updateInputs(layersLeft, crossoverPointLeft)
-	err2 = updateInputs(layersRight, crossoverPointRight)
-	if err1 != nil || err2 != nil {
-		return nil, nil, err1, err2
+	if err2 == nil {
+		child2, err2 = compileChild(advCfg, genome2, other, individual)
 	}
-
-	defer func() {
-		if r := recover(); r != nil {
-			err1, err2 = &CrossoverFailedError{r}, &CrossoverFailedError{r}
-		}
-	}()
-
-	// create new models with swapped layers
-	child1Name := uuid.New().String()
-	child1Model, _ := m.NewSequential(child1Name)
-	child1Model.AddLayers(layersLeft...)
-	child2Name := uuid.New().String()
-	child2Model, _ := m.NewSequential(child2Name)
-	child2Model.AddLayers(layersRight...)
-
-	// compile models
-	err1 = child1Model.Compile(individual.X(), individual.Y(), m.WithBatchSize(advCfg.BatchSize))
-	err2 = child2Model.Compile(individual.X(), individual.Y(), m.WithBatchSize(advCfg.BatchSize))
-
-	return &Individual{
-			name:        child1Name,
-			Sequential:  child1Model,
-			inputRes:    individual.inputRes,
-			isGrayscale: individual.isGrayscale,
-			numClasses:  individual.numClasses,
-			lives:       1,
-		}, &Individual{
-			name:        child2Name,
-			Sequential:  child2Model,
-			inputRes:    individual.inputRes,
-			isGrayscale: individual.isGrayscale,
-			numClasses:  individual.numClasses,
-			lives:       1,
-		},
-		err1, err2
+	return
 }
 
 func (individual *Individual) CrossoverAlt(advCfg AdvancedConfig, other *Individual) (child1, child2 *Individual, err1, err2 error) {
-	// get slices of layers of both models
-	layersLeft := make([]layer.Config, len(individual.Chain.Layers))
-	layersRight := make([]layer.Config, len(other.Chain.Layers))
-	copy(layersLeft, individual.Chain.Layers)
-	copy(layersRight, other.Chain.Layers)
-
-	// find a first FC layer in both models
-	crossoverPointLeft := findFirstFCIndex(layersLeft)
-	crossoverPointRight := findFirstFCIndex(layersRight)
-
-	// swap layers
-	glass := make([]layer.Config, len(layersLeft))
-	copy(glass, layersLeft)
-	layersLeft, layersRight =
-		append(layersLeft[:crossoverPointLeft], layersRight[crossoverPointRight:]...),
-		append(layersRight[:crossoverPointRight], glass[crossoverPointLeft:]...)
-
-	// preserve inputs
-	fcLeft := layersLeft[crossoverPointLeft].(layer.FC)
-	fcRight := layersRight[crossoverPointRight].(layer.FC)
-	fcLeft.Input, fcRight.Input = fcRight.Input, fcLeft.Input
-	layersLeft[crossoverPointLeft], layersRight[crossoverPointRight] = fcLeft, fcRight
-
-	defer func() {
-		if r := recover(); r != nil {
-			err1, err2 = &CrossoverFailedError{r}, &CrossoverFailedError{r}
-		}
-	}()
-
-	// create new models with swapped layers
-	child1Name := uuid.New().String()
-	child1Model, _ := m.NewSequential(child1Name)
-	child1Model.AddLayers(layersLeft...)
-	child2Name := uuid.New().String()
-	child2Model, _ := m.NewSequential(child2Name)
-	child2Model.AddLayers(layersRight...)
+	genome1, genome2 := individual.genome.CrossoverAlt(other.genome)
+	return compileChildren(advCfg, individual, other, genome1, genome2)
+}
 
-	// compile models
-	err1 = child1Model.Compile(individual.X(), individual.Y(), m.WithBatchSize(advCfg.BatchSize))
-	err2 = child2Model.Compile(individual.X(), individual.Y(), m.WithBatchSize(advCfg.BatchSize))
+// compileChildren compiles children whose conv blocks start with ones of parent1 and parent2 respectively
+func compileChildren(advCfg AdvancedConfig, parent1, parent2 *Individual, genome1, genome2 Genome) (child1, child2 *Individual, err1, err2 error) {
+	child1, err1 = compileChild(advCfg, genome1, parent1, parent2)
+	child2, err2 = compileChild(advCfg, genome2, parent2, parent1)
+	return
+}
 
-	return &Individual{
-			name:        child1Name,
-			Sequential:  child1Model,
-			inputRes:    individual.inputRes,
-			isGrayscale: individual.isGrayscale,
-			numClasses:  individual.numClasses,
-			lives:       1,
-		}, &Individual{
-			name:        child2Name,
-			Sequential:  child2Model,
-			inputRes:    individual.inputRes,
-			isGrayscale: individual.isGrayscale,
-			numClasses:  individual.numClasses,
-			lives:       1,
-		},
-		err1, err2
+// compileChild compiles a child that takes after parents, the first one most of all
+func compileChild(advCfg AdvancedConfig, genome Genome, parents ...*Individual) (*Individual, error) {
+	child, err := compileGenome(advCfg, genome)
+	if err != nil {
+		return nil, &CrossoverFailedError{err}
+	}
+	child.maybeInheritWeights(advCfg, parents...)
+	return child, nil
 }
 
 type ClassProbability struct {
@@ -8,19 +8,25 @@ import (
 	m "github.com/m8u/goro/pkg/v1/model"
 	"golang.org/x/exp/rand"
 	"gorgonia.org/tensor"
+	"reflect"
 	"sotsuron/internal/datasets"
 	"sotsuron/internal/utils"
 	"testing"
 	"time"
 )
 
+// newTestRNG derives a random stream from the global source, which tests seed with the current time
+func newTestRNG() *rand.Rand {
+	return rand.New(rand.NewSource(rand.Uint64()))
+}
+
 func TestNewIndividual(t *testing.T) {
 	advCfg := DefaultAdvancedConfig()
 	inputWidth, inputHeight, numClasses, grayscale := 28, 28, 10, true
 	for i := 0; i < 1000; i++ {
 		name := uuid.New().String()
 		model, _ := m.NewSequential(name) // TODO: specify metrics
-		model.AddLayers(GenerateRandomStructure(advCfg, inputWidth, inputHeight, numClasses, grayscale)...)
+		model.AddLayers(GenerateRandomStructure(newTestRNG(), advCfg, inputWidth, inputHeight, numClasses, grayscale)...)
 		var channels int
 		if grayscale {
 			channels = 1
@@ -73,8 +79,9 @@ func TestIndividual_Mutate(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			//t.Parallel()
-			individual := NewIndividual(DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
-			_, err := individual.Mutate(DefaultAdvancedConfig())
+			individual, err := NewIndividual(newTestRNG(), DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
+			utils.MaybeCrash(err)
+			_, err = individual.Mutate(newTestRNG(), DefaultAdvancedConfig())
 			if errors.As(err, &NoValidConfigFound{}) || errors.As(err, &MutationFailedError{}) {
 				t.Skipf(err.Error())
 			} else if err != nil {
@@ -90,11 +97,12 @@ func TestIndividual_Mutate_repeatedly(t *testing.T) {
 	xTrain, yTrain, xTest, yTest, err := dataset.SplitTrainTest(0.8)
 	utils.MaybeCrash(err)
 
-	individual := NewIndividual(DefaultAdvancedConfig(), 28, 28, 10, true)
+	individual, err := NewIndividual(newTestRNG(), DefaultAdvancedConfig(), 28, 28, 10, true)
+	utils.MaybeCrash(err)
 	individual.CalculateFitnessBatch(context.Background(), nil, DefaultAdvancedConfig(), xTrain, yTrain, xTest, yTest)
-	individual, _ = individual.Mutate(DefaultAdvancedConfig())
+	individual, _ = individual.Mutate(newTestRNG(), DefaultAdvancedConfig())
 	individual.CalculateFitnessBatch(context.Background(), nil, DefaultAdvancedConfig(), xTrain, yTrain, xTest, yTest)
-	individual, _ = individual.Mutate(DefaultAdvancedConfig())
+	individual, _ = individual.Mutate(newTestRNG(), DefaultAdvancedConfig())
 	individual.CalculateFitnessBatch(context.Background(), nil, DefaultAdvancedConfig(), xTrain, yTrain, xTest, yTest)
 }
 
@@ -120,9 +128,11 @@ func TestIndividual_Crossover(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			individual := NewIndividual(DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
-			other := NewIndividual(DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
-			_, _, err1, err2 := individual.Crossover(DefaultAdvancedConfig(), other)
+			individual, err := NewIndividual(newTestRNG(), DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
+			utils.MaybeCrash(err)
+			other, err := NewIndividual(newTestRNG(), DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
+			utils.MaybeCrash(err)
+			_, _, err1, err2 := individual.Crossover(newTestRNG(), DefaultAdvancedConfig(), other)
 			if err1 != nil || err2 != nil {
 				t.Skipf("could not crossover: child1 error: %v, child2 error: %v", err1, err2)
 			}
@@ -130,6 +140,29 @@ func TestIndividual_Crossover(t *testing.T) {
 	}
 }
 
+func TestIndividual_Crossover_oneInvalid(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Crossover = CrossoverHeadBody
+	individual, err := compileGenome(advCfg, newTestGenome())
+	if err != nil {
+		t.Fatalf("compileGenome() error = %v", err)
+	}
+	// the head-body crossover gives the dense blocks of the other parent, which are broken, to the first child only
+	broken := newTestOtherGenome()
+	broken.DenseBlocks[0].Activation = "Bogus"
+	other := &Individual{genome: broken}
+	child1, child2, err1, err2 := individual.Crossover(newTestRNG(), advCfg, other)
+	if child1 != nil || err1 == nil {
+		t.Errorf("Crossover() child1 = %v, error = %v, want nil with an error", child1, err1)
+	}
+	if child2 == nil || err2 != nil {
+		t.Fatalf("Crossover() child2 = %v, error = %v, want a valid child", child2, err2)
+	}
+	if got := child2.Genome().DenseBlocks; !reflect.DeepEqual(got, newTestGenome().DenseBlocks) {
+		t.Errorf("Crossover() child2 dense blocks = %+v, want the ones of the first parent", got)
+	}
+}
+
 func TestIndividual_CalculateFitness(t *testing.T) {
 	rand.Seed(0)
 	type fields struct {
@@ -172,7 +205,8 @@ func TestIndividual_CalculateFitness(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			//t.Parallel()
-			individual := NewIndividual(DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
+			individual, err := NewIndividual(newTestRNG(), DefaultAdvancedConfig(), tt.fields.inputWidth, tt.fields.inputHeight, tt.fields.numClasses, true)
+			utils.MaybeCrash(err)
 			gotFitness, err := individual.CalculateFitnessBatch(context.Background(), nil, DefaultAdvancedConfig(), tt.args.xTrain, tt.args.yTrain, tt.args.xTest, tt.args.yTest)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CalculateFitnessBatch() error = %v, wantErr %v", err, tt.wantErr)
@@ -194,3 +228,26 @@ func TestIndividual_CalculateFitness(t *testing.T) {
 {151 10  0x15ab520 0xb73bc0 false 0xb73bc0}
 {7 20}
 */
+
+func TestPlateaued(t *testing.T) {
+	tests := []struct {
+		name       string
+		accuracies []float32
+		patience   int
+		want       bool
+	}{
+		{"off", []float32{0.5, 0.5, 0.5, 0.5}, 0, false},
+		{"too few epochs", []float32{0.5, 0.5}, 2, false},
+		{"improving", []float32{0.3, 0.4, 0.5, 0.6}, 2, false},
+		{"flat", []float32{0.5, 0.5, 0.505, 0.5}, 2, true},
+		{"recovered", []float32{0.5, 0.4, 0.45, 0.6}, 3, false},
+		{"worse than the best", []float32{0.6, 0.4, 0.55, 0.59}, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := plateaued(tt.accuracies, tt.patience, 0.01); got != tt.want {
+				t.Errorf("plateaued(%v, %d) = %v, want %v", tt.accuracies, tt.patience, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package evolution
+
+import (
+	"fmt"
+	g "github.com/m8u/gorgonia"
+	"github.com/m8u/goro/pkg/v1/layer"
+)
+
+// layerLearnables splits learnables of the model into groups that belong to the same layer
+func (individual *Individual) layerLearnables() ([]g.Nodes, error) {
+	learnables := individual.Learnables()
+	var groups []g.Nodes
+	n := 0
+	for _, l := range individual.Chain.Layers {
+		size := 0
+		switch l := l.(type) {
+		case layer.Conv2D:
+			size = 1
+		case layer.FC:
+			size = 2
+			if l.NoBias {
+				size = 1
+			}
+		}
+		if size == 0 {
+			continue
+		}
+		if n+size > len(learnables) {
+			return nil, fmt.Errorf("%s has fewer learnables than its layers need", individual.name)
+		}
+		groups = append(groups, learnables[n:n+size])
+		n += size
+	}
+	if n != len(learnables) {
+		return nil, fmt.Errorf("%s has more learnables than its layers need", individual.name)
+	}
+	return groups, nil
+}
+
+func sameShapes(a, b g.Nodes) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Shape().Eq(b[i].Shape()) {
+			return false
+		}
+	}
+	return true
+}
+
+// inheritWeights copies trained weights of parents to layers of the individual whose weights have the same shapes.
+// Every layer takes them from the closest position in any parent, earlier parents win ties, and each parent layer
+// is used once. The rest of the layers keep their fresh initialization
+func (individual *Individual) inheritWeights(parents ...*Individual) (inherited, total int, err error) {
+	groups, err := individual.layerLearnables()
+	if err != nil {
+		return 0, 0, err
+	}
+	var parentGroups [][]g.Nodes
+	for _, parent := range parents {
+		if !parent.trained {
+			continue
+		}
+		pg, err := parent.layerLearnables()
+		if err != nil {
+			return 0, 0, err
+		}
+		parentGroups = append(parentGroups, pg)
+	}
+	used := make(map[*g.Node]bool)
+	var desired g.Nodes
+	for i, group := range groups {
+		var best g.Nodes
+		bestDistance := -1
+		for _, pg := range parentGroups {
+			for k, candidate := range pg {
+				distance := i - k
+				if distance < 0 {
+					distance = -distance
+				}
+				if used[candidate[0]] || !sameShapes(group, candidate) || bestDistance != -1 && distance >= bestDistance {
+					continue
+				}
+				best, bestDistance = candidate, distance
+			}
+		}
+		if best == nil {
+			desired = append(desired, group...)
+			continue
+		}
+		used[best[0]] = true
+		desired = append(desired, best...)
+		inherited++
+	}
+	if inherited == 0 {
+		return 0, len(groups), nil
+	}
+	if err = individual.SetLearnables(desired); err != nil {
+		return 0, len(groups), err
+	}
+	individual.inherited = true
+	return inherited, len(groups), nil
+}
+
+// maybeInheritWeights makes a freshly compiled child inherit weights of its parents if advCfg.InheritWeights is set
+func (individual *Individual) maybeInheritWeights(advCfg AdvancedConfig, parents ...*Individual) {
+	if !advCfg.InheritWeights || individual == nil {
+		return
+	}
+	inherited, total, err := individual.inheritWeights(parents...)
+	if err != nil {
+		fmt.Println("WARNING: could not inherit weights:", err.Error())
+		return
+	}
+	fmt.Printf("%s inherited weights of %d of %d layers\n", individual.name, inherited, total)
+}
@@ -0,0 +1,72 @@
+package evolution
+
+import (
+	"testing"
+)
+
+// fillLearnables sets every weight of the individual to value
+func fillLearnables(t *testing.T, individual *Individual, value float32) {
+	specs, err := encodeLearnables(individual.Learnables())
+	if err != nil {
+		t.Fatalf("encodeLearnables() error = %v", err)
+	}
+	for _, spec := range specs {
+		for i := range spec.Data {
+			spec.Data[i] = value
+		}
+	}
+	if err = individual.setLearnables(specs); err != nil {
+		t.Fatalf("setLearnables() error = %v", err)
+	}
+}
+
+func allEqual(data []float32, value float32) bool {
+	for _, x := range data {
+		if x != value {
+			return false
+		}
+	}
+	return true
+}
+
+func TestIndividual_inheritWeights(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	parent, err := compileGenome(advCfg, newTestGenome())
+	if err != nil {
+		t.Fatalf("compileGenome() error = %v", err)
+	}
+	fillLearnables(t, parent, 0.5)
+
+	// the hidden FC shrinks, so both FCs change their shapes while conv blocks stay the same
+	genome := newTestGenome()
+	genome.DenseBlocks[0].Output = 16
+	child, err := compileGenome(advCfg, genome)
+	if err != nil {
+		t.Fatalf("compileGenome() error = %v", err)
+	}
+	if inherited, _, _ := child.inheritWeights(parent); inherited != 0 {
+		t.Errorf("inheritWeights() from an untrained parent inherited %d layers", inherited)
+	}
+
+	parent.trained = true
+	inherited, total, err := child.inheritWeights(parent)
+	if err != nil {
+		t.Fatalf("inheritWeights() error = %v", err)
+	}
+	if inherited != 2 || total != 4 {
+		t.Errorf("inheritWeights() = %d of %d layers, want 2 of 4", inherited, total)
+	}
+	if !child.inherited {
+		t.Errorf("inheritWeights() didn't mark the child as inherited")
+	}
+	groups, err := child.layerLearnables()
+	if err != nil {
+		t.Fatalf("layerLearnables() error = %v", err)
+	}
+	for i, group := range groups {
+		got := allEqual(group[0].Value().Data().([]float32), 0.5)
+		if want := i < 2; got != want {
+			t.Errorf("layer %d inherited = %v, want %v", i, got, want)
+		}
+	}
+}
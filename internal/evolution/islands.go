@@ -0,0 +1,340 @@
+package evolution
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/exp/rand"
+	"gorgonia.org/tensor"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	TopologyRing = "ring"
+	TopologyFull = "full"
+)
+
+type UnknownTopologyError struct {
+	topology string
+}
+
+func (err UnknownTopologyError) Error() string {
+	return fmt.Sprintf("unknown migration topology %q", err.topology)
+}
+
+// neighbours returns the islands that island i out of n sends its migrants to
+func neighbours(topology string, i, n int) ([]int, error) {
+	switch topology {
+	case "", TopologyRing:
+		if n < 2 {
+			return nil, nil
+		}
+		return []int{(i + 1) % n}, nil
+	case TopologyFull:
+		var to []int
+		for j := 0; j < n; j++ {
+			if j != i {
+				to = append(to, j)
+			}
+		}
+		return to, nil
+	default:
+		return nil, UnknownTopologyError{topology}
+	}
+}
+
+// numIslands is how many islands numIndividuals are split into, every island needs at least 2 individuals to breed
+func numIslands(advCfg AdvancedConfig, numIndividuals int) int {
+	n := advCfg.Islands
+	if n > numIndividuals/2 {
+		fmt.Printf("WARNING: %d individuals are too few for %d islands, using %d\n", numIndividuals, n, numIndividuals/2)
+		n = numIndividuals / 2
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// newIsland creates an empty sub-population with its own random stream derived from the species' one
+func (species *Species) newIsland(size int) *Species {
+	seed := species.rng.Uint64()
+	rngSource := &rand.PCGSource{}
+	rngSource.Seed(seed)
+	return &Species{
+		targetNumIndividuals: size,
+		generation:           species.generation,
+		inputRes:             species.inputRes,
+		numClasses:           species.numClasses,
+		grayscale:            species.grayscale,
+		seed:                 seed,
+		rng:                  rand.New(rngSource),
+		rngSource:            rngSource,
+	}
+}
+
+// splitIntoIslands deals individuals out to n islands of about the same size in turns,
+// then fills the islands up with random individuals
+func (species *Species) splitIntoIslands(advCfg AdvancedConfig, n int, individuals []*Individual) error {
+	for i := 0; i < n; i++ {
+		size := species.targetNumIndividuals / n
+		if i < species.targetNumIndividuals%n {
+			size++
+		}
+		species.islands = append(species.islands, species.newIsland(size))
+	}
+	for i, individual := range individuals {
+		island := species.islands[i%n]
+		island.individuals = append(island.individuals, individual)
+	}
+	for _, island := range species.islands {
+		if err := island.reseed(advCfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// population returns individuals of all islands, or the individuals of the species if it isn't split
+func (species *Species) population() []*Individual {
+	if len(species.islands) == 0 {
+		return species.individuals
+	}
+	var individuals []*Individual
+	for _, island := range species.islands {
+		individuals = append(individuals, island.individuals...)
+	}
+	return individuals
+}
+
+// migrantCopy compiles a copy of the individual with its weights and fitness for another island,
+// so that islands never share models
+func (individual *Individual) migrantCopy(advCfg AdvancedConfig) (*Individual, error) {
+	migrant, err := compileGenome(advCfg, individual.genome)
+	if err != nil {
+		return nil, err
+	}
+	if individual.trained {
+		if err = migrant.SetLearnables(individual.Learnables()); err != nil {
+			migrant.DisposeVMs()
+			return nil, err
+		}
+	}
+	migrant.fitness, migrant.objectives, migrant.trained = individual.fitness, individual.objectives, individual.trained
+	migrant.inherited, migrant.history, migrant.stoppedEarly = individual.inherited, individual.history, individual.stoppedEarly
+	migrant.lives, migrant.cached = individual.lives, individual.cached
+	return migrant, nil
+}
+
+// migrate sends copies of the best advCfg.MigrationRate of individuals of every island, but at least one,
+// to its neighbours, where they replace the worst individuals
+func (species *Species) migrate(advCfg AdvancedConfig) {
+	if advCfg.MigrationRate <= 0 {
+		return
+	}
+	n := len(species.islands)
+	immigrants := make([][]*Individual, n)
+	for i, island := range species.islands {
+		if len(island.individuals) == 0 {
+			continue
+		}
+		count := int(math.Ceil(float64(len(island.individuals)) * float64(advCfg.MigrationRate)))
+		migrants := sortedByFitness(island.individuals)[:clampInt(count, 1, len(island.individuals))]
+		to, err := neighbours(advCfg.MigrationTopology, i, n)
+		if err != nil {
+			fmt.Println("WARNING:", err.Error())
+			return
+		}
+		for _, j := range to {
+			for _, migrant := range migrants {
+				copied, err := migrant.migrantCopy(advCfg)
+				if err != nil {
+					fmt.Println("WARNING: could not migrate", migrant.name, "-", err.Error())
+					continue
+				}
+				fmt.Printf("%s migrates from island %d to island %d as %s\n", migrant.name, i, j, copied.name)
+				immigrants[j] = append(immigrants[j], copied)
+			}
+		}
+	}
+	for j, island := range species.islands {
+		island.receive(immigrants[j])
+	}
+}
+
+// receive replaces the worst individuals of the island with immigrants, the best one of its own always stays.
+// An immigrant takes the place and the age of the one it replaces
+func (species *Species) receive(immigrants []*Individual) {
+	ranked := sortedByFitness(species.individuals)
+	for k, immigrant := range immigrants {
+		worst := len(ranked) - 1 - k
+		if worst < 1 {
+			immigrant.DisposeVMs()
+			continue
+		}
+		for i, individual := range species.individuals {
+			if individual == ranked[worst] {
+				immigrant.lives = individual.lives
+				individual.DisposeVMs()
+				species.individuals[i] = immigrant
+			}
+		}
+	}
+}
+
+// combineProgress sums progress of the islands up, the generation is the one of the island that is furthest behind
+func combineProgress(progresses []Progress) Progress {
+	combined := Progress{Generation: progresses[0].Generation}
+	for _, progress := range progresses {
+		combined.Generation = tensor.MinInt(combined.Generation, progress.Generation)
+		combined.Individual += progress.Individual
+		combined.Rung = tensor.MaxInt(combined.Rung, progress.Rung)
+		combined.Dropped += progress.Dropped
+		combined.StoppedEarly += progress.StoppedEarly
+		combined.Niches += progress.Niches
+	}
+	return combined
+}
+
+// evolveIslands runs Evolve on all islands in parallel, advCfg.MigrationInterval generations at a time,
+// and lets individuals migrate between islands in between
+func (species *Species) evolveIslands(
+	ctx context.Context, advCfg AdvancedConfig, numGenerations int,
+	xTrain, yTrain, xTest, yTest tensor.Tensor,
+	progressChan chan Progress, allChartChan chan AllChartData, bestChartChan chan BestChartData, bestStructureChan chan BestStructure) error {
+
+	n := len(species.islands)
+	if _, err := neighbours(advCfg.MigrationTopology, 0, n); err != nil {
+		fmt.Println("WARNING:", err.Error())
+		advCfg.MigrationTopology = DefaultAdvancedConfig().MigrationTopology
+	}
+	interval := tensor.MaxInt(advCfg.MigrationInterval, 1)
+	// islands share the workers, so that the whole species doesn't train more individuals at once than configured
+	islandCfg := advCfg
+	islandCfg.MaxConcurrency = tensor.MaxInt(maxConcurrency(advCfg)/n, 1)
+	for _, island := range species.islands {
+		island.cache = species.cache
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	progresses := make([]Progress, n) // the latest progress of each island
+	finish := func() {
+		if progressChan != nil {
+			progress := combineProgress(progresses)
+			progress.CacheHitRate = species.cache.hitRate()
+			progress.Generation = -1
+			progressChan <- progress
+		}
+	}
+
+	start := time.Now()
+	firstGeneration := species.generation
+	for stop := species.generation + interval; ; stop += interval {
+		stop = tensor.MinInt(stop, numGenerations)
+		fmt.Printf("=============================== Islands until generation %d ===============================\n", stop-1)
+		// an island continues from its last generation, whose trained individuals it skips
+		for i, island := range species.islands {
+			progresses[i] = Progress{Generation: island.generation, Individual: island.generation * island.targetNumIndividuals}
+			for _, individual := range island.individuals {
+				if individual.trained {
+					progresses[i].Individual++
+				}
+			}
+		}
+
+		var wg sync.WaitGroup
+		errs := make([]error, n)
+		for i, island := range species.islands {
+			i, island := i, island
+			islandProgressChan := make(chan Progress)
+			var islandAllChartChan chan AllChartData
+			var islandBestChartChan chan BestChartData
+			if allChartChan != nil {
+				islandAllChartChan = make(chan AllChartData)
+			}
+			if bestChartChan != nil {
+				// the island sends its best without blocking, while the forwarder may be busy with its progress
+				islandBestChartChan = make(chan BestChartData, interval)
+			}
+			done := make(chan struct{})
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				defer close(done)
+				errs[i] = island.Evolve(ctx, islandCfg, stop, xTrain, yTrain, xTest, yTest,
+					islandProgressChan, islandAllChartChan, islandBestChartChan, nil)
+				if errs[i] != nil {
+					cancel()
+				}
+			}()
+			// forward events of the island until its Evolve returns, some of them are sent blocking
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case progress := <-islandProgressChan:
+						mu.Lock()
+						if progress.Generation == -1 {
+							// the island has finished the interval, the species reports its own end
+							progress.Generation = progresses[i].Generation
+						}
+						progresses[i] = progress
+						combined := combineProgress(progresses)
+						combined.CacheHitRate = species.cache.hitRate()
+						if evolved := combined.Generation - firstGeneration; evolved > 0 {
+							combined.ETASeconds = time.Since(start).Seconds() / float64(evolved) * float64(numGenerations-combined.Generation)
+						}
+						select {
+						case progressChan <- combined:
+						default:
+						}
+						mu.Unlock()
+					case data := <-islandAllChartChan:
+						data.Island = i
+						allChartChan <- data
+					case data := <-islandBestChartChan:
+						data.Island = i
+						bestChartChan <- data
+					case <-done:
+						for len(islandBestChartChan) > 0 {
+							data := <-islandBestChartChan
+							data.Island = i
+							bestChartChan <- data
+						}
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			if err != nil {
+				finish()
+				return err
+			}
+		}
+		if ctx.Err() != nil {
+			fmt.Println("ABORTING")
+			finish()
+			return nil
+		}
+		species.generation = stop - 1
+
+		best := species.Best()
+		select {
+		case bestStructureChan <- BestStructure{best.Chain.Layers, best.genome.Hyperparameters.Resolve(advCfg), nil}:
+		default:
+		}
+		if stop >= numGenerations {
+			species.maybeSaveCheckpoint(advCfg)
+			finish()
+			return nil
+		}
+		species.migrate(advCfg)
+		species.maybeSaveCheckpoint(advCfg)
+	}
+}
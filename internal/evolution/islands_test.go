@@ -0,0 +1,193 @@
+package evolution
+
+import (
+	"context"
+	"errors"
+	"gorgonia.org/tensor"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNeighbours(t *testing.T) {
+	tests := []struct {
+		topology string
+		i, n     int
+		want     []int
+	}{
+		{TopologyRing, 0, 3, []int{1}},
+		{TopologyRing, 2, 3, []int{0}},
+		{TopologyRing, 0, 1, nil},
+		{TopologyFull, 1, 3, []int{0, 2}},
+		{TopologyFull, 0, 1, nil},
+	}
+	for _, tt := range tests {
+		got, err := neighbours(tt.topology, tt.i, tt.n)
+		if err != nil {
+			t.Fatalf("neighbours(%q) error = %v", tt.topology, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("neighbours(%q, %d, %d) = %v, want %v", tt.topology, tt.i, tt.n, got, tt.want)
+		}
+	}
+	if _, err := neighbours("star", 0, 3); !errors.As(err, &UnknownTopologyError{}) {
+		t.Errorf("neighbours(%q) error = %v, want UnknownTopologyError", "star", err)
+	}
+}
+
+func TestNewSpecies_islands(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Islands = 2
+	species, err := NewSpecies(advCfg, 5, 16, 12, 5, true, newTestGenome(), newTestOtherGenome())
+	if err != nil {
+		t.Fatalf("NewSpecies() error = %v", err)
+	}
+	if len(species.islands) != 2 || len(species.individuals) != 0 {
+		t.Fatalf("NewSpecies() created %d islands and %d individuals outside of them, want 2 and 0",
+			len(species.islands), len(species.individuals))
+	}
+	for i, want := range []int{3, 2} {
+		if got := len(species.islands[i].individuals); got != want || species.islands[i].targetNumIndividuals != want {
+			t.Errorf("island %d has %d of %d individuals, want %d", i, got, species.islands[i].targetNumIndividuals, want)
+		}
+	}
+	if got := species.islands[1].individuals[0].Genome().ConvBlocks; !reflect.DeepEqual(got, newTestOtherGenome().ConvBlocks) {
+		t.Errorf("the second starting architecture went to %+v, want the second island", got)
+	}
+	if len(species.population()) != 5 {
+		t.Errorf("population() = %d individuals, want 5", len(species.population()))
+	}
+
+	advCfg.Islands = 4
+	if species, _ = NewSpecies(advCfg, 5, 16, 12, 5, true); len(species.islands) != 2 {
+		t.Errorf("NewSpecies() with 5 individuals created %d islands, want 2", len(species.islands))
+	}
+}
+
+func TestSpecies_migrate(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Islands, advCfg.MigrationRate = 3, 0.1
+	for _, topology := range []string{TopologyRing, TopologyFull} {
+		t.Run(topology, func(t *testing.T) {
+			advCfg.MigrationTopology = topology
+			species, err := NewSpecies(advCfg, 9, 16, 12, 5, true)
+			if err != nil {
+				t.Fatalf("NewSpecies() error = %v", err)
+			}
+			for i, island := range species.islands {
+				for j, individual := range island.individuals {
+					individual.trained, individual.fitness = true, float32(i)+1-float32(j)/10
+				}
+				fillLearnables(t, island.individuals[0], float32(i))
+			}
+			species.migrate(advCfg)
+
+			for j, island := range species.islands {
+				// fitness of the best individuals of the islands that send migrants to this one
+				senders := map[float32]bool{}
+				for i := range species.islands {
+					to, _ := neighbours(topology, i, 3)
+					for _, k := range to {
+						if k == j {
+							senders[float32(i)+1] = true
+						}
+					}
+				}
+				if island.individuals[0].fitness != float32(j)+1 {
+					t.Errorf("island %d lost its best individual", j)
+				}
+				for _, immigrant := range island.individuals[3-len(senders):] {
+					if !senders[immigrant.fitness] || !immigrant.trained {
+						t.Errorf("island %d received an individual with fitness %v, want the best of %v", j, immigrant.fitness, senders)
+						continue
+					}
+					for _, learnable := range immigrant.Learnables() {
+						if !allEqual(learnable.Value().Data().([]float32), immigrant.fitness-1) {
+							t.Fatalf("an immigrant to island %d doesn't have the weights of its original", j)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCombineProgress(t *testing.T) {
+	got := combineProgress([]Progress{
+		{Generation: 3, Individual: 10, Rung: 1, Dropped: 1},
+		{Generation: 2, Individual: 7, Rung: 0, StoppedEarly: 2},
+	})
+	want := Progress{Generation: 2, Individual: 17, Rung: 1, Dropped: 1, StoppedEarly: 2}
+	if got != want {
+		t.Errorf("combineProgress() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSpecies_Evolve_islands(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Seed = 1
+	advCfg.Epochs, advCfg.BatchSize = 1, 4
+	advCfg.Islands, advCfg.MigrationInterval = 2, 1
+	species, err := NewSpecies(advCfg, 6, 16, 12, 5, true, newTestGenome(), newTestOtherGenome())
+	if err != nil {
+		t.Fatalf("NewSpecies() error = %v", err)
+	}
+	rng := newTestRNG()
+	backing := make([]float32, 8*12*16)
+	for i := range backing {
+		backing[i] = rng.Float32()
+	}
+	labels := make([]float32, 8*5)
+	for i := 0; i < 8; i++ {
+		labels[i*5+i%5] = 1
+	}
+	x := tensor.New(tensor.WithShape(8, 1, 12, 16), tensor.WithBacking(backing))
+	y := tensor.New(tensor.WithShape(8, 5), tensor.WithBacking(labels))
+
+	// three intervals of one generation, each island continues from the generation it has reported last
+	bestChartChan := make(chan BestChartData, 100)
+	if err = species.Evolve(context.Background(), advCfg, 3, x, y, x, y, nil, nil, bestChartChan, nil); err != nil {
+		t.Fatalf("Evolve() error = %v", err)
+	}
+	close(bestChartChan)
+	generations := make([][]int, 2)
+	for data := range bestChartChan {
+		generations[data.Island] = append(generations[data.Island], data.Generation)
+	}
+	for i, got := range generations {
+		if want := []int{0, 1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("island %d reported generations %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSpecies_SaveCheckpoint_islands(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Islands = 2
+	species, err := NewSpecies(advCfg, 4, 12, 12, 4, true)
+	if err != nil {
+		t.Fatalf("NewSpecies() error = %v", err)
+	}
+	species.islands[1].generation = 3
+	path := filepath.Join(t.TempDir(), "checkpoint.gob")
+	if err = species.SaveCheckpoint(path, advCfg); err != nil {
+		t.Fatalf("SaveCheckpoint() error = %v", err)
+	}
+	restored, _, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint() error = %v", err)
+	}
+	if len(restored.islands) != 2 || restored.islands[1].generation != 3 {
+		t.Fatalf("LoadCheckpoint() restored %d islands, want 2 with the second one in generation 3", len(restored.islands))
+	}
+	if restored.inputRes != species.inputRes || restored.numClasses != 4 || !restored.grayscale {
+		t.Errorf("LoadCheckpoint() didn't restore what the species' individuals are generated for")
+	}
+	for i, island := range species.islands {
+		for j, individual := range island.individuals {
+			if got := restored.islands[i].individuals[j].name; got != individual.name {
+				t.Errorf("island %d individual %d = %s, want %s", i, j, got, individual.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package evolution
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sotsuron/internal/utils"
+)
+
+// modelFile is a portable form of a trained individual. BatchNorm layers keep no running statistics, so a model
+// with them normalizes by statistics of the batch it predicts, and its predictions of an image depend on the batch
+type modelFile struct {
+	Name            string
+	Layers          []LayerSpec
+	Hyperparameters Hyperparameters
+	Weights         []tensorSpec
+	InputRes        utils.Resolution
+	Grayscale       bool
+	ClassNames      []string
+	BatchDependent  bool // has BatchNorm layers
+}
+
+// SaveModel writes the architecture and learned weights of an individual to path as JSON
+func (individual *Individual) SaveModel(path string, classNames []string) error {
+	if len(classNames) != individual.numClasses {
+		return fmt.Errorf("got %d class names for a model with %d classes", len(classNames), individual.numClasses)
+	}
+	layers, err := EncodeLayers(individual.Chain.Layers)
+	if err != nil {
+		return err
+	}
+	weights, err := encodeLearnables(individual.Learnables())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(modelFile{
+		Name:            individual.name,
+		Layers:          layers,
+		Hyperparameters: individual.genome.Hyperparameters,
+		Weights:         weights,
+		InputRes:        individual.inputRes,
+		Grayscale:       individual.isGrayscale,
+		ClassNames:      classNames,
+		BatchDependent:  batchDependent(layers),
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// batchDependent tells if outputs of layers depend on the batch at inference
+func batchDependent(layers []LayerSpec) bool {
+	for _, spec := range layers {
+		if spec.Type == "BatchNorm" {
+			return true
+		}
+	}
+	return false
+}
+
+func readModelFile(path string) (model modelFile, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return modelFile{}, err
+	}
+	err = json.Unmarshal(data, &model)
+	return
+}
+
+// LoadModel reads a model saved with SaveModel and returns an individual ready for Predict
+func LoadModel(path string) (individual *Individual, classNames []string, err error) {
+	model, err := readModelFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	layers, err := DecodeLayers(model.Layers)
+	if err != nil {
+		return nil, nil, err
+	}
+	individual, err = compileIndividual(DefaultAdvancedConfig(), model.Name, layers, model.Hyperparameters, model.InputRes, len(model.ClassNames), model.Grayscale)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = individual.setLearnables(model.Weights); err != nil {
+		return nil, nil, err
+	}
+	individual.trained = true
+	return individual, model.ClassNames, nil
+}
+
+// LoadModelGenome reads only the architecture of a model saved with SaveModel, without compiling it
+func LoadModelGenome(path string) (Genome, error) {
+	model, err := readModelFile(path)
+	if err != nil {
+		return Genome{}, err
+	}
+	layers, err := DecodeLayers(model.Layers)
+	if err != nil {
+		return Genome{}, err
+	}
+	channels := 3
+	if model.Grayscale {
+		channels = 1
+	}
+	genome, err := EncodeGenome(layers, model.InputRes, channels)
+	if err != nil {
+		return Genome{}, err
+	}
+	genome.Hyperparameters = model.Hyperparameters
+	return genome, genome.Hyperparameters.validate()
+}
+
+// InputResolution returns the resolution of images the individual expects
+func (individual *Individual) InputResolution() utils.Resolution {
+	return individual.inputRes
+}
+
+// IsGrayscale tells whether the individual expects grayscale images
+func (individual *Individual) IsGrayscale() bool {
+	return individual.isGrayscale
+}
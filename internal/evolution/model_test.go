@@ -0,0 +1,67 @@
+package evolution
+
+import (
+	"golang.org/x/exp/rand"
+	"gorgonia.org/tensor"
+	"path/filepath"
+	"reflect"
+	"sotsuron/internal/nn"
+	"testing"
+)
+
+func TestIndividual_SaveModel(t *testing.T) {
+	classNames := []string{"cat", "dog", "frog"}
+	individual, err := NewIndividual(newTestRNG(), DefaultAdvancedConfig(), 12, 10, len(classNames), true)
+	if err != nil {
+		t.Fatalf("NewIndividual() error = %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := individual.SaveModel(path, classNames); err != nil {
+		t.Fatalf("SaveModel() error = %v", err)
+	}
+	if err := individual.SaveModel(path, classNames[:1]); err == nil {
+		t.Errorf("SaveModel() with wrong number of class names must fail")
+	}
+	file, err := readModelFile(path)
+	if err != nil {
+		t.Fatalf("readModelFile() error = %v", err)
+	}
+	hasBatchNorm := false
+	for _, config := range individual.Chain.Layers {
+		if _, ok := config.(nn.BatchNorm); ok {
+			hasBatchNorm = true
+		}
+	}
+	if file.BatchDependent != hasBatchNorm {
+		t.Errorf("SaveModel() BatchDependent = %v, want %v", file.BatchDependent, hasBatchNorm)
+	}
+	loaded, loadedClassNames, err := LoadModel(path)
+	if err != nil {
+		t.Fatalf("LoadModel() error = %v", err)
+	}
+	if !reflect.DeepEqual(loadedClassNames, classNames) {
+		t.Errorf("LoadModel() classNames = %v, want %v", loadedClassNames, classNames)
+	}
+	if loaded.InputResolution() != individual.InputResolution() || loaded.IsGrayscale() != individual.IsGrayscale() {
+		t.Errorf("LoadModel() input = %v %v, want %v %v",
+			loaded.InputResolution(), loaded.IsGrayscale(), individual.InputResolution(), individual.IsGrayscale())
+	}
+
+	backing := make([]float32, 12*10)
+	for i := range backing {
+		backing[i] = rand.Float32()
+	}
+	x := tensor.New(tensor.WithShape(1, 1, 10, 12), tensor.WithBacking(backing))
+	want, err := individual.Predict(x, classNames)
+	if err != nil {
+		t.Fatalf("Predict() error = %v", err)
+	}
+	got, err := loaded.Predict(x, loadedClassNames)
+	if err != nil {
+		t.Fatalf("Predict() of loaded model error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Predict() of loaded model = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,148 @@
+package evolution
+
+import (
+	"fmt"
+	"github.com/m8u/goro/pkg/v1/layer"
+	"golang.org/x/exp/rand"
+	"math"
+	"sotsuron/internal/utils"
+)
+
+// resizeByQuarter grows or shrinks size by 25%, but at least by 1, within [1, max]
+func resizeByQuarter(rng *rand.Rand, size, max int) int {
+	step := int(math.Round(float64(size) / 4))
+	if step < 1 {
+		step = 1
+	}
+	if rng.Float32() < 0.5 {
+		step = -step
+	}
+	return clampInt(size+step, 1, max)
+}
+
+// plusMinusOne adds or subtracts 1 from x within [min, max]
+func plusMinusOne(rng *rand.Rand, x, min, max int) int {
+	if rng.Float32() < 0.5 {
+		return clampInt(x+1, min, max)
+	}
+	return clampInt(x-1, min, max)
+}
+
+// otherActivation picks an activation function different from the given one
+func otherActivation(rng *rand.Rand, activation string) string {
+	for {
+		other := activationFnToString(activationFns[rng.Intn(len(activationFns))])
+		if other != activation {
+			return other
+		}
+	}
+}
+
+// dropoutStep is how much a Dropout probability changes by in a mutation
+const dropoutStep = 0.05
+
+// nudgeDropout changes the Dropout probability by dropoutStep within [advCfg.MinDropout, advCfg.MaxDropout].
+// Dropout that is off starts at advCfg.MinDropout, but at least at dropoutStep, and stepping below it switches Dropout off
+func nudgeDropout(rng *rand.Rand, advCfg AdvancedConfig, dropout float64) float64 {
+	if advCfg.MaxDropout <= 0 {
+		return dropout
+	}
+	if dropout == 0 {
+		return math.Min(math.Max(advCfg.MinDropout, dropoutStep), advCfg.MaxDropout)
+	}
+	step := dropoutStep
+	if rng.Float32() < 0.5 {
+		step = -step
+	}
+	nudged := math.Round((dropout+step)*100) / 100
+	if nudged < advCfg.MinDropout || nudged <= 0 {
+		return 0
+	}
+	return clampFloat(nudged, advCfg.MinDropout, advCfg.MaxDropout)
+}
+
+// mutate applies every small-step operator to the block with its chance in advCfg: the kernel size changes by 1,
+// the number of filters by 25%, the activation function, the stride or the pad by 1, Dropout by 0.05 and batch
+// normalization is switched on or off. Changes that would leave less
+// than the minimal resolution after layersAfter are undone
+func (block ConvBlock) mutate(rng *rand.Rand, advCfg AdvancedConfig, res utils.Resolution, layersAfter []layer.Config) ConvBlock {
+	minOutputRes := (&utils.Resolution{
+		Width:  advCfg.MinResolutionWidth,
+		Height: advCfg.MinResolutionHeight,
+	}).CalculateMinRequiredBefore(layersAfter)
+	fits := func(block ConvBlock) bool {
+		outputRes := res.After(block.layer(0))
+		return outputRes.Width >= minOutputRes.Width && outputRes.Height >= minOutputRes.Height
+	}
+	maxKernelSize, maxPad, maxStride := advCfg.MaxPoolKernelSize, advCfg.MaxPoolPad, advCfg.MaxPoolStride
+	if block.Type == BlockConv2D {
+		maxKernelSize, maxPad, maxStride = advCfg.MaxConvKernelSize, advCfg.MaxConvPad, advCfg.MaxConvStride
+	}
+
+	if rng.Float32() < advCfg.KernelMutationChance {
+		mutated := block
+		if rng.Float32() < 0.5 {
+			mutated.Height, mutated.Width = clampInt(block.Height+1, 2, maxKernelSize), clampInt(block.Width+1, 2, maxKernelSize)
+		} else {
+			mutated.Height, mutated.Width = clampInt(block.Height-1, 2, maxKernelSize), clampInt(block.Width-1, 2, maxKernelSize)
+		}
+		if fits(mutated) {
+			fmt.Printf("changing kernel of %s block to %dx%d\n", block.Type, mutated.Height, mutated.Width)
+			block = mutated
+		}
+	}
+	if rng.Float32() < advCfg.StrideMutationChance {
+		mutated := block
+		mutated.Stride = plusMinusOne(rng, block.Stride, 1, maxStride)
+		if fits(mutated) {
+			fmt.Println("changing stride of", block.Type, "block to", mutated.Stride)
+			block = mutated
+		}
+	}
+	if rng.Float32() < advCfg.PadMutationChance {
+		mutated := block
+		mutated.Pad = plusMinusOne(rng, block.Pad, 0, maxPad)
+		if fits(mutated) {
+			fmt.Println("changing pad of", block.Type, "block to", mutated.Pad)
+			block = mutated
+		}
+	}
+	if block.Type != BlockConv2D {
+		return block
+	}
+	if rng.Float32() < advCfg.FiltersMutationChance {
+		block.Output = resizeByQuarter(rng, block.Output, advCfg.MaxConvOutput)
+		fmt.Println("changing filters of Conv2D block to", block.Output)
+	}
+	if rng.Float32() < advCfg.ActivationMutationChance {
+		block.Activation = otherActivation(rng, block.Activation)
+		fmt.Println("changing activation of Conv2D block to", block.Activation)
+	}
+	if rng.Float32() < advCfg.DropoutMutationChance {
+		block.Dropout = nudgeDropout(rng, advCfg, block.Dropout)
+		fmt.Println("changing Dropout of Conv2D block to", block.Dropout)
+	}
+	if rng.Float32() < advCfg.BatchNormMutationChance {
+		block.BatchNorm = !block.BatchNorm
+		fmt.Println("switching batch normalization of Conv2D block to", block.BatchNorm)
+	}
+	return block
+}
+
+// mutate resizes the hidden dense block by 25%, switches its activation function and changes its Dropout by 0.05,
+// each with its chance in advCfg
+func (block DenseBlock) mutate(rng *rand.Rand, advCfg AdvancedConfig) DenseBlock {
+	if rng.Float32() < advCfg.DenseSizeMutationChance {
+		block.Output = resizeByQuarter(rng, block.Output, advCfg.MaxDenseSize)
+		fmt.Println("resizing dense block to", block.Output)
+	}
+	if rng.Float32() < advCfg.ActivationMutationChance {
+		block.Activation = otherActivation(rng, block.Activation)
+		fmt.Println("changing activation of dense block to", block.Activation)
+	}
+	if rng.Float32() < advCfg.DropoutMutationChance {
+		block.Dropout = nudgeDropout(rng, advCfg, block.Dropout)
+		fmt.Println("changing Dropout of dense block to", block.Dropout)
+	}
+	return block
+}
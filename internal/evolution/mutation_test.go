@@ -0,0 +1,162 @@
+package evolution
+
+import (
+	"sotsuron/internal/utils"
+	"testing"
+)
+
+// withOnlyMutation returns advCfg where only the operator set by enable is applied
+func withOnlyMutation(enable func(advCfg *AdvancedConfig)) AdvancedConfig {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.KernelMutationChance = 0
+	advCfg.StrideMutationChance = 0
+	advCfg.PadMutationChance = 0
+	advCfg.FiltersMutationChance = 0
+	advCfg.ActivationMutationChance = 0
+	advCfg.DenseSizeMutationChance = 0
+	advCfg.DropoutMutationChance = 0
+	advCfg.BatchNormMutationChance = 0
+	enable(&advCfg)
+	return advCfg
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestConvBlock_mutate(t *testing.T) {
+	genome := newTestGenome()
+	conv := genome.ConvBlocks[0]
+	res := genome.InputRes
+	layersAfter := genome.convLayersFrom(1)
+	tests := []struct {
+		name   string
+		enable func(advCfg *AdvancedConfig)
+		check  func(mutated ConvBlock) bool
+	}{
+		{"kernel", func(advCfg *AdvancedConfig) { advCfg.KernelMutationChance = 1 }, func(mutated ConvBlock) bool {
+			return abs(mutated.Height-conv.Height) == 1 && mutated.Height-conv.Height == mutated.Width-conv.Width
+		}},
+		{"pad", func(advCfg *AdvancedConfig) { advCfg.PadMutationChance = 1 }, func(mutated ConvBlock) bool {
+			return abs(mutated.Pad-conv.Pad) == 1
+		}},
+		{"filters", func(advCfg *AdvancedConfig) { advCfg.FiltersMutationChance = 1 }, func(mutated ConvBlock) bool {
+			return mutated.Output == 6 || mutated.Output == 10
+		}},
+		{"activation", func(advCfg *AdvancedConfig) { advCfg.ActivationMutationChance = 1 }, func(mutated ConvBlock) bool {
+			_, err := activationFnFromString(mutated.Activation)
+			return err == nil && mutated.Activation != conv.Activation
+		}},
+		{"dropout", func(advCfg *AdvancedConfig) { advCfg.DropoutMutationChance = 1 }, func(mutated ConvBlock) bool {
+			return mutated.Dropout == 0.1 && mutated.BatchNorm == conv.BatchNorm
+		}},
+		{"batch norm", func(advCfg *AdvancedConfig) { advCfg.BatchNormMutationChance = 1 }, func(mutated ConvBlock) bool {
+			return mutated.BatchNorm != conv.BatchNorm && mutated.Dropout == conv.Dropout
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advCfg := withOnlyMutation(tt.enable)
+			for i := 0; i < 20; i++ {
+				mutated := conv.mutate(newTestRNG(), advCfg, res, layersAfter)
+				if !tt.check(mutated) {
+					t.Fatalf("mutate() of %+v = %+v", conv, mutated)
+				}
+			}
+		})
+	}
+}
+
+func TestConvBlock_mutate_keepsResolution(t *testing.T) {
+	// the last conv block of the test genome leaves 6x4, so growing the kernel to 4x4 would leave 5x3
+	genome := newTestGenome()
+	genome.ConvBlocks[2].Pad = 0
+	block := genome.ConvBlocks[2]
+	res := utils.Resolution{Width: 8, Height: 6}
+	advCfg := withOnlyMutation(func(advCfg *AdvancedConfig) { advCfg.KernelMutationChance = 1 })
+	advCfg.MinResolutionWidth, advCfg.MinResolutionHeight = 6, 4
+	for i := 0; i < 20; i++ {
+		mutated := block.mutate(newTestRNG(), advCfg, res, genome.convLayersFrom(3))
+		if mutated.Height > block.Height {
+			t.Fatalf("mutate() grew the kernel to %dx%d below the minimal resolution", mutated.Height, mutated.Width)
+		}
+	}
+}
+
+func TestDenseBlock_mutate(t *testing.T) {
+	block := DenseBlock{Output: 32, Activation: "Sigmoid", Dropout: 0.5}
+	advCfg := withOnlyMutation(func(advCfg *AdvancedConfig) {
+		advCfg.DenseSizeMutationChance = 1
+		advCfg.ActivationMutationChance = 1
+	})
+	for i := 0; i < 20; i++ {
+		mutated := block.mutate(newTestRNG(), advCfg)
+		if mutated.Output != 24 && mutated.Output != 40 {
+			t.Errorf("mutate() resized %d outputs to %d", block.Output, mutated.Output)
+		}
+		if mutated.Activation == block.Activation || mutated.Dropout != block.Dropout {
+			t.Errorf("mutate() of %+v = %+v", block, mutated)
+		}
+	}
+}
+
+func TestNudgeDropout(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.MinDropout, advCfg.MaxDropout = 0.1, 0.5
+	tests := []struct {
+		dropout float64
+		want    []float64
+	}{
+		{0, []float64{0.1}},
+		{0.1, []float64{0, 0.15}},
+		{0.15, []float64{0.1, 0.2}},
+		{0.3, []float64{0.25, 0.35}},
+		{0.5, []float64{0.45, 0.5}},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := nudgeDropout(newTestRNG(), advCfg, tt.dropout)
+			if got != tt.want[0] && got != tt.want[len(tt.want)-1] {
+				t.Errorf("nudgeDropout(%v) = %v, want one of %v", tt.dropout, got, tt.want)
+			}
+		}
+	}
+	advCfg.MaxDropout = 0
+	if got := nudgeDropout(newTestRNG(), advCfg, 0); got != 0 {
+		t.Errorf("nudgeDropout() with Dropout turned off in advCfg = %v, want 0", got)
+	}
+}
+
+func TestDenseBlock_mutate_dropout(t *testing.T) {
+	block := DenseBlock{Output: 32, Activation: "Sigmoid", Dropout: 0.3}
+	advCfg := withOnlyMutation(func(advCfg *AdvancedConfig) { advCfg.DropoutMutationChance = 1 })
+	for i := 0; i < 20; i++ {
+		mutated := block.mutate(newTestRNG(), advCfg)
+		if mutated.Dropout != 0.25 && mutated.Dropout != 0.35 || mutated.Output != block.Output {
+			t.Errorf("mutate() of %+v = %+v, want Dropout changed by 0.05 only", block, mutated)
+		}
+	}
+}
+
+func TestResizeByQuarter(t *testing.T) {
+	tests := []struct {
+		size, max int
+		want      []int
+	}{
+		{1, 16, []int{1, 2}},
+		{2, 16, []int{1, 3}},
+		{16, 16, []int{12, 16}},
+		{100, 512, []int{75, 125}},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := resizeByQuarter(newTestRNG(), tt.size, tt.max)
+			if got != tt.want[0] && got != tt.want[1] {
+				t.Errorf("resizeByQuarter(%d, %d) = %d, want one of %v", tt.size, tt.max, got, tt.want)
+			}
+		}
+	}
+}
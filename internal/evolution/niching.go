@@ -0,0 +1,221 @@
+package evolution
+
+import (
+	"fmt"
+	"golang.org/x/exp/rand"
+	"math"
+)
+
+// niche is a cluster of individuals of similar structure, which compete for parent pairs with each other first
+type niche struct {
+	id             int
+	representative Genome // the best member of the previous generation, new individuals are compared with it
+	members        []*Individual
+	bestFitness    float32 // ever reached by a member
+	stagnation     int     // generations since bestFitness has improved
+}
+
+// relativeDifference is |a - b| relative to the larger of them, 0 if both are 0
+func relativeDifference(a, b int) float32 {
+	larger := math.Max(math.Abs(float64(a)), math.Abs(float64(b)))
+	if larger == 0 {
+		return 0
+	}
+	return float32(math.Abs(float64(a-b)) / larger)
+}
+
+func differs(different bool) float32 {
+	if different {
+		return 1
+	}
+	return 0
+}
+
+// distance is the share of properties the blocks differ in, sizes count by how much they differ.
+// Blocks of different types differ completely
+func (block ConvBlock) distance(other ConvBlock) float32 {
+	if block.Type != other.Type {
+		return 1
+	}
+	return (relativeDifference(block.Output, other.Output) +
+		relativeDifference(block.Height, other.Height) + relativeDifference(block.Width, other.Width) +
+		differs(block.Pad != other.Pad) + differs(block.Stride != other.Stride) +
+		differs(block.Activation != other.Activation) + differs((block.Skip == nil) != (other.Skip == nil))) / 7
+}
+
+func (block DenseBlock) distance(other DenseBlock) float32 {
+	return (relativeDifference(block.Output, other.Output) + differs(block.Activation != other.Activation)) / 2
+}
+
+// structuralDistance tells how different the layers of the genomes are, from 0 for the same layers to 1 for
+// nothing in common. Conv and dense blocks are aligned by their positions, a block without a counterpart adds 1,
+// matched ones add their distance, and the sum is divided by the number of aligned positions
+func structuralDistance(genome, other Genome) float32 {
+	var distance float32
+	positions := 0
+	for i := 0; i < len(genome.ConvBlocks) || i < len(other.ConvBlocks); i++ {
+		positions++
+		if i >= len(genome.ConvBlocks) || i >= len(other.ConvBlocks) {
+			distance++
+			continue
+		}
+		distance += genome.ConvBlocks[i].distance(other.ConvBlocks[i])
+	}
+	for i := 0; i < len(genome.DenseBlocks) || i < len(other.DenseBlocks); i++ {
+		positions++
+		if i >= len(genome.DenseBlocks) || i >= len(other.DenseBlocks) {
+			distance++
+			continue
+		}
+		distance += genome.DenseBlocks[i].distance(other.DenseBlocks[i])
+	}
+	if positions == 0 {
+		return 0
+	}
+	return distance / float32(positions)
+}
+
+// speciate puts every individual into the first niche whose representative is closer than
+// advCfg.CompatibilityThreshold, or into a new niche. Empty niches are forgotten, and the best member of every niche
+// becomes its representative and tells whether the niche is stagnating
+func (species *Species) speciate(advCfg AdvancedConfig) {
+	// a generation is ranked again when evolution is resumed, it shouldn't count as another one without improvement
+	nextGeneration := species.generation > species.nichesGeneration
+	species.nichesGeneration = species.generation
+	for _, niche := range species.niches {
+		niche.members = nil
+	}
+	for _, individual := range species.individuals {
+		var home *niche
+		for _, candidate := range species.niches {
+			if structuralDistance(candidate.representative, individual.genome) < advCfg.CompatibilityThreshold {
+				home = candidate
+				break
+			}
+		}
+		if home == nil {
+			species.nextNicheID++
+			home = &niche{
+				id:             species.nextNicheID,
+				representative: individual.Genome(),
+				bestFitness:    float32(math.Inf(-1)),
+			}
+			species.niches = append(species.niches, home)
+		}
+		home.members = append(home.members, individual)
+	}
+
+	var niches []*niche
+	for _, niche := range species.niches {
+		if len(niche.members) == 0 {
+			fmt.Printf("niche %d has died out\n", niche.id)
+			continue
+		}
+		best := sortedByFitness(niche.members)[0]
+		niche.representative = best.Genome()
+		if best.fitness > niche.bestFitness {
+			niche.bestFitness, niche.stagnation = best.fitness, 0
+		} else if nextGeneration {
+			niche.stagnation++
+		}
+		niches = append(niches, niche)
+	}
+	species.niches = niches
+}
+
+// stagnant tells whether the niche hasn't improved for advCfg.StagnationLimit generations
+func (niche *niche) stagnant(advCfg AdvancedConfig) bool {
+	return advCfg.StagnationLimit > 0 && niche.stagnation >= advCfg.StagnationLimit
+}
+
+// sharePairs shares numPairs parent pairs out between niches in proportion to the average fitness of their members,
+// which is their fitness shared within the niche. Stagnant niches get none, unless the best individual is in them
+func (species *Species) sharePairs(advCfg AdvancedConfig, numPairs int) []int {
+	best := species.Best()
+	weights := make([]float64, len(species.niches))
+	minAverage := math.Inf(1)
+	for _, niche := range species.niches {
+		var sum float64
+		for _, member := range niche.members {
+			sum += float64(member.fitness)
+		}
+		minAverage = math.Min(minAverage, sum/float64(len(niche.members)))
+	}
+	var total float64
+	for i, niche := range species.niches {
+		var sum float64
+		hasBest := false
+		for _, member := range niche.members {
+			sum += float64(member.fitness)
+			hasBest = hasBest || member == best
+		}
+		if niche.stagnant(advCfg) && !hasBest {
+			fmt.Printf("niche %d has stagnated for %d generations, it won't breed\n", niche.id, niche.stagnation)
+			continue
+		}
+		// negative fitness is shifted like in roulette selection, so that the least fit niche still gets a small share
+		weights[i] = sum/float64(len(niche.members)) - math.Min(minAverage, 0) + 1e-3
+		total += weights[i]
+	}
+
+	// largest remainder method
+	pairs := make([]int, len(weights))
+	remainders := make([]float64, len(weights))
+	shared := 0
+	for i, weight := range weights {
+		quota := weight / total * float64(numPairs)
+		pairs[i] = int(quota)
+		remainders[i] = quota - float64(pairs[i])
+		shared += pairs[i]
+	}
+	for ; shared < numPairs; shared++ {
+		largest := 0
+		for i := range remainders {
+			if remainders[i] > remainders[largest] {
+				largest = i
+			}
+		}
+		pairs[largest]++
+		remainders[largest] = -1
+	}
+	return pairs
+}
+
+// selectParentPairs picks parent pairs from the whole population, or from within niches if advCfg.Speciation is on
+func (species *Species) selectParentPairs(rng *rand.Rand, advCfg AdvancedConfig, selector Selector, numPairs int) (pairs [][2]*Individual) {
+	if !advCfg.Speciation || len(species.niches) == 0 {
+		return SelectParentPairs(rng, selector, species.individuals, numPairs)
+	}
+	for i, n := range species.sharePairs(advCfg, numPairs) {
+		if n > 0 {
+			pairs = append(pairs, SelectParentPairs(rng, selector, species.niches[i].members, n)...)
+		}
+	}
+	return
+}
+
+// NicheBest describes the best individual of a niche for the UI
+type NicheBest struct {
+	ID              int
+	Size            int
+	Stagnation      int
+	Fitness         float32
+	Layers          []simpleLayerConfig
+	Hyperparameters Hyperparameters
+}
+
+// nicheBests describes the best individual of every niche, nil if the species isn't speciated
+func (species *Species) nicheBests(advCfg AdvancedConfig) (bests []NicheBest) {
+	for _, niche := range species.niches {
+		best := sortedByFitness(niche.members)[0]
+		bests = append(bests, NicheBest{
+			ID:              niche.id,
+			Size:            len(niche.members),
+			Stagnation:      niche.stagnation,
+			Fitness:         best.fitness,
+			Layers:          SimplifyLayers(best.Chain.Layers),
+			Hyperparameters: best.genome.Hyperparameters.Resolve(advCfg),
+		})
+	}
+	return
+}
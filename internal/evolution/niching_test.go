@@ -0,0 +1,111 @@
+package evolution
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStructuralDistance(t *testing.T) {
+	filters := newTestGenome()
+	filters.ConvBlocks[0].Output *= 2
+	deeper := newTestGenome()
+	deeper.ConvBlocks = append(deeper.ConvBlocks, deeper.ConvBlocks[2])
+	tests := []struct {
+		name         string
+		other        Genome
+		wantMin, max float32
+	}{
+		{"same", newTestGenome(), 0, 0},
+		{"twice the filters", filters, 0.01, 0.1},
+		{"an extra block", deeper, 0.1, 0.2},
+		{"other blocks", newTestOtherGenome(), 0.3, 1},
+	}
+	for _, tt := range tests {
+		got := structuralDistance(newTestGenome(), tt.other)
+		if got < tt.wantMin || got > tt.max {
+			t.Errorf("structuralDistance() to %s = %v, want within [%v, %v]", tt.name, got, tt.wantMin, tt.max)
+		}
+		if back := structuralDistance(tt.other, newTestGenome()); back != got {
+			t.Errorf("structuralDistance() from %s = %v, want %v both ways", tt.name, back, got)
+		}
+	}
+}
+
+// newTestNichedSpecies has three trained individuals of one structure and one of another, ranked by fitness
+func newTestNichedSpecies(t *testing.T, advCfg AdvancedConfig) *Species {
+	species := &Species{targetNumIndividuals: 4}
+	for i, genome := range []Genome{newTestGenome(), newTestOtherGenome(), newTestGenome(), newTestGenome()} {
+		individual, err := compileGenome(advCfg, genome)
+		if err != nil {
+			t.Fatalf("compileGenome() error = %v", err)
+		}
+		individual.trained, individual.fitness = true, 0.8-float32(i)/10
+		species.individuals = append(species.individuals, individual)
+	}
+	return species
+}
+
+func TestSpecies_speciate(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Speciation = true
+	species := newTestNichedSpecies(t, advCfg)
+	species.speciate(advCfg)
+	if len(species.niches) != 2 {
+		t.Fatalf("speciate() made %d niches, want 2", len(species.niches))
+	}
+	if got := []int{len(species.niches[0].members), len(species.niches[1].members)}; !reflect.DeepEqual(got, []int{3, 1}) {
+		t.Errorf("speciate() niche sizes = %v, want [3 1]", got)
+	}
+
+	// the same generation ranked again doesn't stagnate, the next one without improvement does
+	species.speciate(advCfg)
+	if species.niches[0].stagnation != 0 {
+		t.Errorf("speciate() of the same generation stagnation = %d, want 0", species.niches[0].stagnation)
+	}
+	species.generation++
+	species.speciate(advCfg)
+	if species.niches[0].stagnation != 1 || species.niches[0].id != 1 {
+		t.Errorf("speciate() of the next generation niche %d stagnation = %d, want niche 1 with 1",
+			species.niches[0].id, species.niches[0].stagnation)
+	}
+
+	species.individuals = species.individuals[:1]
+	species.speciate(advCfg)
+	if len(species.niches) != 1 {
+		t.Errorf("speciate() kept %d niches after a niche has died out, want 1", len(species.niches))
+	}
+}
+
+func TestSpecies_sharePairs(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.Speciation = true
+	species := newTestNichedSpecies(t, advCfg)
+	species.speciate(advCfg)
+	// average fitness of the niches is 0.63 and 0.7, so the niche of one individual gets more pairs than of three
+	if got := species.sharePairs(advCfg, 5); !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Errorf("sharePairs() = %v, want [2 3]", got)
+	}
+
+	advCfg.StagnationLimit = 2
+	species.niches[1].stagnation = 2
+	if got := species.sharePairs(advCfg, 5); !reflect.DeepEqual(got, []int{5, 0}) {
+		t.Errorf("sharePairs() with a stagnant niche = %v, want [5 0]", got)
+	}
+	// the niche of the best individual always breeds
+	species.niches[0].stagnation = 2
+	if got := species.sharePairs(advCfg, 5); got[0] != 5 {
+		t.Errorf("sharePairs() with all niches stagnant = %v, want all pairs for the best one", got)
+	}
+
+	pairs := species.selectParentPairs(newTestRNG(), advCfg, TournamentSelector{Size: 2}, 5)
+	if len(pairs) != 5 {
+		t.Fatalf("selectParentPairs() returned %d pairs, want 5", len(pairs))
+	}
+	for _, pair := range pairs {
+		for _, parent := range pair {
+			if parent == species.individuals[1] {
+				t.Errorf("selectParentPairs() picked a member of a stagnant niche")
+			}
+		}
+	}
+}
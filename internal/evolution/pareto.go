@@ -0,0 +1,265 @@
+package evolution
+
+import (
+	"fmt"
+	"github.com/m8u/goro/pkg/v1/layer"
+	"golang.org/x/exp/rand"
+	"math"
+	"sort"
+	"sotsuron/internal/nn"
+	"sotsuron/internal/utils"
+	"strings"
+)
+
+const (
+	ObjectiveAccuracy = "accuracy"
+	ObjectiveParams   = "params"
+	ObjectiveFLOPs    = "flops"
+	ObjectiveLatency  = "latency"
+)
+
+var allObjectives = []string{ObjectiveAccuracy, ObjectiveParams, ObjectiveFLOPs, ObjectiveLatency}
+
+// Objectives are measures of an individual that multi-objective selection balances against each other.
+// Accuracy is maximized, the rest are minimized
+type Objectives struct {
+	Accuracy  float32
+	NumParams int
+	FLOPs     int
+	Latency   float64 // seconds it takes to evaluate one test example
+}
+
+// CalculateModelCost estimates the number of learnable parameters and floating point operations
+// needed for a single forward pass through layers
+func CalculateModelCost(layers []layer.Config, inputRes utils.Resolution, channels int) (numParams, flops int) {
+	res := inputRes
+	sourceChannels := make(map[int]int)
+	for _, l := range layers {
+		resAfter := res.After(l)
+		switch l := l.(type) {
+		case layer.Conv2D:
+			numParams += l.Output * l.Input * l.Height * l.Width
+			flops += 2 * resAfter.Width * resAfter.Height * l.Output * l.Input * l.Height * l.Width
+			channels = l.Output
+		case layer.MaxPooling2D:
+			flops += resAfter.Width * resAfter.Height * channels * l.Kernel[0] * l.Kernel[1]
+		case nn.AvgPooling2D:
+			flops += resAfter.Width * resAfter.Height * channels * l.Kernel[0] * l.Kernel[1]
+		case nn.BatchNorm:
+			flops += 2 * res.Width * res.Height * channels
+		case nn.SkipSource:
+			sourceChannels[l.ID] = channels
+		case nn.SkipMerge:
+			if l.Mode == nn.MergeConcat {
+				channels += sourceChannels[l.From]
+			} else {
+				flops += res.Width * res.Height * channels
+			}
+		case layer.FC:
+			numParams += l.Input*l.Output + l.Output
+			flops += 2 * l.Input * l.Output
+		}
+		res = resAfter
+	}
+	return
+}
+
+type UnknownObjectiveError struct {
+	objective string
+}
+
+func (err UnknownObjectiveError) Error() string {
+	return fmt.Sprintf("unknown objective %q", err.objective)
+}
+
+// parseObjectives parses a comma separated list of objectives, an empty list means all of them
+func parseObjectives(s string) (objectives []string, err error) {
+	if strings.TrimSpace(s) == "" {
+		return allObjectives, nil
+	}
+	for _, objective := range strings.Split(s, ",") {
+		objective = strings.TrimSpace(objective)
+		switch objective {
+		case ObjectiveAccuracy, ObjectiveParams, ObjectiveFLOPs, ObjectiveLatency:
+			objectives = append(objectives, objective)
+		default:
+			return nil, UnknownObjectiveError{objective}
+		}
+	}
+	return
+}
+
+// costs returns objectives as values to minimize
+func (o Objectives) costs(objectives []string) []float64 {
+	costs := make([]float64, len(objectives))
+	for i, objective := range objectives {
+		switch objective {
+		case ObjectiveAccuracy:
+			costs[i] = -float64(o.Accuracy)
+		case ObjectiveParams:
+			costs[i] = float64(o.NumParams)
+		case ObjectiveFLOPs:
+			costs[i] = float64(o.FLOPs)
+		case ObjectiveLatency:
+			costs[i] = o.Latency
+		}
+	}
+	return costs
+}
+
+func dominates(a, b []float64) bool {
+	strictlyBetter := false
+	for i := range a {
+		if a[i] > b[i] {
+			return false
+		}
+		if a[i] < b[i] {
+			strictlyBetter = true
+		}
+	}
+	return strictlyBetter
+}
+
+// nonDominatedSort splits individuals into Pareto fronts, the first front isn't dominated by anyone
+func nonDominatedSort(individuals []*Individual, objectives []string) (fronts [][]*Individual) {
+	costs := make([][]float64, len(individuals))
+	for i, individual := range individuals {
+		costs[i] = individual.objectives.costs(objectives)
+	}
+	dominatedBy := make([]int, len(individuals))
+	dominating := make([][]int, len(individuals))
+	var current []int
+	for i := range individuals {
+		for j := range individuals {
+			if dominates(costs[i], costs[j]) {
+				dominating[i] = append(dominating[i], j)
+			} else if dominates(costs[j], costs[i]) {
+				dominatedBy[i]++
+			}
+		}
+		if dominatedBy[i] == 0 {
+			current = append(current, i)
+		}
+	}
+	for len(current) > 0 {
+		var front []*Individual
+		var next []int
+		for _, i := range current {
+			front = append(front, individuals[i])
+			for _, j := range dominating[i] {
+				dominatedBy[j]--
+				if dominatedBy[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		fronts = append(fronts, front)
+		current = next
+	}
+	return
+}
+
+// crowdingDistances measures how far each individual of a front is from its neighbours,
+// boundary individuals get an infinite distance so that they are always preferred
+func crowdingDistances(front []*Individual, objectives []string) map[*Individual]float64 {
+	distances := make(map[*Individual]float64, len(front))
+	for _, individual := range front {
+		distances[individual] = 0
+	}
+	sorted := make([]*Individual, len(front))
+	copy(sorted, front)
+	for k := range objectives {
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].objectives.costs(objectives)[k] < sorted[j].objectives.costs(objectives)[k]
+		})
+		first, last := sorted[0].objectives.costs(objectives)[k], sorted[len(sorted)-1].objectives.costs(objectives)[k]
+		distances[sorted[0]] = math.Inf(1)
+		distances[sorted[len(sorted)-1]] = math.Inf(1)
+		if last == first {
+			continue
+		}
+		for i := 1; i < len(sorted)-1; i++ {
+			distances[sorted[i]] += (sorted[i+1].objectives.costs(objectives)[k] - sorted[i-1].objectives.costs(objectives)[k]) / (last - first)
+		}
+	}
+	return distances
+}
+
+// sortByDominance orders individuals by their Pareto front and then by crowding distance (NSGA-II order)
+func sortByDominance(individuals []*Individual, objectives []string) (sorted []*Individual) {
+	for _, front := range nonDominatedSort(individuals, objectives) {
+		distances := crowdingDistances(front, objectives)
+		sort.SliceStable(front, func(i, j int) bool {
+			return distances[front[i]] > distances[front[j]]
+		})
+		sorted = append(sorted, front...)
+	}
+	return
+}
+
+// NSGA2Selector is a binary tournament that prefers individuals from better Pareto fronts,
+// and less crowded ones within the same front
+type NSGA2Selector struct {
+	Objectives []string
+}
+
+func (selector NSGA2Selector) Select(rng *rand.Rand, individuals []*Individual, n int) (selected []*Individual) {
+	rank := make(map[*Individual]int, len(individuals))
+	distance := make(map[*Individual]float64, len(individuals))
+	for i, front := range nonDominatedSort(individuals, selector.Objectives) {
+		for individual, d := range crowdingDistances(front, selector.Objectives) {
+			rank[individual] = i
+			distance[individual] = d
+		}
+	}
+	for i := 0; i < n; i++ {
+		a, b := individuals[rng.Intn(len(individuals))], individuals[rng.Intn(len(individuals))]
+		if rank[b] < rank[a] || rank[b] == rank[a] && distance[b] > distance[a] {
+			a = b
+		}
+		selected = append(selected, a)
+	}
+	return
+}
+
+// ParetoFront returns trained individuals that are not dominated by any other one
+// with respect to advCfg.ParetoObjectives
+func (species *Species) ParetoFront(advCfg AdvancedConfig) ([]*Individual, error) {
+	objectives, err := parseObjectives(advCfg.ParetoObjectives)
+	if err != nil {
+		return nil, err
+	}
+	var trained []*Individual
+	for _, individual := range species.population() {
+		if individual.trained {
+			trained = append(trained, individual)
+		}
+	}
+	if len(trained) == 0 {
+		return nil, nil
+	}
+	return nonDominatedSort(trained, objectives)[0], nil
+}
+
+// ParetoPoint describes a member of the Pareto front for the UI
+type ParetoPoint struct {
+	Name       string
+	Fitness    float32
+	Objectives Objectives
+	Layers     []simpleLayerConfig
+	// in the DSL accepted by ParseArchitecture
+	Architecture string
+}
+
+func DescribeParetoFront(front []*Individual) (points []ParetoPoint) {
+	for _, individual := range front {
+		points = append(points, ParetoPoint{
+			Name:         individual.name,
+			Fitness:      individual.fitness,
+			Objectives:   individual.objectives,
+			Layers:       SimplifyLayers(individual.Chain.Layers),
+			Architecture: individual.genome.String(),
+		})
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package evolution
+
+import (
+	"github.com/m8u/goro/pkg/v1/layer"
+	"golang.org/x/exp/rand"
+	"math"
+	"sotsuron/internal/utils"
+	"testing"
+)
+
+func newTestParetoPopulation(objectives ...Objectives) (individuals []*Individual) {
+	individuals = newTestPopulation(make([]float32, len(objectives))...)
+	for i := range individuals {
+		individuals[i].objectives = objectives[i]
+		individuals[i].fitness = objectives[i].Accuracy
+	}
+	return
+}
+
+func TestCalculateModelCost(t *testing.T) {
+	layers := []layer.Config{
+		layer.Conv2D{Input: 1, Output: 4, Height: 3, Width: 3, Pad: []int{1, 1}, Stride: []int{1, 1}},
+		layer.MaxPooling2D{Kernel: []int{2, 2}, Pad: []int{0, 0}, Stride: []int{2, 2}},
+		layer.Flatten{},
+		layer.FC{Input: 4 * 4 * 4, Output: 10},
+	}
+	numParams, flops := CalculateModelCost(layers, utils.Resolution{Width: 8, Height: 8}, 1)
+	if wantParams := 4*1*3*3 + 64*10 + 10; numParams != wantParams {
+		t.Errorf("CalculateModelCost() numParams = %v, want %v", numParams, wantParams)
+	}
+	if wantFLOPs := 2*8*8*4*1*3*3 + 4*4*4*2*2 + 2*64*10; flops != wantFLOPs {
+		t.Errorf("CalculateModelCost() flops = %v, want %v", flops, wantFLOPs)
+	}
+}
+
+func TestNonDominatedSort(t *testing.T) {
+	individuals := newTestParetoPopulation(
+		Objectives{Accuracy: 0.9, NumParams: 1000}, // front 0
+		Objectives{Accuracy: 0.5, NumParams: 10},   // front 0
+		Objectives{Accuracy: 0.8, NumParams: 2000}, // dominated by 0
+		Objectives{Accuracy: 0.4, NumParams: 3000}, // dominated by 0, 1 and 2
+		Objectives{Accuracy: 0.7, NumParams: 100},  // front 0
+	)
+	fronts := nonDominatedSort(individuals, []string{ObjectiveAccuracy, ObjectiveParams})
+	want := [][]string{{"0", "1", "4"}, {"2"}, {"3"}}
+	if len(fronts) != len(want) {
+		t.Fatalf("nonDominatedSort() returned %v fronts, want %v", len(fronts), len(want))
+	}
+	for i := range want {
+		if len(fronts[i]) != len(want[i]) {
+			t.Fatalf("nonDominatedSort() front %v has %v individuals, want %v", i, len(fronts[i]), len(want[i]))
+		}
+		for j, name := range want[i] {
+			if fronts[i][j].name != name {
+				t.Errorf("nonDominatedSort() front %v = %v, want %v", i, fronts[i][j].name, name)
+			}
+		}
+	}
+}
+
+func TestCrowdingDistances(t *testing.T) {
+	front := newTestParetoPopulation(
+		Objectives{Accuracy: 0.9, NumParams: 1000},
+		Objectives{Accuracy: 0.8, NumParams: 900},
+		Objectives{Accuracy: 0.5, NumParams: 100},
+		Objectives{Accuracy: 0.3, NumParams: 50},
+	)
+	distances := crowdingDistances(front, []string{ObjectiveAccuracy, ObjectiveParams})
+	if !math.IsInf(distances[front[0]], 1) || !math.IsInf(distances[front[3]], 1) {
+		t.Errorf("crowdingDistances() of boundary individuals = %v, %v, want +Inf", distances[front[0]], distances[front[3]])
+	}
+	if distances[front[2]] <= distances[front[1]] {
+		t.Errorf("crowdingDistances() = %v, %v, isolated individual must be less crowded", distances[front[1]], distances[front[2]])
+	}
+}
+
+func TestNSGA2Selector_Select(t *testing.T) {
+	individuals := newTestParetoPopulation(
+		Objectives{Accuracy: 0.9, NumParams: 100},
+		Objectives{Accuracy: 0.1, NumParams: 1000},
+	)
+	selector := NSGA2Selector{Objectives: []string{ObjectiveAccuracy, ObjectiveParams}}
+	counts := make(map[string]int)
+	for _, individual := range selector.Select(rand.New(rand.NewSource(1)), individuals, 1000) {
+		counts[individual.name]++
+	}
+	// the dominated individual wins only when it is drawn twice
+	if counts["1"] > 350 {
+		t.Errorf("Select() selected the dominated individual %v times out of 1000", counts["1"])
+	}
+}
+
+func TestSpecies_ParetoFront(t *testing.T) {
+	species := &Species{individuals: newTestParetoPopulation(
+		Objectives{Accuracy: 0.9, NumParams: 1000},
+		Objectives{Accuracy: 0.5, NumParams: 10},
+		Objectives{Accuracy: 0.8, NumParams: 2000},
+	)}
+	advCfg := DefaultAdvancedConfig()
+	advCfg.ParetoObjectives = "accuracy, params"
+	front, err := species.ParetoFront(advCfg)
+	if err != nil {
+		t.Fatalf("ParetoFront() error = %v", err)
+	}
+	if len(front) != 2 || front[0].name != "0" || front[1].name != "1" {
+		t.Errorf("ParetoFront() = %v, want individuals 0 and 1", front)
+	}
+
+	advCfg.ParetoObjectives = "accuracy,beauty"
+	if _, err = species.ParetoFront(advCfg); err == nil {
+		t.Errorf("ParetoFront() with unknown objective must fail")
+	}
+}
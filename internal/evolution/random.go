@@ -2,13 +2,13 @@ package evolution
 
 import (
 	"fmt"
-	"github.com/m8u/gorgonia"
 	"github.com/m8u/goro/pkg/v1/layer"
 	"golang.org/x/exp/rand"
 	"gorgonia.org/tensor"
 	"math"
 	"modernc.org/mathutil"
 	"sort"
+	"sotsuron/internal/nn"
 	"sotsuron/internal/utils"
 )
 
@@ -34,7 +34,7 @@ func (err NoValidConfigFound) Error() string {
 }
 
 // getValidRandomConfig returns a random valid Conv2D or MaxPooling2D config, or an error if none are found.
-func getValidRandomConfig(advCfg AdvancedConfig, inputRes, minOutputRes utils.Resolution) (width, height, pad, stride int, err error) {
+func getValidRandomConfig(rng *rand.Rand, advCfg AdvancedConfig, inputRes, minOutputRes utils.Resolution) (width, height, pad, stride int, err error) {
 	var validConfigs [][]int
 	for width = 2; width <= minOutputRes.Width; width++ {
 		for height = 2; height <= minOutputRes.Height; height++ {
@@ -57,53 +57,53 @@ func getValidRandomConfig(advCfg AdvancedConfig, inputRes, minOutputRes utils.Re
 		sort.Slice(validConfigs, func(i, j int) bool {
 			return validConfigs[i][0]*validConfigs[i][1] < validConfigs[j][0]*validConfigs[j][1]
 		})
-		config := validConfigs[mathutil.Clamp(int(math.Abs(gorgonia.Gaussian64(0, float64(len(validConfigs)-1))[0])), 0, len(validConfigs)-1)]
+		config := validConfigs[mathutil.Clamp(int(math.Abs(rng.NormFloat64()*float64(len(validConfigs)-1))), 0, len(validConfigs)-1)]
 		return config[0], config[1], config[2], config[3], nil
 	}
 	return 0, 0, 0, 0, NoValidConfigFound{inputRes, minOutputRes}
 }
 
-func GenerateRandomConv2D(advCfg AdvancedConfig, prevOutput int, imageRes utils.Resolution, layers ...layer.Config) (layer.Conv2D, error) {
+func GenerateRandomConv2D(rng *rand.Rand, advCfg AdvancedConfig, prevOutput int, imageRes utils.Resolution, layers ...layer.Config) (layer.Conv2D, error) {
 	if len(layers) > 0 {
 		minOutputRes := (&utils.Resolution{
 			Width:  advCfg.MinResolutionWidth,
 			Height: advCfg.MinResolutionHeight,
 		}).CalculateMinRequiredBefore(layers)
-		width, height, pad, stride, err := getValidRandomConfig(advCfg, imageRes, minOutputRes)
+		width, height, pad, stride, err := getValidRandomConfig(rng, advCfg, imageRes, minOutputRes)
 		if err != nil {
 			return layer.Conv2D{}, err
 		}
 		return layer.Conv2D{
 			Input:      prevOutput,
-			Output:     1 + rand.Intn(advCfg.MaxConvOutput),
+			Output:     1 + rng.Intn(advCfg.MaxConvOutput),
 			Height:     height,
 			Width:      width,
-			Activation: activationFns[rand.Intn(len(activationFns))].Clone(),
+			Activation: activationFns[rng.Intn(len(activationFns))].Clone(),
 			Pad:        SquareShapeSlice(pad),
 			Stride:     SquareShapeSlice(stride),
 		}, nil
 	}
 	return layer.Conv2D{
 		Input:      prevOutput,
-		Output:     1 + rand.Intn(advCfg.MaxConvOutput),
-		Height:     2 + rand.Intn(tensor.MinInt(advCfg.MaxConvKernelSize-1, imageRes.Height-2)),
-		Width:      2 + rand.Intn(tensor.MinInt(advCfg.MaxConvKernelSize-1, imageRes.Width-2)),
-		Activation: activationFns[rand.Intn(len(activationFns))].Clone(),
-		Pad:        SquareShapeSlice(rand.Intn(advCfg.MaxConvPad + 1)),
-		Stride: SquareShapeSlice(1 + rand.Intn(tensor.MinInt(
+		Output:     1 + rng.Intn(advCfg.MaxConvOutput),
+		Height:     2 + rng.Intn(tensor.MinInt(advCfg.MaxConvKernelSize-1, imageRes.Height-2)),
+		Width:      2 + rng.Intn(tensor.MinInt(advCfg.MaxConvKernelSize-1, imageRes.Width-2)),
+		Activation: activationFns[rng.Intn(len(activationFns))].Clone(),
+		Pad:        SquareShapeSlice(rng.Intn(advCfg.MaxConvPad + 1)),
+		Stride: SquareShapeSlice(1 + rng.Intn(tensor.MinInt(
 			advCfg.MaxConvStride,
 			tensor.MinInt(imageRes.Width, imageRes.Height)-1,
 		))),
 	}, nil
 }
 
-func GenerateRandomMaxPooling2D(advCfg AdvancedConfig, imageRes utils.Resolution, layers ...layer.Config) (layer.MaxPooling2D, error) {
+func GenerateRandomMaxPooling2D(rng *rand.Rand, advCfg AdvancedConfig, imageRes utils.Resolution, layers ...layer.Config) (layer.MaxPooling2D, error) {
 	if len(layers) > 0 {
 		minOutputRes := (&utils.Resolution{
 			Width:  advCfg.MinResolutionWidth,
 			Height: advCfg.MinResolutionHeight,
 		}).CalculateMinRequiredBefore(layers)
-		width, height, pad, stride, err := getValidRandomConfig(advCfg, imageRes, minOutputRes)
+		width, height, pad, stride, err := getValidRandomConfig(rng, advCfg, imageRes, minOutputRes)
 		if err != nil {
 			return layer.MaxPooling2D{}, err
 		}
@@ -119,28 +119,52 @@ func GenerateRandomMaxPooling2D(advCfg AdvancedConfig, imageRes utils.Resolution
 
 	return layer.MaxPooling2D{
 		Kernel: []int{
-			2 + rand.Intn(int(math.Min(float64(advCfg.MaxPoolKernelSize-1), float64(imageRes.Height-2)))), // todo use int min
-			2 + rand.Intn(int(math.Min(float64(advCfg.MaxPoolKernelSize-1), float64(imageRes.Width-2)))),  // see gorgonia's nn.go:255 todo use int min
+			2 + rng.Intn(int(math.Min(float64(advCfg.MaxPoolKernelSize-1), float64(imageRes.Height-2)))), // todo use int min
+			2 + rng.Intn(int(math.Min(float64(advCfg.MaxPoolKernelSize-1), float64(imageRes.Width-2)))),  // see gorgonia's nn.go:255 todo use int min
 		},
-		Pad: SquareShapeSlice(rand.Intn(advCfg.MaxPoolPad + 1)),
-		Stride: SquareShapeSlice(1 + rand.Intn(int(math.Min(
+		Pad: SquareShapeSlice(rng.Intn(advCfg.MaxPoolPad + 1)),
+		Stride: SquareShapeSlice(1 + rng.Intn(int(math.Min(
 			float64(advCfg.MaxPoolStride), // todo use int min
 			math.Min(float64(imageRes.Width), float64(imageRes.Height))-1,
 		)))),
 	}, nil
 }
 
-func generateRandomFC(advCfg AdvancedConfig, prevOutput int) layer.FC {
+// GenerateRandomPooling2D returns a MaxPooling2D or, with advCfg.AvgPoolingChance, an AvgPooling2D of the same geometry
+func GenerateRandomPooling2D(rng *rand.Rand, advCfg AdvancedConfig, imageRes utils.Resolution, layers ...layer.Config) (layer.Config, error) {
+	maxPooling2D, err := GenerateRandomMaxPooling2D(rng, advCfg, imageRes, layers...)
+	if err != nil {
+		return nil, err
+	}
+	if rng.Float32() < advCfg.AvgPoolingChance {
+		return nn.AvgPooling2D{
+			Kernel: maxPooling2D.Kernel,
+			Pad:    maxPooling2D.Pad,
+			Stride: maxPooling2D.Stride,
+		}, nil
+	}
+	return maxPooling2D, nil
+}
+
+// generateRandomDropout returns a dropout probability rounded to hundredths with advCfg.DropoutChance, 0 (no Dropout) otherwise
+func generateRandomDropout(rng *rand.Rand, advCfg AdvancedConfig) float64 {
+	if rng.Float32() >= advCfg.DropoutChance || advCfg.MaxDropout <= 0 {
+		return 0
+	}
+	return math.Round((advCfg.MinDropout+rng.Float64()*(advCfg.MaxDropout-advCfg.MinDropout))*100) / 100
+}
+
+func generateRandomFC(rng *rand.Rand, advCfg AdvancedConfig, prevOutput int) layer.FC {
 	return layer.FC{
 		Input:      prevOutput,
-		Output:     1 + rand.Intn(advCfg.MaxDenseSize),
-		Activation: activationFns[rand.Intn(len(activationFns))].Clone(),
+		Output:     1 + rng.Intn(advCfg.MaxDenseSize),
+		Activation: activationFns[rng.Intn(len(activationFns))].Clone(),
 	}
 }
 
-func GenerateRandomStructure(advCfg AdvancedConfig, inputWidth, inputHeight, numClasses int, grayscale bool) (layers []layer.Config) {
+func GenerateRandomStructure(rng *rand.Rand, advCfg AdvancedConfig, inputWidth, inputHeight, numClasses int, grayscale bool) (layers []layer.Config) {
 	// append some Conv2D-MaxPooling2D pairs with random parameters
-	numConvMaxPoolingPairs := 1 + rand.Intn(advCfg.MaxConvMaxPoolingPairs)
+	numConvMaxPoolingPairs := 1 + rng.Intn(advCfg.MaxConvMaxPoolingPairs)
 	var prevOutput int
 	if grayscale {
 		prevOutput = 1
@@ -150,21 +174,27 @@ func GenerateRandomStructure(advCfg AdvancedConfig, inputWidth, inputHeight, num
 	res := utils.Resolution{Width: inputWidth, Height: inputHeight}
 	var newRes utils.Resolution
 	for i := 0; i < numConvMaxPoolingPairs; i++ {
-		conv2D, _ := GenerateRandomConv2D(advCfg, prevOutput, res)
+		conv2D, _ := GenerateRandomConv2D(rng, advCfg, prevOutput, res)
 		if newRes = res.After(conv2D); !newRes.Validate(advCfg.MinResolutionWidth, advCfg.MinResolutionHeight) {
 			break
 		}
 		fmt.Println(conv2D)
 		layers = append(layers, conv2D)
+		if rng.Float32() < advCfg.BatchNormChance {
+			layers = append(layers, nn.BatchNorm{})
+		}
+		if dropout := generateRandomDropout(rng, advCfg); dropout > 0 {
+			layers = append(layers, nn.Dropout{Probability: dropout})
+		}
 		prevOutput = conv2D.Output
 		res = newRes
 
-		maxPooling2D, _ := GenerateRandomMaxPooling2D(advCfg, newRes)
-		if newRes = newRes.After(maxPooling2D); !newRes.Validate(advCfg.MinResolutionWidth, advCfg.MinResolutionHeight) {
+		pooling2D, _ := GenerateRandomPooling2D(rng, advCfg, newRes)
+		if newRes = newRes.After(pooling2D); !newRes.Validate(advCfg.MinResolutionWidth, advCfg.MinResolutionHeight) {
 			break
 		}
-		fmt.Println(maxPooling2D)
-		layers = append(layers, maxPooling2D)
+		fmt.Println(pooling2D)
+		layers = append(layers, pooling2D)
 		res = newRes
 	}
 
@@ -172,12 +202,15 @@ func GenerateRandomStructure(advCfg AdvancedConfig, inputWidth, inputHeight, num
 	layers = append(layers, layer.Flatten{})
 
 	// append some dense layers
-	numDenseLayers := 1 + rand.Intn(advCfg.MaxDenseLayers)
+	numDenseLayers := 1 + rng.Intn(advCfg.MaxDenseLayers)
 	prevOutput = prevOutput * res.Width * res.Height
 	for i := 0; i < numDenseLayers; i++ {
-		fc := generateRandomFC(advCfg, prevOutput)
+		fc := generateRandomFC(rng, advCfg, prevOutput)
 		fmt.Printf("%v -> %v\n", prevOutput, fc.Output)
 		layers = append(layers, fc)
+		if dropout := generateRandomDropout(rng, advCfg); dropout > 0 {
+			layers = append(layers, nn.Dropout{Probability: dropout})
+		}
 		prevOutput = fc.Output
 	}
 	fmt.Printf("%v -> %v\n", prevOutput, numClasses)
@@ -187,7 +220,7 @@ func GenerateRandomStructure(advCfg AdvancedConfig, inputWidth, inputHeight, num
 		layer.FC{
 			Input:      prevOutput,
 			Output:     numClasses,
-			Activation: activationFns[rand.Intn(len(activationFns))].Clone(),
+			Activation: activationFns[rng.Intn(len(activationFns))].Clone(),
 		},
 	)
 
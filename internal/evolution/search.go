@@ -0,0 +1,288 @@
+package evolution
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/exp/rand"
+	"gorgonia.org/tensor"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InvalidGridError means that a grid of depths or widths isn't a list of positive numbers
+type InvalidGridError struct {
+	grid string
+}
+
+func (err InvalidGridError) Error() string {
+	return fmt.Sprintf("invalid grid %q, want comma separated positive numbers", err.grid)
+}
+
+// parseGrid parses comma separated positive numbers
+func parseGrid(s string) (values []int, err error) {
+	for _, value := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 1 {
+			return nil, InvalidGridError{s}
+		}
+		values = append(values, n)
+	}
+	return
+}
+
+// gridPoint is a number of Conv2D layers and the number of filters of each of them
+type gridPoint struct {
+	depth, width int
+}
+
+// gridPoints returns all combinations of advCfg.GridDepths and advCfg.GridWidths, the shallowest ones first
+func gridPoints(advCfg AdvancedConfig) (points []gridPoint, err error) {
+	depths, err := parseGrid(advCfg.GridDepths)
+	if err != nil {
+		return nil, err
+	}
+	widths, err := parseGrid(advCfg.GridWidths)
+	if err != nil {
+		return nil, err
+	}
+	for _, depth := range depths {
+		for _, width := range widths {
+			points = append(points, gridPoint{depth, width})
+		}
+	}
+	return
+}
+
+// maxGridAttempts is how many random structures are generated for a grid point before it's given up
+const maxGridAttempts = 100
+
+// NoGridArchitectureError means that no random structure of the depth of a grid point fits the input resolution
+type NoGridArchitectureError struct {
+	depth, width int
+}
+
+func (err NoGridArchitectureError) Error() string {
+	return fmt.Sprintf("could not generate a structure of %d Conv2D layers of %d filters in %d attempts",
+		err.depth, err.width, maxGridAttempts)
+}
+
+// newGridIndividual generates random structures of at most point.depth conv-pooling pairs until one has exactly
+// point.depth Conv2D layers, and gives all of them point.width filters. Other layers, skips and hyperparameters are
+// as random as of any other individual
+func (species *Species) newGridIndividual(rng *rand.Rand, advCfg AdvancedConfig, point gridPoint) (*Individual, error) {
+	pointCfg := advCfg
+	pointCfg.MaxConvMaxPoolingPairs = point.depth
+	channels := 3
+	if species.grayscale {
+		channels = 1
+	}
+	for attempt := 0; attempt < maxGridAttempts; attempt++ {
+		layers := GenerateRandomStructure(rng, pointCfg, species.inputRes.Width, species.inputRes.Height, species.numClasses, species.grayscale)
+		genome, err := EncodeGenome(layers, species.inputRes, channels)
+		if err != nil {
+			return nil, err
+		}
+		depth := 0
+		for _, block := range genome.ConvBlocks {
+			if block.Type == BlockConv2D {
+				depth++
+			}
+		}
+		if depth != point.depth {
+			continue
+		}
+		for i := range genome.ConvBlocks {
+			if genome.ConvBlocks[i].Type == BlockConv2D {
+				genome.ConvBlocks[i].Output = point.width
+			}
+		}
+		genome.addRandomSkips(rng, advCfg)
+		if advCfg.EvolveHyperparameters {
+			if genome.Hyperparameters, err = generateRandomHyperparameters(rng, advCfg); err != nil {
+				return nil, err
+			}
+		}
+		return compileGenome(advCfg, genome)
+	}
+	return nil, NoGridArchitectureError{point.depth, point.width}
+}
+
+// keepBest leaves the best targetNumIndividuals of individuals in the population and disposes the rest
+func (species *Species) keepBest(individuals []*Individual) {
+	ranked := sortedByFitness(individuals)
+	size := tensor.MinInt(species.targetNumIndividuals, len(ranked))
+	for _, individual := range ranked[size:] {
+		individual.DisposeVMs()
+	}
+	species.individuals = ranked[:size]
+}
+
+// evolveRandom is the random search baseline: every generation consists of new random individuals
+func (species *Species) evolveRandom(
+	ctx context.Context, advCfg AdvancedConfig, numGenerations int,
+	xTrain, yTrain, xTest, yTest tensor.Tensor,
+	progressChan chan Progress, allChartChan chan AllChartData, bestChartChan chan BestChartData, bestStructureChan chan BestStructure) error {
+
+	generate := func(rng *rand.Rand, n int) (*Individual, error) {
+		return NewIndividual(rng, advCfg, species.inputRes.Width, species.inputRes.Height, species.numClasses, species.grayscale)
+	}
+	return species.evolveSearch(ctx, advCfg, numGenerations, generate, xTrain, yTrain, xTest, yTest,
+		progressChan, allChartChan, bestChartChan, bestStructureChan)
+}
+
+// evolveGrid is the grid search baseline: individuals go through the grid points in turns, so that every point is
+// tried about the same number of times within the budget
+func (species *Species) evolveGrid(
+	ctx context.Context, advCfg AdvancedConfig, numGenerations int,
+	xTrain, yTrain, xTest, yTest tensor.Tensor,
+	progressChan chan Progress, allChartChan chan AllChartData, bestChartChan chan BestChartData, bestStructureChan chan BestStructure) error {
+
+	points, err := gridPoints(advCfg)
+	if err != nil {
+		fmt.Println("WARNING:", err.Error())
+		points, _ = gridPoints(DefaultAdvancedConfig())
+	}
+	generate := func(rng *rand.Rand, n int) (*Individual, error) {
+		return species.newGridIndividual(rng, advCfg, points[n%len(points)])
+	}
+
+	// the starting population is random, so its untrained individuals are replaced by the first grid points
+	n := species.generation * species.targetNumIndividuals
+	for i := 0; i < len(species.individuals); i++ {
+		if species.individuals[i].trained {
+			continue
+		}
+		species.individuals[i].DisposeVMs()
+		individual, err := generate(species.newRNG(), n)
+		n++
+		if err != nil {
+			fmt.Println("WARNING:", err.Error())
+			species.individuals = append(species.individuals[:i], species.individuals[i+1:]...)
+			i--
+			continue
+		}
+		species.individuals[i] = individual
+	}
+	return species.evolveSearch(ctx, advCfg, numGenerations, generate, xTrain, yTrain, xTest, yTest,
+		progressChan, allChartChan, bestChartChan, bestStructureChan)
+}
+
+// evolveSearch runs a baseline with the budget of evolution: every generation targetNumIndividuals individuals made
+// by generate, the n-th one of the search, are trained, and the best targetNumIndividuals of all individuals trained
+// so far make the population, so that results are reported the same way. Untrained individuals of the population,
+// e.g. the starting ones, are the first ones of the current generation
+func (species *Species) evolveSearch(
+	ctx context.Context, advCfg AdvancedConfig, numGenerations int, generate func(rng *rand.Rand, n int) (*Individual, error),
+	xTrain, yTrain, xTest, yTest tensor.Tensor,
+	progressChan chan Progress, allChartChan chan AllChartData, bestChartChan chan BestChartData, bestStructureChan chan BestStructure) error {
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var population, candidates []*Individual
+	for _, individual := range species.individuals {
+		if individual.trained {
+			population = append(population, individual)
+		} else {
+			candidates = append(candidates, individual)
+		}
+	}
+	// if all individuals are trained, the current generation has been searched before evolution was resumed
+	firstGeneration := species.generation
+	if len(candidates) == 0 {
+		firstGeneration++
+	}
+	progress := Progress{
+		Generation: firstGeneration,
+		Individual: firstGeneration * species.targetNumIndividuals,
+	}
+	finish := func() {
+		if progressChan != nil {
+			progress.Generation = -1
+			progressChan <- progress
+		}
+	}
+
+	start := time.Now()
+	for i := firstGeneration; i < numGenerations; i++ {
+		species.generation = i
+		progress.Generation = i
+		fmt.Printf("===================================== Generation %d =====================================\n", i)
+		for k := len(candidates); k < species.targetNumIndividuals; k++ {
+			individual, err := generate(species.newRNG(), i*species.targetNumIndividuals+k)
+			if err != nil {
+				fmt.Println("WARNING:", err.Error())
+				progress.Individual++
+				continue
+			}
+			candidates = append(candidates, individual)
+		}
+
+		queue := make(chan *Individual)
+		for w := 0; w < maxConcurrency(advCfg); w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for individual := range queue {
+					species.evaluate(ctx, advCfg, individual, allChartChan, xTrain, yTrain, xTest, yTest)
+					mu.Lock()
+					progress.Individual++
+					progress.CacheHitRate = species.cache.hitRate()
+					select {
+					case progressChan <- progress:
+					default:
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, individual := range candidates {
+			queue <- individual
+		}
+		close(queue)
+		wg.Wait()
+		if ctx.Err() != nil {
+			fmt.Println("ABORTING")
+			species.individuals = append(population, candidates...)
+			finish()
+			return nil
+		}
+
+		for _, individual := range candidates {
+			if individual.trained {
+				population = append(population, individual)
+			} else {
+				individual.DisposeVMs()
+			}
+		}
+		candidates = nil
+		species.keepBest(population)
+		population = species.individuals
+		if len(population) == 0 {
+			fmt.Println("WARNING: no individuals have survived so far")
+			continue
+		}
+
+		progress.ETASeconds = time.Since(start).Seconds() / float64(i-firstGeneration+1) * float64(numGenerations-1-i)
+		select {
+		case progressChan <- progress:
+		default:
+		}
+		best := species.Best()
+		select {
+		case bestChartChan <- BestChartData{Generation: i, Fitness: best.fitness}:
+		default:
+		}
+		select {
+		case bestStructureChan <- BestStructure{best.Chain.Layers, best.genome.Hyperparameters.Resolve(advCfg), nil}:
+		default:
+		}
+		species.maybeSaveCheckpoint(advCfg)
+	}
+	finish()
+	if len(species.individuals) == 0 {
+		return ExtinctionError{species.generation}
+	}
+	return nil
+}
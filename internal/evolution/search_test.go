@@ -0,0 +1,95 @@
+package evolution
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseGrid(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []int
+		wantErr bool
+	}{
+		{"1,2,3", []int{1, 2, 3}, false},
+		{" 8 , 16", []int{8, 16}, false},
+		{"4", []int{4}, false},
+		{"", nil, true},
+		{"1,,2", nil, true},
+		{"0,2", nil, true},
+		{"two", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGrid(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseGrid(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.As(err, &InvalidGridError{}) {
+			t.Errorf("parseGrid(%q) error = %v, want InvalidGridError", tt.s, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseGrid(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSpecies_newGridIndividual(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	advCfg.GridDepths, advCfg.GridWidths = "1,2", "3,5"
+	points, err := gridPoints(advCfg)
+	if err != nil {
+		t.Fatalf("gridPoints() error = %v", err)
+	}
+	if want := []gridPoint{{1, 3}, {1, 5}, {2, 3}, {2, 5}}; !reflect.DeepEqual(points, want) {
+		t.Fatalf("gridPoints() = %v, want %v", points, want)
+	}
+	species, err := NewSpecies(advCfg, 2, 28, 28, 4, true)
+	if err != nil {
+		t.Fatalf("NewSpecies() error = %v", err)
+	}
+	for _, point := range points {
+		individual, err := species.newGridIndividual(newTestRNG(), advCfg, point)
+		if err != nil {
+			t.Fatalf("newGridIndividual(%v) error = %v", point, err)
+		}
+		var widths []int
+		for _, block := range individual.Genome().ConvBlocks {
+			if block.Type == BlockConv2D {
+				widths = append(widths, block.Output)
+			}
+		}
+		if len(widths) != point.depth {
+			t.Errorf("newGridIndividual(%v) has %d Conv2D layers, want %d", point, len(widths), point.depth)
+		}
+		for _, width := range widths {
+			if width != point.width {
+				t.Errorf("newGridIndividual(%v) has a Conv2D layer of %d filters, want %d", point, width, point.width)
+			}
+		}
+	}
+}
+
+func TestSpecies_keepBest(t *testing.T) {
+	advCfg := DefaultAdvancedConfig()
+	species := &Species{targetNumIndividuals: 2}
+	var individuals []*Individual
+	for _, fitness := range []float32{0.2, 0.9, 0.5} {
+		individual, err := compileGenome(advCfg, newTestGenome())
+		if err != nil {
+			t.Fatalf("compileGenome() error = %v", err)
+		}
+		individual.trained, individual.fitness = true, fitness
+		individuals = append(individuals, individual)
+	}
+	species.keepBest(individuals)
+	if len(species.individuals) != 2 || species.individuals[0] != individuals[1] || species.individuals[1] != individuals[2] {
+		t.Errorf("keepBest() kept %d individuals, want the ones of fitness 0.9 and 0.5", len(species.individuals))
+	}
+
+	species.keepBest(individuals[:1])
+	if len(species.individuals) != 1 {
+		t.Errorf("keepBest() of fewer individuals than the target kept %d, want 1", len(species.individuals))
+	}
+}
@@ -0,0 +1,183 @@
+package evolution
+
+import (
+	"fmt"
+	"golang.org/x/exp/rand"
+	"math"
+	"sort"
+)
+
+const (
+	SelectionTournament = "tournament"
+	SelectionRoulette   = "roulette"
+	SelectionRank       = "rank"
+	SelectionTruncation = "truncation"
+	SelectionNSGA2      = "nsga2"
+)
+
+// Selector picks parents out of already evaluated individuals
+type Selector interface {
+	// Select returns n individuals, the same individual may be picked more than once
+	Select(rng *rand.Rand, individuals []*Individual, n int) []*Individual
+}
+
+type UnknownSelectionError struct {
+	selection string
+}
+
+func (err UnknownSelectionError) Error() string {
+	return fmt.Sprintf("unknown selection method %q", err.selection)
+}
+
+// NewSelector returns a selector configured by advCfg.Selection (tournament by default)
+func NewSelector(advCfg AdvancedConfig) (Selector, error) {
+	switch advCfg.Selection {
+	case "", SelectionTournament:
+		size := advCfg.TournamentSize
+		if size < 2 {
+			size = 2
+		}
+		return TournamentSelector{Size: size}, nil
+	case SelectionRoulette:
+		return RouletteSelector{}, nil
+	case SelectionRank:
+		return RankSelector{}, nil
+	case SelectionTruncation:
+		ratio := advCfg.TruncationRatio
+		if ratio <= 0 || ratio > 1 {
+			ratio = 0.5
+		}
+		return TruncationSelector{Ratio: ratio}, nil
+	case SelectionNSGA2:
+		objectives, err := parseObjectives(advCfg.ParetoObjectives)
+		if err != nil {
+			return nil, err
+		}
+		return NSGA2Selector{Objectives: objectives}, nil
+	default:
+		return nil, UnknownSelectionError{advCfg.Selection}
+	}
+}
+
+// SelectParentPairs picks numPairs pairs of parents, trying to avoid pairing an individual with itself
+func SelectParentPairs(rng *rand.Rand, selector Selector, individuals []*Individual, numPairs int) (pairs [][2]*Individual) {
+	for i := 0; i < numPairs; i++ {
+		parent1 := selector.Select(rng, individuals, 1)[0]
+		parent2 := parent1
+		for attempt := 0; attempt < 10 && parent2 == parent1 && len(individuals) > 1; attempt++ {
+			parent2 = selector.Select(rng, individuals, 1)[0]
+		}
+		if parent2 == parent1 {
+			// the selector insists on the same individual, so pair it with the best other one
+			for _, individual := range sortedByFitness(individuals) {
+				if individual != parent1 {
+					parent2 = individual
+					break
+				}
+			}
+		}
+		if parent2.fitness > parent1.fitness {
+			parent1, parent2 = parent2, parent1
+		}
+		pairs = append(pairs, [2]*Individual{parent1, parent2})
+	}
+	return
+}
+
+func sortedByFitness(individuals []*Individual) []*Individual {
+	sorted := make([]*Individual, len(individuals))
+	copy(sorted, individuals)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].fitness > sorted[j].fitness
+	})
+	return sorted
+}
+
+// TournamentSelector picks the fittest out of Size randomly sampled individuals
+type TournamentSelector struct {
+	Size int
+}
+
+func (selector TournamentSelector) Select(rng *rand.Rand, individuals []*Individual, n int) (selected []*Individual) {
+	for i := 0; i < n; i++ {
+		var winner *Individual
+		for j := 0; j < selector.Size; j++ {
+			contender := individuals[rng.Intn(len(individuals))]
+			if winner == nil || contender.fitness > winner.fitness {
+				winner = contender
+			}
+		}
+		selected = append(selected, winner)
+	}
+	return
+}
+
+// RouletteSelector picks individuals with probability proportional to their fitness
+// (shifted so that the least fit individual still has a small chance)
+type RouletteSelector struct{}
+
+func (selector RouletteSelector) Select(rng *rand.Rand, individuals []*Individual, n int) []*Individual {
+	minFitness := float32(math.Inf(1))
+	for _, individual := range individuals {
+		if individual.fitness < minFitness {
+			minFitness = individual.fitness
+		}
+	}
+	weights := make([]float64, len(individuals))
+	for i, individual := range individuals {
+		weights[i] = float64(individual.fitness-minFitness) + 1e-3
+	}
+	return spinWheel(rng, individuals, weights, n)
+}
+
+// RankSelector picks individuals with probability proportional to their rank (linear ranking),
+// so it doesn't depend on the scale of fitness values
+type RankSelector struct{}
+
+func (selector RankSelector) Select(rng *rand.Rand, individuals []*Individual, n int) []*Individual {
+	sorted := sortedByFitness(individuals)
+	weights := make([]float64, len(sorted))
+	for i := range sorted {
+		weights[i] = float64(len(sorted) - i)
+	}
+	return spinWheel(rng, sorted, weights, n)
+}
+
+// TruncationSelector picks individuals uniformly out of the best Ratio part of the population
+type TruncationSelector struct {
+	Ratio float32
+}
+
+func (selector TruncationSelector) Select(rng *rand.Rand, individuals []*Individual, n int) (selected []*Individual) {
+	sorted := sortedByFitness(individuals)
+	poolSize := int(math.Ceil(float64(selector.Ratio) * float64(len(sorted))))
+	if poolSize < 2 {
+		poolSize = 2
+	}
+	if poolSize > len(sorted) {
+		poolSize = len(sorted)
+	}
+	for i := 0; i < n; i++ {
+		selected = append(selected, sorted[rng.Intn(poolSize)])
+	}
+	return
+}
+
+func spinWheel(rng *rand.Rand, individuals []*Individual, weights []float64, n int) (selected []*Individual) {
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	for i := 0; i < n; i++ {
+		point := rng.Float64() * total
+		j := 0
+		for ; j < len(weights)-1; j++ {
+			point -= weights[j]
+			if point < 0 {
+				break
+			}
+		}
+		selected = append(selected, individuals[j])
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package evolution
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/exp/rand"
+	"testing"
+)
+
+func newTestPopulation(fitnesses ...float32) (individuals []*Individual) {
+	for i, fitness := range fitnesses {
+		individuals = append(individuals, &Individual{name: fmt.Sprint(i), fitness: fitness, trained: true})
+	}
+	return
+}
+
+func TestNewSelector(t *testing.T) {
+	tests := []struct {
+		name      string
+		selection string
+		want      Selector
+		wantErr   bool
+	}{
+		{name: "default", selection: "", want: TournamentSelector{Size: 3}},
+		{name: "tournament", selection: SelectionTournament, want: TournamentSelector{Size: 3}},
+		{name: "roulette", selection: SelectionRoulette, want: RouletteSelector{}},
+		{name: "rank", selection: SelectionRank, want: RankSelector{}},
+		{name: "truncation", selection: SelectionTruncation, want: TruncationSelector{Ratio: 0.5}},
+		{name: "unknown", selection: "lottery", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advCfg := DefaultAdvancedConfig()
+			advCfg.Selection = tt.selection
+			got, err := NewSelector(advCfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.As(err, &UnknownSelectionError{}) {
+					t.Errorf("NewSelector() error = %v, want UnknownSelectionError", err)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("NewSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectors_Select(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector Selector
+	}{
+		{name: "tournament", selector: TournamentSelector{Size: 3}},
+		{name: "roulette", selector: RouletteSelector{}},
+		{name: "rank", selector: RankSelector{}},
+		{name: "truncation", selector: TruncationSelector{Ratio: 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			individuals := newTestPopulation(0.9, -0.2, 0.5, 0.1, 0.3, 0.7)
+			counts := make(map[*Individual]int)
+			selected := tt.selector.Select(rand.New(rand.NewSource(1)), individuals, 3000)
+			if len(selected) != 3000 {
+				t.Fatalf("Select() returned %d individuals, want %d", len(selected), 3000)
+			}
+			for _, individual := range selected {
+				counts[individual]++
+			}
+			// the fittest individual must be picked more often than the least fit one
+			if counts[individuals[0]] <= counts[individuals[1]] {
+				t.Errorf("best picked %d times, worst picked %d times", counts[individuals[0]], counts[individuals[1]])
+			}
+		})
+	}
+}
+
+func TestSelectParentPairs(t *testing.T) {
+	individuals := newTestPopulation(0.9, 0.8, 0.1, 0.2)
+	// truncation to the 2 best individuals must always pair them together
+	pairs := SelectParentPairs(rand.New(rand.NewSource(1)), TruncationSelector{Ratio: 0.5}, individuals, 20)
+	if len(pairs) != 20 {
+		t.Fatalf("SelectParentPairs() returned %d pairs, want %d", len(pairs), 20)
+	}
+	for _, pair := range pairs {
+		if pair[0] != individuals[0] || pair[1] != individuals[1] {
+			t.Errorf("SelectParentPairs() = (%s, %s), want (0, 1)", pair[0].name, pair[1].name)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package evolution
+
+import (
+	"fmt"
+	g "github.com/m8u/gorgonia"
+	"github.com/m8u/goro/pkg/v1/layer"
+	"gorgonia.org/tensor"
+	"sotsuron/internal/nn"
+)
+
+// LayerSpec is a serializable form of a layer config
+type LayerSpec struct {
+	Type        string  // "Conv2D", "MaxPooling2D", "AvgPooling2D", "BatchNorm", "Dropout", "SkipSource", "SkipMerge", "Flatten" or "FC"
+	Input       int     `json:",omitempty"`
+	Output      int     `json:",omitempty"`
+	Height      int     `json:",omitempty"`
+	Width       int     `json:",omitempty"`
+	Activation  string  `json:",omitempty"`
+	Pad         []int   `json:",omitempty"`
+	Stride      []int   `json:",omitempty"`
+	Probability float64 `json:",omitempty"` // of Dropout
+	Skip        int     `json:",omitempty"` // ID of SkipSource or From of SkipMerge
+	Mode        string  `json:",omitempty"` // of SkipMerge
+}
+
+type UnknownLayerError struct {
+	layerType string
+}
+
+func (err UnknownLayerError) Error() string {
+	return fmt.Sprintf("unknown layer type %q", err.layerType)
+}
+
+func activationFnFromString(name string) (layer.ActivationFn, error) {
+	switch name {
+	case "Linear":
+		return layer.Linear.Clone(), nil
+	case "Sigmoid":
+		return layer.Sigmoid.Clone(), nil
+	case "Softmax":
+		return layer.Softmax.Clone(), nil
+	case "Tanh":
+		return layer.Tanh.Clone(), nil
+	case "ReLU":
+		return layer.ReLU.Clone(), nil
+	case "LeakyReLU":
+		return layer.LeakyReLU.Clone(), nil
+	default:
+		return nil, fmt.Errorf("unknown activation function %q", name)
+	}
+}
+
+// EncodeLayers converts layer configs to their serializable form
+func EncodeLayers(layers []layer.Config) (specs []LayerSpec, err error) {
+	for _, l := range layers {
+		switch l := l.(type) {
+		case layer.Conv2D:
+			specs = append(specs, LayerSpec{
+				Type:       "Conv2D",
+				Input:      l.Input,
+				Output:     l.Output,
+				Height:     l.Height,
+				Width:      l.Width,
+				Activation: activationFnToString(l.Activation),
+				Pad:        l.Pad,
+				Stride:     l.Stride,
+			})
+		case layer.MaxPooling2D:
+			specs = append(specs, LayerSpec{
+				Type:   "MaxPooling2D",
+				Height: l.Kernel[0],
+				Width:  l.Kernel[1],
+				Pad:    l.Pad,
+				Stride: l.Stride,
+			})
+		case nn.AvgPooling2D:
+			specs = append(specs, LayerSpec{
+				Type:   "AvgPooling2D",
+				Height: l.Kernel[0],
+				Width:  l.Kernel[1],
+				Pad:    l.Pad,
+				Stride: l.Stride,
+			})
+		case nn.BatchNorm:
+			specs = append(specs, LayerSpec{Type: "BatchNorm"})
+		case nn.Dropout:
+			specs = append(specs, LayerSpec{Type: "Dropout", Probability: l.Probability})
+		case nn.SkipSource:
+			specs = append(specs, LayerSpec{Type: "SkipSource", Skip: l.ID})
+		case nn.SkipMerge:
+			specs = append(specs, LayerSpec{Type: "SkipMerge", Skip: l.From, Mode: l.Mode})
+		case layer.Flatten:
+			specs = append(specs, LayerSpec{Type: "Flatten"})
+		case layer.FC:
+			specs = append(specs, LayerSpec{
+				Type:       "FC",
+				Input:      l.Input,
+				Output:     l.Output,
+				Activation: activationFnToString(l.Activation),
+			})
+		default:
+			return nil, UnknownLayerError{fmt.Sprintf("%T", l)}
+		}
+	}
+	return
+}
+
+// DecodeLayers converts serialized layers back to layer configs
+func DecodeLayers(specs []LayerSpec) (layers []layer.Config, err error) {
+	skips := nn.NewSkips()
+	for _, spec := range specs {
+		switch spec.Type {
+		case "Conv2D":
+			activation, err := activationFnFromString(spec.Activation)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, layer.Conv2D{
+				Input:      spec.Input,
+				Output:     spec.Output,
+				Height:     spec.Height,
+				Width:      spec.Width,
+				Activation: activation,
+				Pad:        spec.Pad,
+				Stride:     spec.Stride,
+			})
+		case "MaxPooling2D":
+			layers = append(layers, layer.MaxPooling2D{
+				Kernel: []int{spec.Height, spec.Width},
+				Pad:    spec.Pad,
+				Stride: spec.Stride,
+			})
+		case "AvgPooling2D":
+			layers = append(layers, nn.AvgPooling2D{
+				Kernel: []int{spec.Height, spec.Width},
+				Pad:    spec.Pad,
+				Stride: spec.Stride,
+			})
+		case "BatchNorm":
+			layers = append(layers, nn.BatchNorm{})
+		case "Dropout":
+			layers = append(layers, nn.Dropout{Probability: spec.Probability})
+		case "SkipSource":
+			layers = append(layers, nn.SkipSource{ID: spec.Skip, Skips: skips})
+		case "SkipMerge":
+			layers = append(layers, nn.SkipMerge{From: spec.Skip, Mode: spec.Mode, Skips: skips})
+		case "Flatten":
+			layers = append(layers, layer.Flatten{})
+		case "FC":
+			activation, err := activationFnFromString(spec.Activation)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, layer.FC{
+				Input:      spec.Input,
+				Output:     spec.Output,
+				Activation: activation,
+			})
+		default:
+			return nil, UnknownLayerError{spec.Type}
+		}
+	}
+	return
+}
+
+// tensorSpec is a serializable form of a learnable's value
+type tensorSpec struct {
+	Shape []int
+	Data  []float32
+}
+
+func encodeLearnables(learnables g.Nodes) (specs []tensorSpec, err error) {
+	for _, learnable := range learnables {
+		data, ok := learnable.Value().Data().([]float32)
+		if !ok {
+			return nil, fmt.Errorf("learnable %s is not of type float32", learnable.Name())
+		}
+		backing := make([]float32, len(data))
+		copy(backing, data)
+		specs = append(specs, tensorSpec{
+			Shape: learnable.Shape().Clone(),
+			Data:  backing,
+		})
+	}
+	return
+}
+
+// setLearnables overwrites weights of a compiled individual with serialized ones
+func (individual *Individual) setLearnables(specs []tensorSpec) error {
+	graph := g.NewGraph()
+	var nodes g.Nodes
+	for _, spec := range specs {
+		nodes = append(nodes, g.NodeFromAny(graph, tensor.New(tensor.WithShape(spec.Shape...), tensor.WithBacking(spec.Data))))
+	}
+	return individual.SetLearnables(nodes)
+}
@@ -1,6 +1,9 @@
 package evolution
 
-import "github.com/m8u/goro/pkg/v1/layer"
+import (
+	"github.com/m8u/goro/pkg/v1/layer"
+	"sotsuron/internal/nn"
+)
 
 func SquareShapeSlice(side int) []int {
 	return []int{side, side}
@@ -10,11 +13,34 @@ type Progress struct {
 	Generation int
 	Individual int
 	ETASeconds float64
+
+	CacheHitRate float64 // share of architectures whose fitness was taken from the cache instead of training
+
+	Rung         int // of successive halving that is being trained
+	Dropped      int // individuals of the generation that successive halving has stopped training
+	StoppedEarly int // individuals of the generation whose test accuracy has plateaued
+
+	Niches int // of individuals of similar structure, with speciation
+}
+
+// BestStructure describes the best individual of a generation
+type BestStructure struct {
+	Layers          []layer.Config
+	Hyperparameters Hyperparameters // resolved, so that the ones taken from AdvancedConfig are shown too
+	Niches          []NicheBest     // the best individual of every niche, with speciation
 }
 
 type AllChartData struct {
 	Name     string
 	Accuracy float32
+	Island   int // where the individual lives, 0 if the population isn't split
+}
+
+// BestChartData is the fitness of the best individual of a generation of an island
+type BestChartData struct {
+	Island     int
+	Generation int
+	Fitness    float32
 }
 
 func activationFnToString(activationFn layer.ActivationFn) string {
@@ -55,6 +81,26 @@ type simpleMaxPooling2D struct {
 	Pad    int
 	Stride int
 }
+type simpleAvgPooling2D struct {
+	Type   string // "AvgPooling2D"
+	Height int
+	Width  int
+	Pad    int
+	Stride int
+}
+type simpleBatchNorm struct {
+	Type       string // "BatchNorm"
+	Statistics string // "batch", since nn.BatchNorm has no running statistics and depends on the batch at inference too
+}
+type simpleDropout struct {
+	Type        string // "Dropout"
+	Probability float64
+}
+type simpleSkipMerge struct {
+	Type string // "SkipMerge"
+	From int    // index of the conv block, -1 for the input
+	Mode string
+}
 type simpleFC struct {
 	Type       string // "FC"
 	Input      int
@@ -86,6 +132,29 @@ func SimplifyLayers(layers []layer.Config) (simplified []simpleLayerConfig) {
 				Pad:    maxPooling2D.Pad[0],
 				Stride: maxPooling2D.Stride[0],
 			})
+		case nn.AvgPooling2D:
+			avgPooling2D := layers[i].(nn.AvgPooling2D)
+			simplified = append(simplified, simpleAvgPooling2D{
+				Type:   "AvgPooling2D",
+				Height: avgPooling2D.Kernel[0],
+				Width:  avgPooling2D.Kernel[1],
+				Pad:    avgPooling2D.Pad[0],
+				Stride: avgPooling2D.Stride[0],
+			})
+		case nn.BatchNorm:
+			simplified = append(simplified, simpleBatchNorm{Type: "BatchNorm", Statistics: "batch"})
+		case nn.Dropout:
+			simplified = append(simplified, simpleDropout{
+				Type:        "Dropout",
+				Probability: layers[i].(nn.Dropout).Probability,
+			})
+		case nn.SkipMerge:
+			skipMerge := layers[i].(nn.SkipMerge)
+			simplified = append(simplified, simpleSkipMerge{
+				Type: "SkipMerge",
+				From: skipMerge.From,
+				Mode: skipMerge.Mode,
+			})
 		case layer.FC:
 			fc := layers[i].(layer.FC)
 			simplified = append(simplified, simpleFC{
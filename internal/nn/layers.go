@@ -0,0 +1,228 @@
+// Package nn provides layers for goro sequential models that goro itself lacks
+package nn
+
+import (
+	"fmt"
+	g "github.com/m8u/gorgonia"
+	"github.com/m8u/goro/pkg/v1/layer"
+	t "gorgonia.org/tensor"
+)
+
+// AvgPooling2D implements average pooling, it is configured the same way as layer.MaxPooling2D.
+// Padding counts as zeros, so every window is divided by the size of the kernel
+type AvgPooling2D struct {
+	// Shape of the kernel.
+	// Defaults to (2, 2)
+	Kernel t.Shape
+
+	// Pad
+	// Defaults to (0, 0)
+	Pad []int
+
+	// Stride
+	// Defaults to (2, 2)
+	Stride []int
+}
+
+func (p AvgPooling2D) Validate() error {
+	return nil
+}
+
+func (p AvgPooling2D) ApplyDefaults() layer.Config {
+	if len(p.Kernel) == 0 {
+		p.Kernel = []int{2, 2}
+	}
+	if len(p.Pad) == 0 {
+		p.Pad = []int{0, 0}
+	}
+	if len(p.Stride) == 0 {
+		p.Stride = []int{2, 2}
+	}
+	return p
+}
+
+func (p AvgPooling2D) Compile(graph *g.ExprGraph, opts ...layer.CompileOpt) layer.Layer {
+	return &avgPooling2D{config: p, graph: graph}
+}
+
+func (p AvgPooling2D) Clone() layer.Config {
+	return AvgPooling2D{
+		Kernel: p.Kernel.Clone(),
+		Pad:    append([]int(nil), p.Pad...),
+		Stride: append([]int(nil), p.Stride...),
+	}
+}
+
+type avgPooling2D struct {
+	config AvgPooling2D
+	graph  *g.ExprGraph
+}
+
+// Fwd averages windows of im2col rather than using g.AveragePool2D, whose gradient breaks on windows that lie
+// entirely in padding and ignores all but one element of every window
+func (p *avgPooling2D) Fwd(x *g.Node) (*g.Node, error) {
+	shape := x.Shape()
+	if shape.Dims() != 4 {
+		return nil, fmt.Errorf("average pooling expects a BCHW input, got shape %v", shape)
+	}
+	cols, err := g.Im2Col(x, p.config.Kernel, p.config.Pad, p.config.Stride, t.Shape{1, 1})
+	if err != nil {
+		return nil, err
+	}
+	colsShape := cols.Shape()
+	batch, height, width, channels := colsShape[0], colsShape[1], colsShape[2], shape[1]
+	windows, err := g.Reshape(cols, t.Shape{batch * height * width * channels, p.config.Kernel.TotalSize()})
+	if err != nil {
+		return nil, err
+	}
+	means, err := g.Mean(windows, 1)
+	if err != nil {
+		return nil, err
+	}
+	if means, err = g.Reshape(means, t.Shape{batch, height, width, channels}); err != nil {
+		return nil, err
+	}
+	return g.Transpose(means, 0, 3, 1, 2)
+}
+
+func (p *avgPooling2D) Learnables() g.Nodes {
+	return g.Nodes{}
+}
+
+func (p *avgPooling2D) Clone() layer.Layer {
+	return &avgPooling2D{config: p.config.Clone().(AvgPooling2D)}
+}
+
+func (p *avgPooling2D) Graph() *g.ExprGraph {
+	return p.graph
+}
+
+// BatchNorm normalizes every channel of a BCHW input by its mean and variance over the batch.
+// It has no learnable scale and shift, since goro only shares learnables of its own layers between graphs,
+// and it has no running statistics, since they would be kept per graph and not saved with the weights.
+// So it uses statistics of the current batch in the online graphs too, and outputs of a model with it
+// depend on the other samples of the batch at inference, and on the spatial statistics of a single sample
+type BatchNorm struct {
+	// Defaults to 1e-5
+	Epsilon float64
+}
+
+func (b BatchNorm) Validate() error {
+	if b.Epsilon < 0 {
+		return fmt.Errorf("epsilon must not be negative")
+	}
+	return nil
+}
+
+func (b BatchNorm) ApplyDefaults() layer.Config {
+	if b.Epsilon == 0 {
+		b.Epsilon = 1e-5
+	}
+	return b
+}
+
+func (b BatchNorm) Compile(graph *g.ExprGraph, opts ...layer.CompileOpt) layer.Layer {
+	return &batchNorm{config: b, graph: graph}
+}
+
+func (b BatchNorm) Clone() layer.Config {
+	return b
+}
+
+type batchNorm struct {
+	config BatchNorm
+	graph  *g.ExprGraph
+}
+
+func (b *batchNorm) Fwd(x *g.Node) (*g.Node, error) {
+	if x.Dims() != 4 {
+		return nil, fmt.Errorf("batch normalization expects a BCHW input, got shape %v", x.Shape())
+	}
+	// the identity scale and shift of every channel are broadcast over the batch and the spatial dimensions.
+	// They need distinct names, since a graph merges unnamed inputs of the same shape into one node
+	channels := x.Shape()[1]
+	scale := g.NewTensor(b.graph, x.Dtype(), 4, g.WithShape(1, channels, 1, 1), g.WithName(x.Name()+"_γ"), g.WithInit(g.Ones()))
+	bias := g.NewTensor(b.graph, x.Dtype(), 4, g.WithShape(1, channels, 1, 1), g.WithName(x.Name()+"_β"), g.WithInit(g.Zeroes()))
+	// the momentum only updates the running statistics, which are never used
+	normalized, _, _, _, err := g.BatchNorm(x, scale, bias, 0, b.config.Epsilon)
+	return normalized, err
+}
+
+func (b *batchNorm) Learnables() g.Nodes {
+	return g.Nodes{}
+}
+
+func (b *batchNorm) Clone() layer.Layer {
+	return &batchNorm{config: b.config}
+}
+
+func (b *batchNorm) Graph() *g.ExprGraph {
+	return b.graph
+}
+
+// numTrainGraphs is how many graphs goro compiles for training before the online ones: train and train batch
+const numTrainGraphs = 2
+
+// Dropout zeroes inputs with Probability during training. Unlike layer.Dropout it passes inputs through
+// unchanged in the online graphs used for evaluation. goro compiles the train, train batch, online and
+// online batch graphs in this order from clones of the same config, so clones share a counter of compiled graphs
+type Dropout struct {
+	// Probability of dropping out.
+	// Defaults to 0.5
+	Probability float64
+
+	compiled *int
+}
+
+func (d Dropout) Validate() error {
+	if d.Probability < 0 || d.Probability >= 1 {
+		return fmt.Errorf("dropout probability must be in [0, 1)")
+	}
+	return nil
+}
+
+func (d Dropout) ApplyDefaults() layer.Config {
+	if d.Probability == 0 {
+		d.Probability = 0.5
+	}
+	if d.compiled == nil {
+		d.compiled = new(int)
+	}
+	return d
+}
+
+func (d Dropout) Compile(graph *g.ExprGraph, opts ...layer.CompileOpt) layer.Layer {
+	d = d.ApplyDefaults().(Dropout)
+	training := *d.compiled < numTrainGraphs
+	*d.compiled++
+	return &dropout{config: d, graph: graph, training: training}
+}
+
+func (d Dropout) Clone() layer.Config {
+	return d
+}
+
+type dropout struct {
+	config   Dropout
+	graph    *g.ExprGraph
+	training bool
+}
+
+func (d *dropout) Fwd(x *g.Node) (*g.Node, error) {
+	if !d.training {
+		return x, nil
+	}
+	return g.Dropout(x, d.config.Probability)
+}
+
+func (d *dropout) Learnables() g.Nodes {
+	return g.Nodes{}
+}
+
+func (d *dropout) Clone() layer.Layer {
+	return &dropout{config: d.config, training: d.training}
+}
+
+func (d *dropout) Graph() *g.ExprGraph {
+	return d.graph
+}
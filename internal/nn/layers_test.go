@@ -0,0 +1,180 @@
+package nn
+
+import (
+	g "github.com/m8u/gorgonia"
+	"github.com/m8u/goro/pkg/v1/layer"
+	m "github.com/m8u/goro/pkg/v1/model"
+	"gorgonia.org/tensor"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLayers(t *testing.T) {
+	model, _ := m.NewSequential("test")
+	model.AddLayers(
+		layer.Conv2D{Input: 1, Output: 4, Height: 3, Width: 3, Activation: layer.ReLU.Clone(), Pad: []int{1, 1}, Stride: []int{1, 1}},
+		BatchNorm{},
+		AvgPooling2D{Kernel: []int{2, 2}, Pad: []int{0, 0}, Stride: []int{2, 2}},
+		Dropout{Probability: 0.5},
+		layer.Flatten{},
+		layer.FC{Input: 4 * 4 * 4, Output: 3, Activation: layer.Linear.Clone()},
+	)
+	err := model.Compile(m.NewInput("x", []int{1, 1, 8, 8}), m.NewInput("y", []int{1, 3}), m.WithBatchSize(4))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	x := tensor.New(tensor.WithShape(4, 1, 8, 8), tensor.WithBacking(tensor.Range(tensor.Float32, 0, 4*8*8)))
+	y := tensor.New(tensor.WithShape(4, 3), tensor.WithBacking([]float32{1, 0, 0, 0, 1, 0, 0, 0, 1, 1, 0, 0}))
+	if err = model.FitBatch(x, y); err != nil {
+		t.Fatalf("FitBatch() error = %v", err)
+	}
+
+	// Dropout is only applied in train graphs, so evaluation is deterministic
+	prediction, err := model.PredictBatch(x)
+	if err != nil {
+		t.Fatalf("PredictBatch() error = %v", err)
+	}
+	want := prediction.(*tensor.Dense).Clone()
+	if prediction, err = model.PredictBatch(x); err != nil {
+		t.Fatalf("PredictBatch() error = %v", err)
+	}
+	if !reflect.DeepEqual(prediction.(*tensor.Dense).Data(), want.(*tensor.Dense).Data()) {
+		t.Errorf("PredictBatch() = %v, want %v", prediction, want)
+	}
+}
+
+func TestAvgPooling2D(t *testing.T) {
+	// a pad of 2 with a kernel of 2 makes windows that lie entirely in padding
+	model, _ := m.NewSequential("test")
+	model.AddLayers(
+		AvgPooling2D{Kernel: []int{2, 2}, Pad: []int{2, 2}, Stride: []int{2, 2}},
+		layer.Flatten{},
+		layer.FC{Input: 2 * 4 * 4, Output: 3, Activation: layer.Linear.Clone()},
+	)
+	err := model.Compile(m.NewInput("x", []int{1, 2, 4, 4}), m.NewInput("y", []int{1, 3}), m.WithBatchSize(1))
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	x := tensor.New(tensor.WithShape(1, 2, 4, 4), tensor.WithBacking(tensor.Range(tensor.Float32, 0, 2*4*4)))
+	y := tensor.New(tensor.WithShape(1, 3), tensor.WithBacking([]float32{1, 0, 0}))
+	if err = model.FitBatch(x, y); err != nil {
+		t.Fatalf("FitBatch() error = %v", err)
+	}
+	pooled := model.Chain.Layers[0].(AvgPooling2D)
+	pooling := pooled.Compile(nil).(*avgPooling2D)
+	graph := g.NewGraph()
+	input := g.NewTensor(graph, g.Float32, 4, g.WithShape(1, 2, 4, 4), g.WithValue(x))
+	output, err := pooling.Fwd(input)
+	if err != nil {
+		t.Fatalf("Fwd() error = %v", err)
+	}
+	vm := g.NewTapeMachine(graph)
+	defer vm.Close()
+	if err = vm.RunAll(); err != nil {
+		t.Fatalf("RunAll() error = %v", err)
+	}
+	got := output.Value().Data().([]float32)
+	want := make([]float32, 2*4*4)
+	for c := 0; c < 2; c++ {
+		for i := 0; i < 2; i++ {
+			for j := 0; j < 2; j++ {
+				var sum float32
+				for _, offset := range []int{0, 1, 4, 5} {
+					sum += float32(c*16 + i*8 + j*2 + offset)
+				}
+				want[c*16+(i+1)*4+j+1] = sum / 4
+			}
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Fwd() = %v, want %v", got, want)
+	}
+}
+
+func TestBatchNorm(t *testing.T) {
+	// channels have different offsets and scales, and each is normalized over the batch and the spatial dimensions
+	graph := g.NewGraph()
+	backing := make([]float32, 2*2*2*2)
+	for i := range backing {
+		c := i / 4 % 2
+		backing[i] = float32(i%4+i/8*4)*float32(1+9*c) + float32(100*c)
+	}
+	x := tensor.New(tensor.WithShape(2, 2, 2, 2), tensor.WithBacking(backing))
+	input := g.NewTensor(graph, g.Float32, 4, g.WithShape(2, 2, 2, 2), g.WithValue(x))
+	output, err := BatchNorm{}.ApplyDefaults().(BatchNorm).Compile(graph).Fwd(input)
+	if err != nil {
+		t.Fatalf("Fwd() error = %v", err)
+	}
+	vm := g.NewTapeMachine(graph)
+	defer vm.Close()
+	if err = vm.RunAll(); err != nil {
+		t.Fatalf("RunAll() error = %v", err)
+	}
+	if !output.Shape().Eq(tensor.Shape{2, 2, 2, 2}) {
+		t.Fatalf("Fwd() shape = %v, want %v", output.Shape(), tensor.Shape{2, 2, 2, 2})
+	}
+	got := output.Value().Data().([]float32)
+	for c := 0; c < 2; c++ {
+		var mean, variance float64
+		for i, value := range got {
+			if i/4%2 == c {
+				mean += float64(value) / 8
+			}
+		}
+		for i, value := range got {
+			if i/4%2 == c {
+				variance += (float64(value) - mean) * (float64(value) - mean) / 8
+			}
+		}
+		if math.Abs(mean) > 1e-3 || math.Abs(variance-1) > 1e-2 {
+			t.Errorf("channel %d has mean %v and variance %v, want 0 and 1", c, mean, variance)
+		}
+	}
+}
+
+func TestDropout_Validate(t *testing.T) {
+	for _, probability := range []float64{-0.1, 1} {
+		if err := (Dropout{Probability: probability}).Validate(); err == nil {
+			t.Errorf("Validate() of probability %v must fail", probability)
+		}
+	}
+}
+
+func TestSkips(t *testing.T) {
+	for _, mode := range []string{MergeAdd, MergeConcat} {
+		t.Run(mode, func(t *testing.T) {
+			skips := NewSkips()
+			channels := 2
+			if mode == MergeConcat {
+				channels = 3
+			}
+			model, _ := m.NewSequential("test")
+			model.AddLayers(
+				layer.Conv2D{Input: 1, Output: 2, Height: 3, Width: 3, Activation: layer.ReLU.Clone(), Pad: []int{1, 1}, Stride: []int{1, 1}},
+				SkipSource{ID: 0, Skips: skips},
+				layer.Conv2D{Input: 2, Output: 2, Height: 3, Width: 3, Activation: layer.ReLU.Clone(), Pad: []int{0, 0}, Stride: []int{1, 1}},
+				SkipMerge{From: 0, Mode: MergeAdd, Skips: skips},
+				layer.Flatten{},
+				layer.FC{Input: 2 * 6 * 6, Output: 3, Activation: layer.Linear.Clone()},
+			)
+			if mode == MergeConcat {
+				model.Chain.Layers[3] = SkipMerge{From: -1, Mode: MergeConcat, Skips: skips}
+				model.Chain.Layers = append([]layer.Config{SkipSource{ID: -1, Skips: skips}}, model.Chain.Layers...)
+				model.Chain.Layers[6] = layer.FC{Input: channels * 6 * 6, Output: 3, Activation: layer.Linear.Clone()}
+			}
+			err := model.Compile(m.NewInput("x", []int{1, 1, 8, 8}), m.NewInput("y", []int{1, 3}), m.WithBatchSize(4))
+			if err != nil {
+				t.Fatalf("Compile() error = %v", err)
+			}
+			x := tensor.New(tensor.WithShape(4, 1, 8, 8), tensor.WithBacking(tensor.Range(tensor.Float32, 0, 4*8*8)))
+			y := tensor.New(tensor.WithShape(4, 3), tensor.WithBacking([]float32{1, 0, 0, 0, 1, 0, 0, 0, 1, 1, 0, 0}))
+			if err = model.FitBatch(x, y); err != nil {
+				t.Fatalf("FitBatch() error = %v", err)
+			}
+			if _, err = model.Predict(tensor.New(tensor.WithShape(1, 1, 8, 8), tensor.WithBacking(tensor.Range(tensor.Float32, 0, 8*8)))); err != nil {
+				t.Errorf("Predict() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package nn
+
+import (
+	"fmt"
+	g "github.com/m8u/gorgonia"
+	"github.com/m8u/goro/pkg/v1/layer"
+	"sync"
+)
+
+const (
+	MergeAdd    = "add"
+	MergeConcat = "concat"
+)
+
+// Skips lets SkipMerge layers of a sequential model use outputs of earlier SkipSource layers.
+// goro compiles a model into several graphs, so outputs are remembered per graph
+type Skips struct {
+	mu    sync.Mutex
+	nodes map[*g.ExprGraph]map[int]*g.Node
+}
+
+func NewSkips() *Skips {
+	return &Skips{nodes: make(map[*g.ExprGraph]map[int]*g.Node)}
+}
+
+func (skips *Skips) set(id int, node *g.Node) {
+	skips.mu.Lock()
+	defer skips.mu.Unlock()
+	if skips.nodes[node.Graph()] == nil {
+		skips.nodes[node.Graph()] = make(map[int]*g.Node)
+	}
+	skips.nodes[node.Graph()][id] = node
+}
+
+func (skips *Skips) get(graph *g.ExprGraph, id int) (*g.Node, bool) {
+	skips.mu.Lock()
+	defer skips.mu.Unlock()
+	node, ok := skips.nodes[graph][id]
+	return node, ok
+}
+
+// SkipSource passes its input through unchanged and remembers it for SkipMerge layers with From = ID
+type SkipSource struct {
+	ID    int
+	Skips *Skips
+}
+
+func (s SkipSource) Validate() error {
+	if s.Skips == nil {
+		return fmt.Errorf("skip source %d is not connected", s.ID)
+	}
+	return nil
+}
+
+func (s SkipSource) ApplyDefaults() layer.Config {
+	return s
+}
+
+func (s SkipSource) Compile(graph *g.ExprGraph, opts ...layer.CompileOpt) layer.Layer {
+	return &skipSource{config: s, graph: graph}
+}
+
+func (s SkipSource) Clone() layer.Config {
+	return s
+}
+
+type skipSource struct {
+	config SkipSource
+	graph  *g.ExprGraph
+}
+
+func (s *skipSource) Fwd(x *g.Node) (*g.Node, error) {
+	s.config.Skips.set(s.config.ID, x)
+	return x, nil
+}
+
+func (s *skipSource) Learnables() g.Nodes {
+	return g.Nodes{}
+}
+
+func (s *skipSource) Clone() layer.Layer {
+	return &skipSource{config: s.config}
+}
+
+func (s *skipSource) Graph() *g.ExprGraph {
+	return s.graph
+}
+
+// SkipMerge adds the output of the SkipSource with ID = From to its BCHW input or concatenates them along channels.
+// If the source has a higher resolution, its center is cropped to the resolution of the input
+type SkipMerge struct {
+	From  int
+	Mode  string // MergeAdd or MergeConcat
+	Skips *Skips
+}
+
+func (s SkipMerge) Validate() error {
+	if s.Skips == nil {
+		return fmt.Errorf("skip from %d is not connected", s.From)
+	}
+	if s.Mode != MergeAdd && s.Mode != MergeConcat {
+		return fmt.Errorf("unknown merge mode %q", s.Mode)
+	}
+	return nil
+}
+
+func (s SkipMerge) ApplyDefaults() layer.Config {
+	return s
+}
+
+func (s SkipMerge) Compile(graph *g.ExprGraph, opts ...layer.CompileOpt) layer.Layer {
+	return &skipMerge{config: s, graph: graph}
+}
+
+func (s SkipMerge) Clone() layer.Config {
+	return s
+}
+
+type skipMerge struct {
+	config SkipMerge
+	graph  *g.ExprGraph
+}
+
+func (s *skipMerge) Fwd(x *g.Node) (*g.Node, error) {
+	source, ok := s.config.Skips.get(x.Graph(), s.config.From)
+	if !ok {
+		return nil, fmt.Errorf("skip source %d is not compiled before the merge", s.config.From)
+	}
+	shape, sourceShape := x.Shape(), source.Shape()
+	if shape.Dims() != 4 || sourceShape.Dims() != 4 || shape[0] != sourceShape[0] {
+		return nil, fmt.Errorf("can't merge shapes %v and %v", sourceShape, shape)
+	}
+	height, width := shape[2], shape[3]
+	if sourceShape[2] < height || sourceShape[3] < width {
+		return nil, fmt.Errorf("skip source of shape %v is smaller than %v", sourceShape, shape)
+	}
+	if sourceShape[2] != height || sourceShape[3] != width {
+		top, left := (sourceShape[2]-height)/2, (sourceShape[3]-width)/2
+		var err error
+		if source, err = g.Slice(source, nil, nil, g.S(top, top+height), g.S(left, left+width)); err != nil {
+			return nil, err
+		}
+	}
+	if s.config.Mode == MergeConcat {
+		return g.Concat(1, x, source)
+	}
+	if sourceShape[1] != shape[1] {
+		return nil, fmt.Errorf("can't add %d channels to %d", sourceShape[1], shape[1])
+	}
+	return g.Add(x, source)
+}
+
+func (s *skipMerge) Learnables() g.Nodes {
+	return g.Nodes{}
+}
+
+func (s *skipMerge) Clone() layer.Layer {
+	return &skipMerge{config: s.config}
+}
+
+func (s *skipMerge) Graph() *g.ExprGraph {
+	return s.graph
+}
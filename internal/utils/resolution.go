@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/m8u/goro/pkg/v1/layer"
 	"gorgonia.org/tensor"
+	"sotsuron/internal/nn"
 )
 
 type Resolution struct {
@@ -29,6 +30,11 @@ func (res *Resolution) After(config layer.Config) (resAfter Resolution) {
 			(res.Width-maxPooling2D.Kernel[1]+2*maxPooling2D.Pad[1])/maxPooling2D.Stride[1] + 1,
 			(res.Height-maxPooling2D.Kernel[0]+2*maxPooling2D.Pad[0])/maxPooling2D.Stride[0] + 1,
 		}
+	} else if avgPooling2D, ok := config.(nn.AvgPooling2D); ok {
+		resAfter = Resolution{
+			(res.Width-avgPooling2D.Kernel[1]+2*avgPooling2D.Pad[1])/avgPooling2D.Stride[1] + 1,
+			(res.Height-avgPooling2D.Kernel[0]+2*avgPooling2D.Pad[0])/avgPooling2D.Stride[0] + 1,
+		}
 	} else {
 		resAfter = *res
 	}
@@ -54,6 +60,11 @@ func (res *Resolution) before(config layer.Config) (resBefore Resolution) {
 			(res.Width-1)*maxPooling2D.Stride[1] - 2*maxPooling2D.Pad[1] + maxPooling2D.Kernel[1],
 			(res.Height-1)*maxPooling2D.Stride[0] - 2*maxPooling2D.Pad[0] + maxPooling2D.Kernel[0],
 		}
+	} else if avgPooling2D, ok := config.(nn.AvgPooling2D); ok {
+		resBefore = Resolution{
+			(res.Width-1)*avgPooling2D.Stride[1] - 2*avgPooling2D.Pad[1] + avgPooling2D.Kernel[1],
+			(res.Height-1)*avgPooling2D.Stride[0] - 2*avgPooling2D.Pad[0] + avgPooling2D.Kernel[0],
+		}
 	} else {
 		resBefore = *res
 	}
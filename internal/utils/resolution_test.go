@@ -132,7 +132,7 @@ func Test_resolution_before(t *testing.T) {
 			Width:  3 + rand.Intn(100),
 			Height: 3 + rand.Intn(100),
 		}
-		conv2D, _ := evolution.GenerateRandomConv2D(evolution.DefaultAdvancedConfig(), 10, res)
+		conv2D, _ := evolution.GenerateRandomConv2D(rand.New(rand.NewSource(rand.Uint64())), evolution.DefaultAdvancedConfig(), 10, res)
 		resAfter := res.After(conv2D)
 		tests = append(tests, test{
 			name:          "conv2d",
@@ -145,7 +145,7 @@ func Test_resolution_before(t *testing.T) {
 			Width:  3 + rand.Intn(100),
 			Height: 3 + rand.Intn(100),
 		}
-		maxPooling2D, _ := evolution.GenerateRandomMaxPooling2D(evolution.DefaultAdvancedConfig(), res)
+		maxPooling2D, _ := evolution.GenerateRandomMaxPooling2D(rand.New(rand.NewSource(rand.Uint64())), evolution.DefaultAdvancedConfig(), res)
 		resAfter = res.After(maxPooling2D)
 		tests = append(tests, test{
 			name:          "maxpooling2d",
@@ -181,7 +181,7 @@ func Test_resolution_beforeMany(t *testing.T) {
 		var layers []layer.Config
 	generateUntilHasConv2D:
 		for {
-			layers = evolution.GenerateRandomStructure(evolution.DefaultAdvancedConfig(), inputWidth, inputHeight, 2, true)
+			layers = evolution.GenerateRandomStructure(rand.New(rand.NewSource(rand.Uint64())), evolution.DefaultAdvancedConfig(), inputWidth, inputHeight, 2, true)
 			for _, l := range layers {
 				if _, ok := l.(layer.Conv2D); ok {
 					break generateUntilHasConv2D